@@ -6,8 +6,8 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -24,6 +24,7 @@ type APW struct {
 	FilesReferenced map[string]string
 	FilesMissing    []string
 	Workspace       *Workspace
+	extras          map[string][]byte
 }
 
 // NewAPW loads or creates an APW object
@@ -113,11 +114,11 @@ func (apw *APW) populateFileReferences() error {
 			// Cycle through Files
 			for _, file := range system.Files {
 				// Depending on file path type (Absolute or Relative), add this hash with full qualified name
-				if filepath.IsAbs(file.FilePathName) {
-					apw.FilesReferenced[file.FilePathName] = file.Type
-				} else {
-					apw.FilesReferenced[filepath.Join(apw.OriginPath, file.FilePathName)] = file.Type
+				fn := localPath(file.FilePathName)
+				if !filepath.IsAbs(fn) {
+					fn = filepath.Join(apw.OriginPath, fn)
 				}
+				apw.FilesReferenced[findFold(fn)] = file.Type
 			}
 		}
 	}
@@ -132,6 +133,76 @@ func (apw *APW) populateFileReferences() error {
 	return nil
 }
 
+// localPath converts the Windows separators used in workspaces to those of
+// this machine
+func localPath(p string) string {
+	return filepath.FromSlash(strings.Replace(p, `\`, `/`, -1))
+}
+
+// findFold returns the path of an existing file matching fn ignoring case,
+// as workspaces written on Windows may not match the case of files copied
+// elsewhere. fn is returned unchanged if it exists or can't be found
+func findFold(fn string) string {
+	if _, err := os.Stat(fn); err == nil {
+		return fn
+	}
+	dir := filepath.Dir(fn)
+	if dir == fn {
+		return fn
+	}
+	dir = findFold(dir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fn
+	}
+	for _, f := range files {
+		if strings.EqualFold(f.Name(), filepath.Base(fn)) {
+			return filepath.Join(dir, f.Name())
+		}
+	}
+	return fn
+}
+
+// RefreshFiles rebuilds FilesReferenced and FilesMissing, for use after
+// files have been added to or removed from the workspace
+func (apw *APW) RefreshFiles() error {
+	apw.FilesReferenced = make(map[string]string)
+	apw.FilesMissing = nil
+	return apw.populateFileReferences()
+}
+
+// AddSystemFile adds the file at fn to a system unless the system already
+// holds it, storing the path relative to the .apw where possible. Returns the
+// new file, or nil if it was already present. Call RefreshFiles once done
+func (apw *APW) AddSystemFile(s *System, fn string, t Type, c CompileType) *File {
+
+	// Compare against the system's files as populateFileReferences sees them
+	fn = filepath.Clean(localPath(fn))
+	for _, f := range s.Files {
+		p := localPath(f.FilePathName)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(apw.OriginPath, p)
+		}
+		if strings.EqualFold(filepath.Clean(p), fn) {
+			return nil
+		}
+	}
+
+	if rel, err := filepath.Rel(apw.OriginPath, fn); err == nil {
+		fn = rel
+	}
+	f := NewFile(fn, t, c)
+
+	// Match Netlinx Studio, which names files without their extension
+	f.Identifier = strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+
+	// Workspaces always store Windows separators
+	f.FilePathName = strings.Replace(f.FilePathName, `/`, `\`, -1)
+
+	s.AddFile(f)
+	return f
+}
+
 // FindAPWs searches all subdirectories (recursivly option) for any
 // .apw files and returns a list of AMXProjects
 func FindAPWs(sourceDir string, recursive bool) []*APW {
@@ -188,18 +259,11 @@ func (apw *APW) ExportAPW() error {
 // ExportArchive pulls all .apw files together into a zip in the target folder using the workspace name
 func (apw *APW) ExportArchive(destDir string, buildID string) error {
 	// Verify the APW file is all good before we do this
-	if len(apw.FilesMissing) > 0 {
-		var e bytes.Buffer
-		e.WriteString(strconv.Itoa(len(apw.FilesMissing)))
-		e.WriteString(" File")
-		if len(apw.FilesMissing) > 1 {
-			e.WriteString("s")
-		}
-		e.WriteString(" not found")
-		return errors.New(e.String())
+	if err := apw.checkMissing(); err != nil {
+		return err
 	}
 
-	// Create a Zip writer with defered close
+	// Create a Zip file with defered close
 	var filename bytes.Buffer
 	filename.WriteString(apw.Identifier)
 	if buildID != "" {
@@ -212,53 +276,174 @@ func (apw *APW) ExportArchive(destDir string, buildID string) error {
 	}
 	defer myZipFile.Close()
 
-	z := zip.NewWriter(myZipFile)
-	defer z.Close()
+	return apw.WriteArchive(myZipFile)
+}
 
-	// Add each file to the Archive
-	for file, fileType := range apw.FilesReferenced {
+// checkMissing returns an error if any referenced files weren't found
+func (apw *APW) checkMissing() error {
+	if len(apw.FilesMissing) == 0 {
+		return nil
+	}
+	var e bytes.Buffer
+	e.WriteString(strconv.Itoa(len(apw.FilesMissing)))
+	e.WriteString(" File")
+	if len(apw.FilesMissing) > 1 {
+		e.WriteString("s")
+	}
+	e.WriteString(" not found")
+	return errors.New(e.String())
+}
 
-		// Open existing file
-		fileToZip, err := os.Open(file)
-		if err != nil {
-			return err
-		}
-		defer fileToZip.Close()
+// WriteArchive writes the zip produced by ExportArchive to w, with each
+// referenced file in a folder for its type and the workspace XML at the root
+func (apw *APW) WriteArchive(w io.Writer) error {
+	// Verify the APW file is all good before we do this
+	if err := apw.checkMissing(); err != nil {
+		return err
+	}
 
-		// Get the file information
-		info, err := fileToZip.Stat()
-		if err != nil {
+	entries, err := apw.archiveEntries()
+	if err != nil {
+		return err
+	}
+
+	z := zip.NewWriter(w)
+
+	for _, e := range entries {
+		if err := addToZip(z, e.file, e.name); err != nil {
 			return err
 		}
-		header, err := zip.FileInfoHeader(info)
-
-		// Set file to correct folder based on file type
-		header.Name = filepath.Join(FileFolder(fileType), header.Name)
+	}
 
-		// Compress File
-		header.Method = zip.Deflate
+	// Save XML
+	f, err := z.Create(apw.Identifier + ".apw")
+	if err != nil {
+		return err
+	}
+	b, err := apw.Workspace.ToXML()
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
 
-		writer, err := z.CreateHeader(header)
+	// Save any extra files
+	var extras []string
+	for name := range apw.extras {
+		extras = append(extras, name)
+	}
+	sort.Strings(extras)
+	for _, name := range extras {
+		f, err := z.Create(name)
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(writer, fileToZip)
-		if err != nil {
+		if _, err = f.Write(apw.extras[name]); err != nil {
 			return err
 		}
+	}
+
+	return z.Close()
+}
 
+// AddArchiveFile adds a file which isn't part of the workspace, such as a
+// manifest, to the root of archives written by WriteArchive
+func (apw *APW) AddArchiveFile(name string, b []byte) {
+	if apw.extras == nil {
+		apw.extras = make(map[string][]byte)
 	}
+	apw.extras[name] = b
+}
 
-	// Save XML
-	f, err := z.Create(apw.Identifier + ".apw")
+// ArchiveFiles returns the names of the workspace files WriteArchive will
+// write, in order
+func (apw *APW) ArchiveFiles() ([]string, error) {
+	entries, err := apw.archiveEntries()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	return names, nil
+}
+
+// archiveEntry is a referenced file and its name in an archive
+type archiveEntry struct {
+	file string
+	name string
+}
+
+// archiveEntries lists the referenced files in order, so archives of the
+// same workspace match, each in a folder for its type. Different files which
+// would have the same name in the archive are an error, as the workspace
+// can't refer to both once its paths are made relative
+func (apw *APW) archiveEntries() ([]archiveEntry, error) {
+
+	var files []string
+	for file := range apw.FilesReferenced {
+		files = append(files, file)
 	}
-	b, _ := apw.Workspace.ToXML()
-	_, err = f.Write([]byte(b))
+	sort.Strings(files)
+
+	var entries []archiveEntry
+	added := make(map[string]string)
+	for _, file := range files {
+		// Set file to correct folder based on file type
+		name := path.Join(FileFolder(apw.FilesReferenced[file]), filepath.Base(file))
+		if prev, ok := added[strings.ToLower(name)]; ok {
+			// The same file named with a different case is fine
+			if sameFile(prev, file) {
+				continue
+			}
+			return nil, errors.New(prev + " and " + file + " would both be archived as " + name)
+		}
+		added[strings.ToLower(name)] = file
+		entries = append(entries, archiveEntry{file, name})
+	}
+	return entries, nil
+}
+
+// sameFile returns true if both paths are the same file on disk
+func sameFile(a string, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// addToZip compresses a file into the archive under name
+func addToZip(z *zip.Writer, file string, name string) error {
+
+	// Open existing file
+	fileToZip, err := os.Open(file)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer fileToZip.Close()
 
-	return nil
+	// Get the file information
+	info, err := fileToZip.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	// Compress File
+	header.Method = zip.Deflate
+
+	writer, err := z.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, fileToZip)
+	return err
 }
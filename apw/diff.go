@@ -0,0 +1,176 @@
+package apw
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ChangeType specifies how an item differs between two workspaces
+type ChangeType int
+
+// ChangeType values for use outside this module
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+// ChangeTypes for use outside this module
+var changeTypes = [...]string{
+	"added",
+	"removed",
+	"modified",
+}
+
+// String returns the English name of the ChangeType
+func (c ChangeType) String() string { return changeTypes[c] }
+
+// Change is a single difference between two workspaces. Field, Old and New
+// are only set for modifications
+type Change struct {
+	Type    ChangeType
+	Project string
+	System  string
+	File    string
+	Field   string
+	Old     string
+	New     string
+}
+
+// String returns the Change on a single line
+func (c Change) String() string {
+	var parts []string
+	for _, s := range []string{c.Project, c.System, c.File} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	s := c.Type.String() + ": " + strings.Join(parts, " / ")
+	if c.Type == ChangeModified {
+		s += ": " + c.Field + ` "` + c.Old + `" -> "` + c.New + `"`
+	}
+	return s
+}
+
+// systemFields are the System values compared by Diff
+var systemFields = []struct {
+	name  string
+	value func(*System) string
+}{
+	{"SysID", func(s *System) string { return strconv.Itoa(s.SysID) }},
+	{"IsActive", func(s *System) string { return s.IsActive }},
+	{"Platform", func(s *System) string { return s.Platform }},
+	{"Transport", func(s *System) string { return s.Transport }},
+	{"TransportEx", func(s *System) string { return s.TransportEx }},
+	{"TransTCPIP", func(s *System) string { return s.TransTCPIP }},
+	{"TransTCPIPEx", func(s *System) string { return s.TransTCPIPEx }},
+	{"TransSerial", func(s *System) string { return s.TransSerial }},
+	{"TransSerialEx", func(s *System) string { return s.TransSerialEx }},
+	{"Comments", func(s *System) string { return s.Comments }},
+}
+
+// fileFields are the File values compared by Diff
+var fileFields = []struct {
+	name  string
+	value func(*File) string
+}{
+	{"Identifier", func(f *File) string { return f.Identifier }},
+	{"Type", func(f *File) string { return f.Type }},
+	{"CompileType", func(f *File) string { return f.CompileType }},
+	{"DeviceMap", func(f *File) string {
+		var maps []string
+		for _, d := range f.DeviceMaps {
+			maps = append(maps, d.DevAddr)
+		}
+		return strings.Join(maps, ", ")
+	}},
+}
+
+// fileKey identifies a file within a system regardless of separators or case
+func fileKey(f *File) string {
+	return strings.ToLower(strings.Replace(f.FilePathName, `\`, `/`, -1))
+}
+
+// Diff returns the changes needed to turn workspace a into workspace b.
+// Projects and systems are matched by identifier, files by path
+func Diff(a, b *Workspace) []Change {
+
+	var cs []Change
+
+	for _, pb := range b.Projects {
+		pa := a.FindProject(pb.Identifier)
+		if pa == nil {
+			cs = append(cs, Change{Type: ChangeAdded, Project: pb.Identifier})
+			continue
+		}
+		cs = append(cs, diffProject(pa, pb)...)
+	}
+	for _, pa := range a.Projects {
+		if b.FindProject(pa.Identifier) == nil {
+			cs = append(cs, Change{Type: ChangeRemoved, Project: pa.Identifier})
+		}
+	}
+
+	return cs
+}
+
+// diffProject compares the systems of two projects
+func diffProject(a, b *Project) []Change {
+
+	var cs []Change
+
+	for _, sb := range b.Systems {
+		sa := a.FindSystem(sb.Identifier)
+		if sa == nil {
+			cs = append(cs, Change{Type: ChangeAdded, Project: b.Identifier, System: sb.Identifier})
+			continue
+		}
+		for _, f := range systemFields {
+			if o, n := f.value(sa), f.value(sb); o != n {
+				cs = append(cs, Change{Type: ChangeModified, Project: b.Identifier, System: sb.Identifier, Field: f.name, Old: o, New: n})
+			}
+		}
+		cs = append(cs, diffSystem(b.Identifier, sa, sb)...)
+	}
+	for _, sa := range a.Systems {
+		if b.FindSystem(sa.Identifier) == nil {
+			cs = append(cs, Change{Type: ChangeRemoved, Project: b.Identifier, System: sa.Identifier})
+		}
+	}
+
+	return cs
+}
+
+// diffSystem compares the files of two systems
+func diffSystem(project string, a, b *System) []Change {
+
+	var cs []Change
+
+	files := make(map[string]*File)
+	for _, f := range a.Files {
+		files[fileKey(f)] = f
+	}
+
+	seen := make(map[string]bool)
+	for _, fb := range b.Files {
+		k := fileKey(fb)
+		seen[k] = true
+		fa, ok := files[k]
+		if !ok {
+			cs = append(cs, Change{Type: ChangeAdded, Project: project, System: b.Identifier, File: fb.FilePathName})
+			continue
+		}
+		for _, f := range fileFields {
+			if o, n := f.value(fa), f.value(fb); o != n {
+				cs = append(cs, Change{Type: ChangeModified, Project: project, System: b.Identifier, File: fb.FilePathName, Field: f.name, Old: o, New: n})
+			}
+		}
+	}
+	for _, fa := range a.Files {
+		if !seen[fileKey(fa)] {
+			cs = append(cs, Change{Type: ChangeRemoved, Project: project, System: b.Identifier, File: fa.FilePathName})
+		}
+	}
+
+	return cs
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // System represetents an AMX project in an APW
@@ -74,6 +75,38 @@ func (s *System) AddConnectionToSystem(t *Transport) {
 	s.TransTCPIPEx = buf.String()
 }
 
+// IPTransport returns the TCP/IP connection stored in TransTCPIPEx, or nil
+// if there isn't one. The system's UserName and Password are used in place
+// of those in the connection string when set
+func (s *System) IPTransport() *Transport {
+
+	// Split out host|port|ping|name|username|password
+	fields := strings.Split(s.TransTCPIPEx, "|")
+	for len(fields) < 6 {
+		fields = append(fields, "")
+	}
+	if fields[0] == "" {
+		return nil
+	}
+
+	t := NewIPTransport(fields[0])
+	if port, err := strconv.Atoi(fields[1]); err == nil {
+		t.Port = port
+	}
+	t.PingTest = fields[2] == "1"
+	t.Name = fields[3]
+	t.Username = fields[4]
+	t.Password = fields[5]
+
+	if s.UserName != "" {
+		t.Username = s.UserName
+	}
+	if s.Password != "" {
+		t.Password = s.Password
+	}
+	return t
+}
+
 // FindFile returns a pointer to a system
 func (s *System) FindFile(id string) *File {
 	for i, f := range s.Files {
@@ -0,0 +1,150 @@
+package apw
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Level specifies how serious a Problem is
+type Level int
+
+// Level values for use outside this module
+const (
+	LevelWarning Level = iota
+	LevelError
+)
+
+// Levels for use outside this module
+var levels = [...]string{
+	"warning",
+	"error",
+}
+
+// String returns the English name of the Level
+func (l Level) String() string { return levels[l] }
+
+// Problem is an issue found when validating a workspace
+type Problem struct {
+	Level   Level
+	Project string
+	System  string
+	File    string
+	Message string
+}
+
+// String returns the Problem on a single line
+func (p Problem) String() string {
+	var parts []string
+	for _, s := range []string{p.Project, p.System, p.File} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return p.Level.String() + ": " + p.Message
+	}
+	return p.Level.String() + ": " + strings.Join(parts, " / ") + ": " + p.Message
+}
+
+// validType returns true if t is a file type Netlinx Studio knows
+func validType(t string) bool {
+	for _, s := range types {
+		if strings.EqualFold(s, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the workspace structure for problems Netlinx Studio would
+// reject or silently mishandle. Files are only checked for existence when
+// checkFiles is set, as the APW may not be on the same machine as them
+func (apw *APW) Validate(checkFiles bool) []Problem {
+
+	var ps []Problem
+	add := func(l Level, p, s, f, msg string) {
+		ps = append(ps, Problem{Level: l, Project: p, System: s, File: f, Message: msg})
+	}
+
+	w := apw.Workspace
+	if w == nil || len(w.Projects) == 0 {
+		add(LevelError, "", "", "", "workspace has no projects")
+		return ps
+	}
+
+	projects := make(map[string]bool)
+	for _, p := range w.Projects {
+		if p.Identifier == "" {
+			add(LevelError, "", "", "", "project has no identifier")
+		} else if projects[strings.ToLower(p.Identifier)] {
+			add(LevelError, p.Identifier, "", "", "duplicate project identifier")
+		}
+		projects[strings.ToLower(p.Identifier)] = true
+
+		if len(p.Systems) == 0 {
+			add(LevelWarning, p.Identifier, "", "", "project has no systems")
+		}
+
+		systems := make(map[string]bool)
+		for _, s := range p.Systems {
+			if s.Identifier == "" {
+				add(LevelError, p.Identifier, "", "", "system has no identifier")
+			} else if systems[strings.ToLower(s.Identifier)] {
+				add(LevelError, p.Identifier, s.Identifier, "", "duplicate system identifier")
+			}
+			systems[strings.ToLower(s.Identifier)] = true
+
+			masters := 0
+			files := make(map[string]bool)
+			for _, f := range s.Files {
+				name := f.Identifier
+				if name == "" {
+					name = f.FilePathName
+				}
+				if f.FilePathName == "" {
+					add(LevelError, p.Identifier, s.Identifier, name, "file has no path")
+					continue
+				}
+				if !validType(f.Type) {
+					add(LevelError, p.Identifier, s.Identifier, name, `unknown file type "`+f.Type+`"`)
+				}
+				if f.Type == TypeMasterSrc.String() {
+					masters++
+				}
+				key := strings.ToLower(strings.Replace(f.FilePathName, `\`, `/`, -1))
+				if files[key] {
+					add(LevelWarning, p.Identifier, s.Identifier, name, "file is referenced more than once")
+				}
+				files[key] = true
+			}
+
+			switch {
+			case masters == 0 && len(s.Files) > 0:
+				add(LevelWarning, p.Identifier, s.Identifier, "", "system has no master source")
+			case masters > 1:
+				add(LevelError, p.Identifier, s.Identifier, "", strconv.Itoa(masters)+" master sources")
+			}
+		}
+	}
+
+	if checkFiles {
+		missing := append([]string(nil), apw.FilesMissing...)
+		sort.Strings(missing)
+		for _, fn := range missing {
+			add(LevelError, "", "", fn, "file not found")
+		}
+	}
+
+	return ps
+}
+
+// HasErrors returns true if any of the Problems is an error
+func HasErrors(ps []Problem) bool {
+	for _, p := range ps {
+		if p.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
@@ -126,10 +126,7 @@ func (w *Workspace) convert(pt packType) {
 func (w *Workspace) FromBytes(b []byte) error {
 
 	// Convert XML to Structure
-	xml.Unmarshal(b, &w)
-
-	// Return Cleanly
-	return nil
+	return xml.Unmarshal(b, &w)
 }
 
 // ToXML converts structure to XML bytes
@@ -168,7 +165,7 @@ func (w *Workspace) SetRelativeFilepaths() {
 	for pi, p := range w.Projects {
 		for si, s := range p.Systems {
 			for fi, f := range s.Files {
-				w.Projects[pi].Systems[si].Files[fi].FilePathName = filepath.Join(FileFolder(f.Type), filepath.Base(f.FilePathName))
+				w.Projects[pi].Systems[si].Files[fi].FilePathName = FileFolder(f.Type) + `\` + filepath.Base(localPath(f.FilePathName))
 			}
 		}
 	}
@@ -211,7 +208,7 @@ func FileFolder(t string) string {
 			return "Interfaces"
 		}
 
-	case "XDD", "Module", "DUET", "TKO":
+	case "XDD", "Module", "DUET", "Duet", "TKO":
 		{
 			return "Modules"
 		}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"regexp"
 )
@@ -11,22 +12,33 @@ type Configuration struct {
 	CompilerPath    string `json:"compilerPath"`
 	RepoRootPath    string `json:"repoRootPath"`
 	GlobalAMXFolder string `json:"globalAMXFolder"`
-	GlobalProject   string `json:"globalProject"` 
+	GlobalProject   string `json:"globalProject"`
 }
 
-// Load imports from a JSON file
+// Load imports from a JSON file. A missing file gives an empty
+// Configuration, so the tool can be run with flags alone
 func Load(configFile string) Configuration {
+
+	config := Configuration{}
+
 	// Load in Config Settings
-	file, fileErr := os.Open(configFile)
-	if fileErr != nil {
-		os.Exit(0)
+	file, err := os.Open(configFile)
+	if os.IsNotExist(err) {
+		return config
 	}
+	if err != nil {
+		log.Println("Error Loading config file " + configFile)
+		log.Println(err)
+		os.Exit(1)
+	}
+	defer file.Close()
 
 	// Decode Config Settings
 	decoder := json.NewDecoder(file)
-	config := Configuration{}
-	decodeErr := decoder.Decode(&config)
-	if decodeErr != nil {
+	err = decoder.Decode(&config)
+	if err != nil {
+		log.Println("Config File Error: ")
+		log.Println(err)
 		os.Exit(1)
 	}
 
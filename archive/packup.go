@@ -1,169 +1,142 @@
 package main
 
 import (
+	"errors"
 	"flag"
-	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
-	"time" 
+	"strings"
+
 	"github.com/soloworks/go-netlinx/apw"
+	"github.com/soloworks/go-netlinx/version"
 )
 
 type myargs struct {
+	config    string
 	path      string
-	workspace string 
+	workspace string
 	project   string
 	system    string
+	dest      string
+	version   string
+	axi       string
 	archive   bool
 	handover  bool
 	release   bool
+	batch     bool
 }
 
 func main() {
 
 	// Local Variables
-	c := Load("packup.json") 
 	var args myargs
 
-	// Specify Build ID from UTC
-	buildID := time.Now().UTC().Format("2006_01_02_")
-	buildID = buildID + strconv.Itoa(time.Now().Hour()*3600+time.Now().Minute()*60+time.Now().Second())
-	//buildID := time.Now().UTC().Format("20060102150405")
-	//buildID := strconv.Itoa(int(time.Now().Unix()))
-
-	// Variables used to build project
-	var w = -1
-	var p = -2
-	var s = -2
-
 	// Get Command Line Variables
-	flag.StringVar(&args.path, "Working Path", "", "Path to use (overrides default in config)")
+	flag.StringVar(&args.config, "Config", "packup.json", "Config File name")
+	flag.StringVar(&args.path, "Path", "", "Path to search for workspaces (overrides repoRootPath in config)")
 	flag.StringVar(&args.workspace, "Workspace", "", "Use the specified APW file if found")
-	flag.StringVar(&args.project, "Project", "All", "Specified project to process (Default = All)")
-	flag.StringVar(&args.system, "System", "All", "Specified System to process (Default = All)")
+	flag.StringVar(&args.project, "Project", "", "Specified project to process, or All (asks if not set)")
+	flag.StringVar(&args.system, "System", "", "Specified System to process, or All (asks if not set)")
+	flag.StringVar(&args.dest, "Dest", "", "Folder for the packages (Default = PackUp beside the workspace)")
+	flag.StringVar(&args.version, "Version", "", "Semantic version for the build (Default = from git, or the time)")
+	flag.StringVar(&args.axi, "AXI", "", "Write an include with version constants, relative to the workspace")
 	flag.BoolVar(&args.archive, "A", false, "Produce Archive Package")
 	flag.BoolVar(&args.handover, "H", false, "Produce Handover Package")
 	flag.BoolVar(&args.release, "R", false, "Produce Release Package")
+	flag.BoolVar(&args.batch, "Batch", false, "Never ask, using All for any project or system not set")
 
 	flag.Parse()
 
 	// Process any command line stuff
+	c := Load(args.config)
 	if args.path != "" {
 		c.RepoRootPath = args.path
 	}
-	if args.project == "All" {
-		p = -1
-		s = -1
+	if c.RepoRootPath == "" {
+		c.RepoRootPath = "."
 	}
-	if args.system == "All" {
-		s = -1
+	var types []packType
+	if args.archive {
+		types = append(types, ptArchive)
 	}
-	if !args.archive && !args.handover && !args.release {
-		fmt.Println("No package type set - use -A|H|R")
-		os.Exit(3)
+	if args.handover {
+		types = append(types, ptHandover)
+	}
+	if args.release {
+		types = append(types, ptRelease)
+	}
+	if len(types) == 0 && args.axi == "" {
+		println("No package type set - use -A|H|R or -AXI")
+		os.Exit(1)
 	}
-
-	// Debug the argumentgs
-	fmt.Println("Command Line args ", args)
 
 	// Get AMX Projects
-	apwInfos := apwfile.FindInDir(c.RepoRootPath)
-
-	// Check for command line workspace match
-	for index, apwInfo := range apwInfos {
-		if apwInfo.Identifier == args.workspace {
-			// Set this as the working workspace
-			w = index
-			// Exit Loop
-			break
-		}
-	}
+	apws := apw.FindAPWs(c.RepoRootPath, true)
 
-	// Output workspaces for user input
-	if w == -1 {
-		for index, apwInfo := range apwInfos {
-			fmt.Printf("%02d", index)
-			fmt.Println(": ", apwInfo.Identifier)
-		}
-		// Prompt User
-		w = cli.AskForItemIndex("Select Workspace:", false)
-		fmt.Println("Selected: ", apwInfos[w].Name)
-	}
-
-	// Load up the requested workspace
-	apwfile.LoadWorkspace(&apwInfos[w])
-
-	// Check for command line project match
-	if args.project != "" {
-		for index, project := range apwInfos[w].XML.Projects {
-			if project.Identifier == args.project {
-				// Set this as the working workspace
-				p = index
-				// Exit Loop
-				break
-			}
-		}
-	}
-	// Check for command line system match
-	if p > -1 && args.system != "" {
-		for index, system := range apwInfos[w].XML.Projects[p].Systems {
-			if system.Identifier == args.system {
-				// Set this as the working workspace
-				s = index
-				// Exit Loop
-				break
-			}
-		}
+	// Select what to pack, asking where flags weren't given
+	p := newPicker(os.Stdin, os.Stdout, args.batch)
+	a, err := p.selectWorkspace(apws, args.workspace)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
 	}
+	log.Println("Selected: " + a.Filename)
 
-	// Prompt for a project if not set
-	if p == -2 {
-		for index, project := range apwInfos[w].XML.Projects {
-			fmt.Printf("%02d", index)
-			fmt.Println(": " + project.Identifier)
-		}
-		p = cli.AskForItemIndex("Select Project (or a for All):", true)
+	sel, err := p.selectSystems(a.Workspace, args.project, args.system)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
 	}
 
-	// Process project
-	if p > -1 {
-		// Swap array for new array with a single project
-		apwInfos[w].XML.Projects = []workspace.Project{apwInfos[w].XML.Projects[p]}
+	// Work out the version from the workspace's repository
+	v, err := version.Detect(a.OriginPath, args.version)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
 	}
+	log.Println("Version: " + v.String())
 
-	// If one project has been selected, prompt for system
-	if p > -1 && s == -2 {
-
-		for index, system := range apwInfos[w].XML.Projects[p].Systems {
-			fmt.Printf("%02d", index)
-			fmt.Println(": " + system.Identifier)
+	if args.axi != "" {
+		fn := args.axi
+		if !filepath.IsAbs(fn) {
+			fn = filepath.Join(a.OriginPath, fn)
 		}
-		s = cli.AskForItemIndex("Select System (or a for All):", true)
-		if s > -1 {
-			apwInfos[w].XML.Projects[p].Systems = []workspace.System{apwInfos[w].XML.Projects[p].Systems[s]}
+		if err := ioutil.WriteFile(fn, v.AXI(axiPrefix), 0644); err != nil {
+			println(err.Error())
+			os.Exit(1)
 		}
+		log.Println("Wrote " + fn)
 	}
 
-	// Process an Archive Package
-	if args.archive {
-		packItUp(apwInfos[w], ptArchive, buildID)
+	dest := args.dest
+	if dest == "" {
+		dest = filepath.Join(a.OriginPath, "PackUp")
 	}
 
-	// Process a Handover Package
-	if args.handover {
-		packItUp(apwInfos[w], ptHandover, buildID)
+	// Pack each requested type from a fresh copy of the workspace
+	failed := false
+	for _, pt := range types {
+		log.Println("Packing " + pt.String())
+		fn, err := packItUp(a.Filename, sel, pt, dest, v)
+		if err != nil {
+			log.Println("Failed " + pt.String() + ": " + err.Error())
+			failed = true
+			continue
+		}
+		log.Println("Completed " + pt.String() + ": " + fn)
 	}
 
-	// Process a Release Package
-	if args.release {
-		packItUp(apwInfos[w], ptRelease, buildID)
+	if failed {
+		os.Exit(1)
 	}
-
 	log.Println("Done!")
 }
 
+// axiPrefix starts the name of each constant in the version include
+const axiPrefix = "BUILD_"
+
 type packType int
 
 const (
@@ -172,55 +145,77 @@ const (
 	ptRelease
 )
 
-func packItUp(apw apwfile.APWInfo, pt packType, buildID string) {
+var packTypes = [...]string{
+	"Archive",
+	"Handover",
+	"Release",
+}
 
-	// Ammend the BuildID with the type of packaging being done
-	switch pt {
-	case ptArchive:
-		log.Println("Packing Archive")
-		buildID = buildID + "A"
-	case ptHandover:
-		log.Println("Packing Handover")
-		buildID = buildID + "H"
-	case ptRelease:
-		log.Println("Packing Release")
-		buildID = buildID + "R"
+func (pt packType) String() string {
+	return packTypes[pt]
+}
+
+// packItUp loads the workspace, keeps the selected projects and systems,
+// converts it for the package type and zips it into dest with a manifest,
+// stamping the version into the name and workspace comments. The name of
+// the zip is returned
+func packItUp(apwFile string, sel selection, pt packType, dest string, v version.Version) (string, error) {
+
+	a, err := apw.LoadAPW(apwFile)
+	if err != nil {
+		return "", err
 	}
+	sel.apply(a.Workspace)
 
-	//log.Println(apw.XML)
+	// Only archives have to have every source file
+	problems := a.Validate(pt == ptArchive)
+	for _, p := range problems {
+		log.Println(p.String())
+	}
+	if apw.HasErrors(problems) {
+		return "", errors.New("workspace has errors")
+	}
 
-	// ADjust the XML to reflect the package type
+	// Adjust the XML to reflect the package type
 	switch pt {
 	case ptHandover:
-		workspace.ConvertToHandover(&apw.XML)
+		a.Workspace.ConvertToHandover()
 	case ptRelease:
-		workspace.ConvertToRelease(&apw.XML)
+		a.Workspace.ConvertToRelease()
 	}
 
-	// Populate the Files referece
-	apwfile.GetFiles(&apw)
-
-	// Adjust the XML to relative paths
-	workspace.SetRelativePaths(&apw.XML)
+	// Populate the Files reference for what's left
+	if err := a.RefreshFiles(); err != nil {
+		return "", err
+	}
+	if len(a.FilesMissing) > 0 {
+		return "", errors.New("missing files: " + strings.Join(a.FilesMissing, ", "))
+	}
 
-	// get the full path of a release folder
-	destDir := filepath.Join(apw.OriginPath, "PackUp")
-	// Create it ignoring any errors (such as it existing already)
-	_ = os.Mkdir(destDir, os.ModePerm)
+	// Adjust the XML to relative paths and record the version
+	a.Workspace.SetRelativeFilepaths()
+	a.Workspace.Comments = v.StampComments(a.Workspace.Comments)
 
-	// Zip it up!
-	err := apwfile.Zip(&apw, destDir, buildID)
+	kind := strings.ToLower(pt.String())
+	files, err := a.ArchiveFiles()
 	if err != nil {
-
+		return "", err
 	}
+	m, err := v.Manifest(a.Identifier, kind, files).JSON()
+	if err != nil {
+		return "", err
+	}
+	a.AddArchiveFile(version.ManifestName, m)
 
-	// Announce Finish
-	switch pt {
-	case ptArchive:
-		log.Println("Completed Archive")
-	case ptHandover:
-		log.Println("Completed Handover")
-	case ptRelease:
-		log.Println("Completed Release")
+	// Ammend the BuildID with the type of packaging being done
+	buildID := v.BuildID() + "_" + kind
+
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := a.ExportArchive(dest, buildID); err != nil {
+		return "", err
 	}
+
+	return filepath.Join(dest, a.Identifier+"_"+buildID+".zip"), nil
 }
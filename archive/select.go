@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// all is returned by choose when every item is selected
+const all = -1
+
+// picker chooses between workspaces, projects and systems, asking the user
+// for any not given on the command line unless in batch mode
+type picker struct {
+	in    *bufio.Reader
+	out   io.Writer
+	batch bool
+}
+
+// newPicker returns a picker reading answers from in
+func newPicker(in io.Reader, out io.Writer, batch bool) *picker {
+	return &picker{in: bufio.NewReader(in), out: out, batch: batch}
+}
+
+// choose returns the index of the item named name, ignoring case. A name
+// of All selects every item where allowed. With no name a single item is
+// chosen without asking, otherwise the user is asked, or in batch mode all
+// are chosen where allowed
+func (p *picker) choose(kind string, items []string, name string, allowAll bool) (int, error) {
+
+	if len(items) == 0 {
+		return 0, errors.New("no " + strings.ToLower(kind) + "s found")
+	}
+
+	if name != "" {
+		if allowAll && strings.EqualFold(name, "All") {
+			return all, nil
+		}
+		for i, item := range items {
+			if strings.EqualFold(item, name) {
+				return i, nil
+			}
+		}
+		return 0, errors.New("no " + strings.ToLower(kind) + " named " + name + ", found: " + strings.Join(items, ", "))
+	}
+
+	if len(items) == 1 {
+		return 0, nil
+	}
+	if p.batch {
+		if allowAll {
+			return all, nil
+		}
+		return 0, errors.New("more than one " + strings.ToLower(kind) + " found, choose one with -" + kind)
+	}
+
+	return p.ask(kind, items, allowAll)
+}
+
+// ask lists the items and reads a choice until a valid one is given
+func (p *picker) ask(kind string, items []string, allowAll bool) (int, error) {
+
+	for i, item := range items {
+		fmt.Fprintf(p.out, "%02d: %s\n", i, item)
+	}
+
+	prompt := "Select " + kind + ": "
+	if allowAll {
+		prompt = "Select " + kind + " (or a for All): "
+	}
+
+	for {
+		io.WriteString(p.out, prompt)
+		line, err := p.in.ReadString('\n')
+		line = strings.TrimSpace(line)
+
+		if allowAll && strings.EqualFold(line, "a") {
+			return all, nil
+		}
+		if i, aerr := strconv.Atoi(line); aerr == nil && i >= 0 && i < len(items) {
+			return i, nil
+		}
+		if err != nil {
+			return 0, errors.New("no " + strings.ToLower(kind) + " selected")
+		}
+		io.WriteString(p.out, "Invalid selection\n")
+	}
+}
+
+// selection is the project and system to package, where empty means all
+type selection struct {
+	Project string
+	System  string
+}
+
+// selectWorkspace chooses one of the workspaces by its file or workspace
+// Identifier
+func (p *picker) selectWorkspace(apws []*apw.APW, name string) (*apw.APW, error) {
+
+	var items []string
+	for _, a := range apws {
+		items = append(items, a.Identifier)
+	}
+
+	// Also allow the Identifier inside the workspace
+	if name != "" && !strings.EqualFold(name, "All") {
+		for _, a := range apws {
+			if a.Workspace != nil && strings.EqualFold(a.Workspace.Identifier, name) {
+				return a, nil
+			}
+		}
+	}
+
+	i, err := p.choose("Workspace", items, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return apws[i], nil
+}
+
+// selectSystems chooses the project and, if a single project is chosen,
+// the system to package from the workspace
+func (p *picker) selectSystems(w *apw.Workspace, project string, system string) (selection, error) {
+
+	var sel selection
+
+	// A system name alone picks its project
+	if project == "" && system != "" && !strings.EqualFold(system, "All") {
+		for _, pr := range w.Projects {
+			for _, s := range pr.Systems {
+				if strings.EqualFold(s.Identifier, system) {
+					project = pr.Identifier
+				}
+			}
+		}
+	}
+
+	var items []string
+	for _, pr := range w.Projects {
+		items = append(items, pr.Identifier)
+	}
+	pi, err := p.choose("Project", items, project, true)
+	if err != nil || pi == all {
+		return sel, err
+	}
+	sel.Project = w.Projects[pi].Identifier
+
+	items = nil
+	for _, s := range w.Projects[pi].Systems {
+		items = append(items, s.Identifier)
+	}
+	si, err := p.choose("System", items, system, true)
+	if err != nil || si == all {
+		return sel, err
+	}
+	sel.System = w.Projects[pi].Systems[si].Identifier
+
+	return sel, nil
+}
+
+// apply removes the projects and systems not selected from the workspace
+func (sel selection) apply(w *apw.Workspace) {
+	if sel.Project == "" {
+		return
+	}
+	p := w.FindProject(sel.Project)
+	if p == nil {
+		w.Projects = nil
+		return
+	}
+	w.Projects = []*apw.Project{p}
+
+	if sel.System == "" {
+		return
+	}
+	s := p.FindSystem(sel.System)
+	if s == nil {
+		p.Systems = nil
+		return
+	}
+	p.Systems = []*apw.System{s}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// testWorkspace has two projects, the first with two systems
+func testWorkspace() *apw.Workspace {
+	return &apw.Workspace{
+		Identifier: "Site",
+		Projects: []*apw.Project{
+			{Identifier: "Boardroom", Systems: []*apw.System{{Identifier: "Main"}, {Identifier: "Touch"}}},
+			{Identifier: "Lobby", Systems: []*apw.System{{Identifier: "Signage"}}},
+		},
+	}
+}
+
+func TestSelectSystemsFlags(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		project string
+		system  string
+		batch   bool
+		want    selection
+		err     string
+	}{
+		{name: "project and system", project: "boardroom", system: "TOUCH", want: selection{"Boardroom", "Touch"}},
+		{name: "system picks project", system: "signage", want: selection{"Lobby", "Signage"}},
+		{name: "single system chosen", project: "Lobby", want: selection{"Lobby", "Signage"}},
+		{name: "all projects", project: "All", want: selection{}},
+		{name: "all systems", project: "Boardroom", system: "all", want: selection{Project: "Boardroom"}},
+		{name: "batch takes all projects", batch: true, want: selection{}},
+		{name: "batch takes all systems", project: "Boardroom", batch: true, want: selection{Project: "Boardroom"}},
+		{name: "unknown project", project: "Kitchen", err: "no project named Kitchen, found: Boardroom, Lobby"},
+		{name: "unknown system", project: "Lobby", system: "Main", err: "no system named Main, found: Signage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := newPicker(strings.NewReader(""), &out, tt.batch)
+			sel, err := p.selectSystems(testWorkspace(), tt.project, tt.system)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sel != tt.want {
+				t.Errorf("selection = %+v, want %+v", sel, tt.want)
+			}
+			if out.Len() != 0 {
+				t.Errorf("asked with flags given:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestSelectSystemsInteractive(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		input string
+		want  selection
+		err   string
+	}{
+		{name: "project then system", input: "00\n1\n", want: selection{"Boardroom", "Touch"}},
+		{name: "all projects", input: "a\n", want: selection{}},
+		{name: "all systems", input: "0\nA\n", want: selection{Project: "Boardroom"}},
+		{name: "single system not asked", input: "1\n", want: selection{"Lobby", "Signage"}},
+		{name: "invalid then valid", input: "7\nLobby\n1\n", want: selection{"Lobby", "Signage"}},
+		{name: "last line without newline", input: "0\n0", want: selection{"Boardroom", "Main"}},
+		{name: "input ends", input: "9\n", err: "no project selected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := newPicker(strings.NewReader(tt.input), &out, false)
+			sel, err := p.selectSystems(testWorkspace(), "", "")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v\n%s", err, out.String())
+			}
+			if sel != tt.want {
+				t.Errorf("selection = %+v, want %+v\n%s", sel, tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestAskOutput(t *testing.T) {
+
+	var out bytes.Buffer
+	p := newPicker(strings.NewReader("x\n1\n"), &out, false)
+	i, err := p.choose("Project", []string{"Boardroom", "Lobby"}, "", true)
+	if err != nil || i != 1 {
+		t.Fatalf("choose = %d, %v, want 1", i, err)
+	}
+
+	want := "00: Boardroom\n01: Lobby\n" +
+		"Select Project (or a for All): Invalid selection\n" +
+		"Select Project (or a for All): "
+	if out.String() != want {
+		t.Errorf("output =\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestSelectWorkspace(t *testing.T) {
+
+	apws := []*apw.APW{
+		{Identifier: "site.apw", Workspace: &apw.Workspace{Identifier: "Site"}},
+		{Identifier: "spare.apw", Workspace: &apw.Workspace{Identifier: "Spare"}},
+	}
+
+	p := newPicker(strings.NewReader(""), &bytes.Buffer{}, true)
+	if a, err := p.selectWorkspace(apws, "spare"); err != nil || a != apws[1] {
+		t.Errorf("by workspace Identifier = %v, %v", a, err)
+	}
+	if a, err := p.selectWorkspace(apws, "SITE.APW"); err != nil || a != apws[0] {
+		t.Errorf("by file = %v, %v", a, err)
+	}
+	if _, err := p.selectWorkspace(apws, ""); err == nil || !strings.Contains(err.Error(), "-Workspace") {
+		t.Errorf("batch with two workspaces = %v, want an error naming -Workspace", err)
+	}
+	if _, err := p.selectWorkspace(nil, ""); err == nil {
+		t.Error("no workspaces didn't fail")
+	}
+
+	p = newPicker(strings.NewReader("1\n"), &bytes.Buffer{}, false)
+	if a, err := p.selectWorkspace(apws, ""); err != nil || a != apws[1] {
+		t.Errorf("asked = %v, %v", a, err)
+	}
+}
+
+func TestSelectionApply(t *testing.T) {
+
+	w := testWorkspace()
+	selection{"Boardroom", "Touch"}.apply(w)
+	if len(w.Projects) != 1 || w.Projects[0].Identifier != "Boardroom" ||
+		len(w.Projects[0].Systems) != 1 || w.Projects[0].Systems[0].Identifier != "Touch" {
+		t.Errorf("apply left %+v", w.Projects)
+	}
+
+	w = testWorkspace()
+	selection{}.apply(w)
+	if len(w.Projects) != 2 {
+		t.Errorf("empty selection removed projects")
+	}
+}
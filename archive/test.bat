@@ -1 +1 @@
-go run packup.go -Workspace 1040_LendLease -H -R
\ No newline at end of file
+go run . -Workspace 1040_LendLease -Project All -H -R
@@ -4,15 +4,28 @@ import (
 	"flag"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/soloworks/go-netlinx/apw"
 	"github.com/soloworks/go-netlinx/compilecfg"
 )
 
 type myargs struct {
-	Source string
-	Dest   string
-	Root   string
+	Source       string
+	Dest         string
+	Root         string
+	LogFile      string
+	LogAppend    bool
+	LogConsole   bool
+	Debug        bool
+	WithSource   bool
+	WC           bool
+	SourcesFirst bool
+	Workspace    bool
+	Scope        string
+	Project      string
+	System       string
+	Split        bool
 }
 
 var args myargs
@@ -22,24 +35,71 @@ func main() {
 	flag.StringVar(&args.Source, "Source", "", "Source APW File")
 	flag.StringVar(&args.Dest, "Dest", "compile.cfg", "Destination CFG File")
 	flag.StringVar(&args.Root, "Root", ".", "Root Directory")
+	flag.StringVar(&args.LogFile, "LogFile", "", "Compiler Log File (relative to Root)")
+	flag.BoolVar(&args.LogAppend, "LogAppend", false, "Append to an existing Log File")
+	flag.BoolVar(&args.LogConsole, "LogConsole", true, "Log to the Console")
+	flag.BoolVar(&args.Debug, "Debug", true, "Build with Debug Information")
+	flag.BoolVar(&args.WithSource, "WithSource", false, "Build with Source")
+	flag.BoolVar(&args.WC, "WC", true, "Build with WC")
+	flag.BoolVar(&args.SourcesFirst, "SourcesFirst", false, "Compile Sources before Modules")
+	flag.BoolVar(&args.Workspace, "WorkspaceOrder", false, "Keep workspace order rather than sorting")
+	flag.StringVar(&args.Scope, "Scope", "workspace", "Group files by workspace|project|system")
+	flag.StringVar(&args.Project, "Project", "", "Only include this Project")
+	flag.StringVar(&args.System, "System", "", "Only include this System")
+	flag.BoolVar(&args.Split, "Split", false, "Write one CFG File per System into the Dest folder")
 	flag.Parse()
 
 	// Load in the core APW file
 	a, err := apw.LoadAPW(args.Source)
 	if err != nil {
 		println(`Error Loading APW File: "` + args.Source + `"`)
-		println(err)
+		println(err.Error())
 		os.Exit(1)
 	}
 
+	// Set the options from the command line
+	opts := compilecfg.DefaultOptions()
+	opts.Root = args.Root
+	opts.LogFile = args.LogFile
+	if args.LogAppend {
+		opts.LogFileOption = compilecfg.LogAppend
+	}
+	opts.LogConsole = compilecfg.NewFlag(args.LogConsole)
+	opts.DebugInfo = compilecfg.NewFlag(args.Debug)
+	opts.Source = compilecfg.NewFlag(args.WithSource)
+	opts.WC = compilecfg.NewFlag(args.WC)
+	opts.ModulesFirst = !args.SourcesFirst
+	if args.Workspace {
+		opts.Order = compilecfg.OrderWorkspace
+	}
+	switch args.Scope {
+	case "project":
+		opts.Scope = compilecfg.ScopeProject
+	case "system":
+		opts.Scope = compilecfg.ScopeSystem
+	}
+	opts.Project = args.Project
+	opts.System = args.System
+
+	// Process and generate a .cfg per system
+	if args.Split {
+		os.MkdirAll(args.Dest, os.ModePerm)
+		for _, sc := range compilecfg.SystemConfigs(*a, opts) {
+			writeCfg(filepath.Join(args.Dest, sc.Filename), sc.Config.Bytes())
+		}
+		return
+	}
+
 	// Process and generate the .cfg
-	b := compilecfg.Generate(*a, args.Root, "", true)
+	writeCfg(args.Dest, compilecfg.Generate(*a, opts))
+}
 
+func writeCfg(fn string, b []byte) {
 	// Output to File
-	err = ioutil.WriteFile(args.Dest, b, 0644)
+	err := ioutil.WriteFile(fn, b, 0644)
 	if err != nil {
-		println(`Error Writing CFG File: "` + args.Dest + `"`)
-		println(err)
+		println(`Error Writing CFG File: "` + fn + `"`)
+		println(err.Error())
 		os.Exit(1)
 	}
 }
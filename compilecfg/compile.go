@@ -3,6 +3,7 @@ package compilecfg
 import (
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/soloworks/go-netlinx/apw"
@@ -16,119 +17,379 @@ func toLinux(x string) string {
 	return strings.Join(strings.Split(x, `\`), `/`)
 }
 
-// Generate creates a Netlinx Compiler .cfg file from a workspace
-func Generate(a apw.APW, root string, logfile string, logconsole bool) []byte {
-
-	// Create an empty list for modules
-	var Modules []string
-	var Source []string
-	IncludePath := make(map[string]struct{})
-	ModulePath := make(map[string]struct{})
-
-	// Extract list of .axs Modules and .axs Source
-	for x, y := range a.FilesReferenced {
-		switch filepath.Ext(x) {
-
-		case ".tkn", ".jar":
-			switch y {
-			case "Module", "DUET":
-				x = toLinux(x)
-				ip := filepath.Dir(x)
-				ip = toWindows(ip)
-				ModulePath[ip] = struct{}{}
+// cfgPath returns an example path to a .cfg file within root
+func cfgPath(root string) string {
+	if root == "" {
+		return "filename.cfg"
+	}
+	return root + `\filename.cfg`
+}
+
+// Order specifies how files and paths are ordered in a generated Config
+type Order int
+
+// Order values for use outside this module
+const (
+	OrderSorted Order = iota
+	OrderWorkspace
+)
+
+// Scope specifies how files are grouped in a generated Config
+type Scope int
+
+// Scope values for use outside this module
+const (
+	ScopeWorkspace Scope = iota
+	ScopeProject
+	ScopeSystem
+)
+
+// Options controls the content of a generated Config
+type Options struct {
+	Root          string
+	LogFile       string
+	LogFileOption LogOption
+	LogConsole    Flag
+	DebugInfo     Flag
+	Source        Flag
+	WC            Flag
+	ModulesFirst  bool
+	Order         Order
+	Scope         Scope
+	Project       string
+	System        string
+	IncludePaths  []string
+	ModulePaths   []string
+	LibraryPaths  []string
+}
+
+// DefaultOptions returns the Options used by Netlinx Studio for a full
+// build, with modules compiled before the main source files
+func DefaultOptions() Options {
+	return Options{
+		LogFileOption: LogNew,
+		LogConsole:    FlagYes,
+		DebugInfo:     FlagYes,
+		Source:        FlagNo,
+		WC:            FlagYes,
+		ModulesFirst:  true,
+	}
+}
+
+// workspaceFile is a file path and type taken from a workspace
+type workspaceFile struct {
+	path string
+	t    string
+}
+
+// group is a named set of files compiled together
+type group struct {
+	name  string
+	files []workspaceFile
+}
+
+// isAbs reports whether a workspace path is absolute, treating Windows drive
+// letter and UNC paths as absolute whatever this machine is
+func isAbs(p string) bool {
+	if len(p) > 2 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') {
+		return true
+	}
+	return strings.HasPrefix(p, `\\`) || filepath.IsAbs(p)
+}
+
+// systemFiles returns the absolute path and type of each file in a system
+func systemFiles(a apw.APW, s *apw.System) []workspaceFile {
+	var files []workspaceFile
+	for _, f := range s.Files {
+		fn := f.FilePathName
+		if !isAbs(fn) {
+			// Workspaces store Windows separators, so convert as apw does
+			fn = filepath.Join(a.OriginPath, filepath.FromSlash(toLinux(fn)))
+		}
+		files = append(files, workspaceFile{fn, f.Type})
+	}
+	return files
+}
+
+// orderFiles removes duplicates and sorts the files if requested
+func orderFiles(files []workspaceFile, o Order) []workspaceFile {
+	var out []workspaceFile
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if !seen[f.path] {
+			seen[f.path] = true
+			out = append(out, f)
+		}
+	}
+	if o == OrderSorted {
+		sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	}
+	return out
+}
+
+// groups returns the selected files of the workspace grouped by scope
+func groups(a apw.APW, opts Options) []group {
+
+	var gs []group
+
+	for _, p := range a.Workspace.Projects {
+		if opts.Project != "" && p.Identifier != opts.Project {
+			continue
+		}
+		for _, s := range p.Systems {
+			if opts.System != "" && s.Identifier != opts.System {
+				continue
 			}
-		case ".axs", ".axi":
-			switch y {
-			case "Module":
-				Modules = append(Modules, x)
-			case "Source", "MasterSrc":
-				Source = append(Source, x)
-			case "Include":
-				x = toLinux(x)
-				ip := filepath.Dir(x)
-				ip = toWindows(ip)
-				IncludePath[ip] = struct{}{}
+			files := systemFiles(a, s)
+			switch opts.Scope {
+			case ScopeSystem:
+				gs = append(gs, group{p.Identifier + " - " + s.Identifier, files})
+			case ScopeProject:
+				if len(gs) == 0 || gs[len(gs)-1].name != p.Identifier {
+					gs = append(gs, group{name: p.Identifier})
+				}
+				gs[len(gs)-1].files = append(gs[len(gs)-1].files, files...)
+			default:
+				if len(gs) == 0 {
+					gs = append(gs, group{})
+				}
+				gs[0].files = append(gs[0].files, files...)
 			}
 		}
 	}
 
-	// Order the lists
-	sort.Strings(Modules)
-	sort.Strings(Source)
+	for i := range gs {
+		gs[i].files = orderFiles(gs[i].files, opts.Order)
+	}
+
+	return gs
+}
+
+// appendUnique adds s to list if not already present
+func appendUnique(list []string, s ...string) []string {
+	for _, y := range s {
+		found := false
+		for _, x := range list {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, y)
+		}
+	}
+	return list
+}
+
+// fileSet holds the files to compile and the search paths for a group
+type fileSet struct {
+	modules      []string
+	sources      []string
+	includePaths []string
+	modulePaths  []string
+	libraryPaths []string
+}
+
+// add sorts a workspace file into the set based on extension and type,
+// ignoring case as Netlinx Studio isn't consistent (i.e. Duet vs DUET)
+func (fs *fileSet) add(wf workspaceFile) {
+
+	x := toWindows(wf.path)
+	dir := toWindows(filepath.Dir(toLinux(x)))
+
+	switch strings.ToLower(filepath.Ext(wf.path)) {
+	case ".axs":
+		switch strings.ToUpper(wf.t) {
+		case "MODULE":
+			// Compiled alongside, so the .tko is found from here too
+			fs.modules = append(fs.modules, x)
+			fs.modulePaths = appendUnique(fs.modulePaths, dir)
+		case "SOURCE", "MASTERSRC":
+			fs.sources = append(fs.sources, x)
+		}
+	case ".axi":
+		switch strings.ToUpper(wf.t) {
+		case "INCLUDE":
+			fs.includePaths = appendUnique(fs.includePaths, dir)
+		}
+	case ".tko", ".tkn":
+		switch strings.ToUpper(wf.t) {
+		case "MODULE", "TKO":
+			fs.modulePaths = appendUnique(fs.modulePaths, dir)
+		}
+	case ".jar":
+		switch strings.ToUpper(wf.t) {
+		case "MODULE", "DUET":
+			fs.modulePaths = appendUnique(fs.modulePaths, dir)
+		}
+	case ".lib":
+		fs.libraryPaths = appendUnique(fs.libraryPaths, dir)
+	}
+}
+
+// axsFiles returns the files to compile in the requested order
+func (fs *fileSet) axsFiles(modulesFirst bool) []string {
+	if modulesFirst {
+		return append(append([]string{}, fs.modules...), fs.sources...)
+	}
+	return append(append([]string{}, fs.sources...), fs.modules...)
+}
+
+// NewConfig builds a Netlinx Compiler Config from a workspace. With a
+// Project or System Scope, the files are written in one section per group
+func NewConfig(a apw.APW, opts Options) *Config {
 
 	// Fix an Unix folders to Windows
-	root = toWindows(root)
+	root := toWindows(opts.Root)
 
 	// Build the Config File Header & Options
-	var sb strings.Builder
-	sb.WriteString(";------------------------------------------------------------------------------\n")
-	sb.WriteString(";\n")
-	sb.WriteString("; Netlinx Compiler Config File generated by Go\n")
-	sb.WriteString("; Source: http://github.org/soloworks/go-netlinx/compiler\n")
-	sb.WriteString(`; Run> NLRC -C"`)
-	if root != "" {
-		sb.WriteString(root)
-		sb.WriteString(`\`)
-	}
-	sb.WriteString(`filename.cfg"`)
-	sb.WriteString(";\n")
-	sb.WriteString(";------------------------------------------------------------------------------\n\n")
+	c := &Config{
+		Comment: []string{
+			"------------------------------------------------------------------------------",
+			"",
+			" Netlinx Compiler Config File generated by Go",
+			" Source: http://github.org/soloworks/go-netlinx/compiler",
+			` Run> NLRC -C"` + cfgPath(root) + `"`,
+			"",
+			"------------------------------------------------------------------------------",
+		},
+		MainAXSRootDirectory: root,
+		OutputLogConsole:     opts.LogConsole,
+		BuildWithDebugInfo:   opts.DebugInfo,
+		BuildWithSource:      opts.Source,
+		BuildWithWC:          opts.WC,
+	}
 
 	// Write out Root Directory
 	if root == "" {
-		sb.WriteString("MainAXSRootDirectory=-R\n\n")
-	} else {
-		sb.WriteString("MainAXSRootDirectory=")
-		sb.WriteString(root)
-		sb.WriteString("\n\n")
-	}
-	if logfile != "" {
-		sb.WriteString("OutputLogFileOption=N\n")
-		sb.WriteString("OutputLogFile=")
-		sb.WriteString(root)
-		sb.WriteString("\\")
-		sb.WriteString(logfile)
-		sb.WriteString("\n")
-	}
-	if logconsole {
-		sb.WriteString("OutputLogConsoleOption=Y\n")
-	} else {
-		sb.WriteString("OutputLogConsoleOption=N\n")
-	}
-	sb.WriteString("BuildWithDebugInformation=Y\n")
-	sb.WriteString("BuildWithSource=N\n")
-	sb.WriteString("BuildWithWC=Y\n\n")
-
-	// Add the additional Include paths
-	for x := range IncludePath {
-		sb.WriteString("AdditionalIncludePath=")
-		sb.WriteString(x)
-		sb.WriteString("\n")
-	}
-
-	// Add the additional Module paths
-	for x := range ModulePath {
-		sb.WriteString("AdditionalModulePath=")
-		sb.WriteString(x)
-		sb.WriteString("\n")
-	}
-
-	sb.WriteString("\n")
-	// Add the Modules
-	for _, x := range Modules {
-		sb.WriteString("AXSFILE=")
-		sb.WriteString(x)
-		sb.WriteString("\n")
-	}
-
-	sb.WriteString("\n")
-
-	// Add the Source
-	for _, x := range Source {
-		sb.WriteString("AXSFILE=")
-		sb.WriteString(x)
-		sb.WriteString("\n")
-	}
-	// Return the file as bytes
-	return []byte(sb.String())
+		c.MainAXSRootDirectory = RelativeRoot
+	}
+	if opts.LogFile != "" {
+		c.OutputLogFileOption = opts.LogFileOption
+		c.OutputLogFile = toWindows(opts.LogFile)
+		if root != "" {
+			c.OutputLogFile = root + `\` + c.OutputLogFile
+		}
+	}
+
+	// Gather the files and paths for each group
+	var paths fileSet
+	for _, g := range groups(a, opts) {
+		var fs fileSet
+		for _, wf := range g.files {
+			fs.add(wf)
+		}
+		paths.includePaths = appendUnique(paths.includePaths, fs.includePaths...)
+		paths.modulePaths = appendUnique(paths.modulePaths, fs.modulePaths...)
+		paths.libraryPaths = appendUnique(paths.libraryPaths, fs.libraryPaths...)
+
+		if opts.Scope == ScopeWorkspace {
+			c.AXSFiles = fs.axsFiles(opts.ModulesFirst)
+		} else {
+			c.Sections = append(c.Sections, Section{Name: g.name, AXSFiles: fs.axsFiles(opts.ModulesFirst)})
+		}
+	}
+
+	// Add any extra paths requested
+	for _, p := range opts.IncludePaths {
+		paths.includePaths = appendUnique(paths.includePaths, toWindows(p))
+	}
+	for _, p := range opts.ModulePaths {
+		paths.modulePaths = appendUnique(paths.modulePaths, toWindows(p))
+	}
+	for _, p := range opts.LibraryPaths {
+		paths.libraryPaths = appendUnique(paths.libraryPaths, toWindows(p))
+	}
+
+	// Sort the paths too unless workspace order was requested
+	if opts.Order == OrderSorted {
+		sort.Strings(paths.includePaths)
+		sort.Strings(paths.modulePaths)
+		sort.Strings(paths.libraryPaths)
+	}
+	c.AdditionalIncludePath = paths.includePaths
+	c.AdditionalModulePath = paths.modulePaths
+	c.AdditionalLibraryPath = paths.libraryPaths
+
+	return c
+}
+
+// Generate creates a Netlinx Compiler .cfg file from a workspace
+func Generate(a apw.APW, opts Options) []byte {
+	return NewConfig(a, opts).Bytes()
+}
+
+// SystemConfig is a Config covering a single system of a workspace
+type SystemConfig struct {
+	Project  string
+	System   string
+	Filename string
+	Config   *Config
+}
+
+// cfgName builds a filesystem safe name for a project and system
+func cfgName(project string, system string) string {
+	var sb strings.Builder
+	under := false
+	for _, r := range project + "_" + system {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			sb.WriteRune(r)
+			under = false
+		default:
+			if !under {
+				sb.WriteRune('_')
+			}
+			under = true
+		}
+	}
+	return strings.Trim(sb.String(), "_")
+}
+
+// SystemConfigs returns a separate Config for each selected system, each with
+// its own Filename. Where a log file is set, each system logs to its own file
+// named after the system
+func SystemConfigs(a apw.APW, opts Options) []*SystemConfig {
+
+	var scs []*SystemConfig
+	used := make(map[string]bool)
+
+	for _, p := range a.Workspace.Projects {
+		if opts.Project != "" && p.Identifier != opts.Project {
+			continue
+		}
+		for _, s := range p.Systems {
+			if opts.System != "" && s.Identifier != opts.System {
+				continue
+			}
+
+			// Different identifiers can give the same name, such as "A B"
+			// and "A_B", so number any repeats as Windows ignores case
+			name := cfgName(p.Identifier, s.Identifier)
+			for i, base := 2, name; used[strings.ToLower(name)]; i++ {
+				name = base + "_" + strconv.Itoa(i)
+			}
+			used[strings.ToLower(name)] = true
+
+			// Scope the options to just this system
+			o := opts
+			o.Scope = ScopeWorkspace
+			o.Project = p.Identifier
+			o.System = s.Identifier
+			if o.LogFile != "" {
+				ext := filepath.Ext(o.LogFile)
+				o.LogFile = strings.TrimSuffix(o.LogFile, ext) + "_" + name + ext
+			}
+
+			scs = append(scs, &SystemConfig{
+				Project:  p.Identifier,
+				System:   s.Identifier,
+				Filename: name + ".cfg",
+				Config:   NewConfig(a, o),
+			})
+		}
+	}
+
+	return scs
 }
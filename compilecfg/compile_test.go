@@ -0,0 +1,333 @@
+package compilecfg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// testAPW has two projects, with files given relative to the .apw, by drive
+// letter and of every type that adds a search path
+const testAPW = `<?xml version="1.0" encoding="utf-8"?>
+<Workspace CurrentVersion="4.0"><Identifier>Site</Identifier><CreateVersion>4.0</CreateVersion>
+<Project><Identifier>Boardroom</Identifier>
+<System IsActive="true" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>Main</Identifier><SysID>1</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>main</Identifier><FilePathName>Source\main.axs</FilePathName></File>
+<File CompileType="Netlinx" Type="Module"><Identifier>Display</Identifier><FilePathName>Modules\Display.axs</FilePathName></File>
+<File CompileType="Netlinx" Type="Include"><Identifier>util</Identifier><FilePathName>Includes\util.axi</FilePathName></File>
+<File CompileType="None" Type="TKO"><Identifier>Driver</Identifier><FilePathName>Modules\Driver.tko</FilePathName></File>
+<File CompileType="None" Type="DUET"><Identifier>Dvd</Identifier><FilePathName>Duet\Dvd.jar</FilePathName></File>
+<File CompileType="None" Type="Other"><Identifier>manual</Identifier><FilePathName>Docs\manual.jar</FilePathName></File>
+<File CompileType="None" Type="Other"><Identifier>net</Identifier><FilePathName>Lib\net.lib</FilePathName></File>
+<File CompileType="Netlinx" Type="Include"><Identifier>shared</Identifier><FilePathName>C:\AMX\Shared\shared.axi</FilePathName></File>
+</System>
+<System IsActive="false" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>Touch</Identifier><SysID>2</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>x</Identifier><FilePathName>C:\AMX\x.axs</FilePathName></File>
+</System></Project>
+<Project><Identifier>Lobby</Identifier>
+<System IsActive="true" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>Signage</Identifier><SysID>3</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>signage</Identifier><FilePathName>Lobby\signage.axs</FilePathName></File>
+<File CompileType="Netlinx" Type="Include"><Identifier>util</Identifier><FilePathName>Includes\util.axi</FilePathName></File>
+</System></Project></Workspace>
+`
+
+// testWorkspace loads testAPW as if from the current folder, so file paths
+// stay relative
+func testWorkspace(t *testing.T) apw.APW {
+	t.Helper()
+	a, err := apw.NewAPW("Site.apw", []byte(testAPW))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *a
+}
+
+func TestDefaultOptions(t *testing.T) {
+
+	o := DefaultOptions()
+	if o.LogFileOption != LogNew || o.LogConsole != FlagYes || o.DebugInfo != FlagYes ||
+		o.Source != FlagNo || o.WC != FlagYes || !o.ModulesFirst {
+		t.Errorf("DefaultOptions() = %+v", o)
+	}
+	if o.Order != OrderSorted || o.Scope != ScopeWorkspace || o.Project != "" || o.System != "" {
+		t.Errorf("DefaultOptions() selects %+v", o)
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		opts     func(*Options)
+		files    []string
+		sections []Section
+		includes []string
+		modules  []string
+		libs     []string
+	}{
+		{
+			name:     "defaults",
+			files:    []string{`Modules\Display.axs`, `C:\AMX\x.axs`, `Lobby\signage.axs`, `Source\main.axs`},
+			includes: []string{`C:\AMX\Shared`, `Includes`},
+			modules:  []string{`Duet`, `Modules`},
+			libs:     []string{`Lib`},
+		},
+		{
+			name: "workspace order with sources first",
+			opts: func(o *Options) {
+				o.Order = OrderWorkspace
+				o.ModulesFirst = false
+			},
+			files:    []string{`Source\main.axs`, `C:\AMX\x.axs`, `Lobby\signage.axs`, `Modules\Display.axs`},
+			includes: []string{`Includes`, `C:\AMX\Shared`},
+			modules:  []string{`Modules`, `Duet`},
+			libs:     []string{`Lib`},
+		},
+		{
+			name: "project scope",
+			opts: func(o *Options) { o.Scope = ScopeProject },
+			sections: []Section{
+				{Name: "Boardroom", AXSFiles: []string{`Modules\Display.axs`, `C:\AMX\x.axs`, `Source\main.axs`}},
+				{Name: "Lobby", AXSFiles: []string{`Lobby\signage.axs`}},
+			},
+			includes: []string{`C:\AMX\Shared`, `Includes`},
+			modules:  []string{`Duet`, `Modules`},
+			libs:     []string{`Lib`},
+		},
+		{
+			name: "system scope within a project",
+			opts: func(o *Options) {
+				o.Scope = ScopeSystem
+				o.Project = "Boardroom"
+			},
+			sections: []Section{
+				{Name: "Boardroom - Main", AXSFiles: []string{`Modules\Display.axs`, `Source\main.axs`}},
+				{Name: "Boardroom - Touch", AXSFiles: []string{`C:\AMX\x.axs`}},
+			},
+			includes: []string{`C:\AMX\Shared`, `Includes`},
+			modules:  []string{`Duet`, `Modules`},
+			libs:     []string{`Lib`},
+		},
+		{
+			name: "single system with extra paths",
+			opts: func(o *Options) {
+				o.System = "Signage"
+				o.IncludePaths = []string{"Extra/Includes", "Includes"}
+				o.ModulePaths = []string{`D:\Modules`}
+				o.LibraryPaths = []string{"Lib"}
+			},
+			files:    []string{`Lobby\signage.axs`},
+			includes: []string{`Extra\Includes`, `Includes`},
+			modules:  []string{`D:\Modules`},
+			libs:     []string{`Lib`},
+		},
+	}
+
+	a := testWorkspace(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			opts := DefaultOptions()
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+			c := NewConfig(a, opts)
+
+			if !reflect.DeepEqual(c.AXSFiles, tt.files) {
+				t.Errorf("AXSFiles = %q, want %q", c.AXSFiles, tt.files)
+			}
+			if !reflect.DeepEqual(c.Sections, tt.sections) {
+				t.Errorf("Sections = %q, want %q", c.Sections, tt.sections)
+			}
+			if !reflect.DeepEqual(c.AdditionalIncludePath, tt.includes) {
+				t.Errorf("AdditionalIncludePath = %q, want %q", c.AdditionalIncludePath, tt.includes)
+			}
+			if !reflect.DeepEqual(c.AdditionalModulePath, tt.modules) {
+				t.Errorf("AdditionalModulePath = %q, want %q", c.AdditionalModulePath, tt.modules)
+			}
+			if !reflect.DeepEqual(c.AdditionalLibraryPath, tt.libs) {
+				t.Errorf("AdditionalLibraryPath = %q, want %q", c.AdditionalLibraryPath, tt.libs)
+			}
+			if c.MainAXSRootDirectory != RelativeRoot {
+				t.Errorf("MainAXSRootDirectory = %q, want %q", c.MainAXSRootDirectory, RelativeRoot)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	opts := DefaultOptions()
+	opts.Root = "C:/Builds/Site"
+	opts.LogFile = "logs/build.log"
+	opts.LogFileOption = LogAppend
+	opts.Source = FlagYes
+	opts.System = "Main"
+
+	want := `;------------------------------------------------------------------------------
+;
+; Netlinx Compiler Config File generated by Go
+; Source: http://github.org/soloworks/go-netlinx/compiler
+; Run> NLRC -C"C:\Builds\Site\filename.cfg"
+;
+;------------------------------------------------------------------------------
+
+MainAXSRootDirectory=C:\Builds\Site
+
+OutputLogFile=C:\Builds\Site\logs\build.log
+OutputLogFileOption=A
+OutputLogConsoleOption=Y
+BuildWithDebugInformation=Y
+BuildWithSource=Y
+BuildWithWC=Y
+
+AdditionalIncludePath=C:\AMX\Shared
+AdditionalIncludePath=Includes
+
+AdditionalModulePath=Duet
+AdditionalModulePath=Modules
+
+AdditionalLibraryPath=Lib
+
+AXSFile=Modules\Display.axs
+AXSFile=Source\main.axs
+
+`
+	if got := string(Generate(testWorkspace(t), opts)); got != want {
+		t.Errorf("Generate() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSystemFiles(t *testing.T) {
+
+	a, err := apw.NewAPW(filepath.Join("Projects", "Site.apw"), []byte(testAPW))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths are joined to the .apw folder, drive letters are kept
+	s := a.Workspace.Projects[0].Systems[0]
+	files := systemFiles(*a, s)
+	tests := map[string]string{
+		"main":   filepath.Join("Projects", "Source", "main.axs"),
+		"shared": `C:\AMX\Shared\shared.axi`,
+	}
+	for i, f := range s.Files {
+		if want, ok := tests[f.Identifier]; ok && files[i].path != want {
+			t.Errorf("%s = %q, want %q", f.FilePathName, files[i].path, want)
+		}
+	}
+}
+
+func TestSystemConfigs(t *testing.T) {
+
+	xml := `<Workspace CurrentVersion="4.0"><Identifier>Site</Identifier>
+<Project><Identifier>A B</Identifier>
+<System><Identifier>C</Identifier><SysID>1</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>one</Identifier><FilePathName>one.axs</FilePathName></File>
+</System></Project>
+<Project><Identifier>A_B</Identifier>
+<System><Identifier>C</Identifier><SysID>2</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>two</Identifier><FilePathName>two.axs</FilePathName></File>
+</System>
+<System><Identifier>c</Identifier><SysID>3</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>three</Identifier><FilePathName>three.axs</FilePathName></File>
+</System>
+<System><Identifier>C_2</Identifier><SysID>4</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>four</Identifier><FilePathName>four.axs</FilePathName></File>
+</System></Project></Workspace>`
+	a, err := apw.NewAPW("Site.apw", []byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.LogFile = "build.log"
+	opts.Scope = ScopeProject
+
+	tests := []struct {
+		project  string
+		system   string
+		filename string
+		log      string
+		file     string
+	}{
+		{"A B", "C", "A_B_C.cfg", "build_A_B_C.log", "one.axs"},
+		{"A_B", "C", "A_B_C_2.cfg", "build_A_B_C_2.log", "two.axs"},
+		{"A_B", "C_2", "A_B_C_2_2.cfg", "build_A_B_C_2_2.log", "four.axs"},
+		{"A_B", "c", "A_B_c_3.cfg", "build_A_B_c_3.log", "three.axs"},
+	}
+
+	scs := SystemConfigs(*a, opts)
+	if len(scs) != len(tests) {
+		t.Fatalf("%d configs, want %d", len(scs), len(tests))
+	}
+	for i, tt := range tests {
+		sc := scs[i]
+		if sc.Project != tt.project || sc.System != tt.system || sc.Filename != tt.filename {
+			t.Errorf("config %d = %q %q %q, want %q %q %q", i, sc.Project, sc.System, sc.Filename, tt.project, tt.system, tt.filename)
+		}
+		if sc.Config.OutputLogFile != tt.log {
+			t.Errorf("%s OutputLogFile = %q, want %q", sc.Filename, sc.Config.OutputLogFile, tt.log)
+		}
+		// Each holds just its own system, without sections
+		if !reflect.DeepEqual(sc.Config.AXSFiles, []string{tt.file}) || len(sc.Config.Sections) != 0 {
+			t.Errorf("%s AXSFiles = %q, Sections = %q", sc.Filename, sc.Config.AXSFiles, sc.Config.Sections)
+		}
+	}
+
+	// Selecting a system leaves the rest out
+	opts.Project = "A_B"
+	opts.System = "c"
+	if scs := SystemConfigs(*a, opts); len(scs) != 1 || scs[0].Filename != "A_B_c.cfg" {
+		t.Errorf("selected %+v, want A_B_c.cfg", scs)
+	}
+}
+
+func TestSectionOutput(t *testing.T) {
+
+	opts := DefaultOptions()
+	opts.Scope = ScopeSystem
+	opts.Project = "Boardroom"
+	opts.LogConsole = FlagUnset
+	opts.DebugInfo = FlagUnset
+	opts.Source = FlagUnset
+	opts.WC = FlagUnset
+
+	c := NewConfig(testWorkspace(t), opts)
+	c.Comment = nil
+	want := `MainAXSRootDirectory=-R
+
+
+AdditionalIncludePath=C:\AMX\Shared
+AdditionalIncludePath=Includes
+
+AdditionalModulePath=Duet
+AdditionalModulePath=Modules
+
+AdditionalLibraryPath=Lib
+
+; Section: Boardroom - Main
+AXSFile=Modules\Display.axs
+AXSFile=Source\main.axs
+
+; Section: Boardroom - Touch
+AXSFile=C:\AMX\x.axs
+
+`
+	got := string(c.Bytes())
+	if got != want {
+		t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Sections are read back from their comments
+	r, err := ParseConfig([]byte(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Sections, c.Sections) || r.AXSFiles != nil {
+		t.Errorf("read back %q and %q, want %q", r.AXSFiles, r.Sections, c.Sections)
+	}
+}
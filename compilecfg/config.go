@@ -0,0 +1,324 @@
+package compilecfg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// RelativeRoot is the MainAXSRootDirectory value which tells NLRC to use
+// the folder holding the .cfg file
+const RelativeRoot = "-R"
+
+// Flag is a Y/N compiler option, which can be left unset so that the
+// Netlinx Studio setting is used instead
+type Flag int
+
+// Flag values for use outside this module
+const (
+	FlagUnset Flag = iota
+	FlagYes
+	FlagNo
+)
+
+// Flags as written in a .cfg file
+var flags = [...]string{
+	"",
+	"Y",
+	"N",
+}
+
+// String returns the .cfg value of the Flag
+func (f Flag) String() string { return flags[f] }
+
+// NewFlag returns a set Flag from a bool
+func NewFlag(b bool) Flag {
+	if b {
+		return FlagYes
+	}
+	return FlagNo
+}
+
+// LogOption specifies how NLRC treats an existing log file
+type LogOption int
+
+// LogOption values for use outside this module
+const (
+	LogUnset LogOption = iota
+	LogNew
+	LogAppend
+)
+
+// LogOptions as written in a .cfg file
+var logOptions = [...]string{
+	"",
+	"N",
+	"A",
+}
+
+// String returns the .cfg value of the LogOption
+func (l LogOption) String() string { return logOptions[l] }
+
+// sectionPrefix marks a comment line which starts a Section
+const sectionPrefix = " Section: "
+
+// Section is a named group of AXSFile entries, such as a single system
+type Section struct {
+	Name     string
+	AXSFiles []string
+}
+
+// Config represents a Netlinx Compiler (NLRC) configuration file
+type Config struct {
+	Comment               []string
+	MainAXSRootDirectory  string
+	AXSFiles              []string
+	OutputLogFile         string
+	OutputLogFileOption   LogOption
+	OutputLogConsole      Flag
+	BuildWithDebugInfo    Flag
+	BuildWithSource       Flag
+	BuildWithWC           Flag
+	AdditionalIncludePath []string
+	AdditionalModulePath  []string
+	AdditionalLibraryPath []string
+	Sections              []Section
+
+	// comments holds comment lines read before a key or section, by the
+	// lower case line they precede, so Write can put them back
+	comments map[string][]string
+}
+
+// Files returns every AXSFile in compile order, including those in Sections
+func (c *Config) Files() []string {
+	files := append([]string{}, c.AXSFiles...)
+	for _, s := range c.Sections {
+		files = append(files, s.AXSFiles...)
+	}
+	return files
+}
+
+// LoadConfig reads and parses a .cfg file from disk
+func LoadConfig(fn string) (*Config, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig populates a Config from the contents of a .cfg file. The first
+// block of comment lines before any key is kept in Comment and section
+// comments start a new Section. Other comments are kept with the key or
+// section which follows them and written back by Write, while comments
+// after the last key, or before a key Write leaves out, are dropped
+func ParseConfig(b []byte) (*Config, error) {
+
+	c := &Config{}
+	header := true
+	var pending []string
+	n := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		n++
+		l := strings.TrimSpace(scanner.Text())
+
+		// Comments and blank lines
+		if strings.HasPrefix(l, ";") {
+			l = strings.TrimPrefix(l, ";")
+			if strings.HasPrefix(l, sectionPrefix) {
+				c.Sections = append(c.Sections, Section{Name: strings.TrimPrefix(l, sectionPrefix)})
+				c.attach(";"+l, pending)
+				pending = nil
+				header = false
+			} else {
+				pending = append(pending, l)
+			}
+			continue
+		}
+		if l == "" {
+			// A blank line ends the header
+			if header && c.Comment == nil {
+				c.Comment = pending
+				pending = nil
+			}
+			continue
+		}
+		if header && c.Comment == nil {
+			c.Comment = pending
+			pending = nil
+		}
+		header = false
+
+		// Everything else is Key=Value
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("line " + strconv.Itoa(n) + ": expected Key=Value")
+		}
+		k := strings.ToLower(strings.TrimSpace(kv[0]))
+		v := strings.TrimSpace(kv[1])
+
+		var err error
+		switch k {
+		case "mainaxsrootdirectory":
+			c.MainAXSRootDirectory = v
+		case "axsfile":
+			if len(c.Sections) > 0 {
+				s := &c.Sections[len(c.Sections)-1]
+				s.AXSFiles = append(s.AXSFiles, v)
+			} else {
+				c.AXSFiles = append(c.AXSFiles, v)
+			}
+		case "outputlogfile":
+			c.OutputLogFile = v
+		case "outputlogfileoption":
+			c.OutputLogFileOption, err = parseLogOption(v)
+		case "outputlogconsoleoption":
+			c.OutputLogConsole, err = parseFlag(v)
+		case "buildwithdebuginformation":
+			c.BuildWithDebugInfo, err = parseFlag(v)
+		case "buildwithsource":
+			c.BuildWithSource, err = parseFlag(v)
+		case "buildwithwc":
+			c.BuildWithWC, err = parseFlag(v)
+		case "additionalincludepath":
+			c.AdditionalIncludePath = append(c.AdditionalIncludePath, v)
+		case "additionalmodulepath":
+			c.AdditionalModulePath = append(c.AdditionalModulePath, v)
+		case "additionallibrarypath":
+			c.AdditionalLibraryPath = append(c.AdditionalLibraryPath, v)
+		default:
+			err = errors.New("unknown key " + strings.TrimSpace(kv[0]))
+		}
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(n) + ": " + err.Error())
+		}
+		c.attach(k+"="+v, pending)
+		pending = nil
+	}
+
+	return c, scanner.Err()
+}
+
+// attach keeps comment lines to be written before line
+func (c *Config) attach(line string, comment []string) {
+	if len(comment) == 0 {
+		return
+	}
+	if c.comments == nil {
+		c.comments = map[string][]string{}
+	}
+	line = strings.ToLower(line)
+	c.comments[line] = append(c.comments[line], comment...)
+}
+
+// writeComment adds comment lines, each starting with a semicolon
+func writeComment(sb *strings.Builder, comment []string) {
+	for _, l := range comment {
+		sb.WriteString(";")
+		sb.WriteString(l)
+		sb.WriteString("\n")
+	}
+}
+
+func parseFlag(v string) (Flag, error) {
+	switch strings.ToUpper(v) {
+	case "Y":
+		return FlagYes, nil
+	case "N":
+		return FlagNo, nil
+	}
+	return FlagUnset, errors.New("invalid Y/N value " + v)
+}
+
+func parseLogOption(v string) (LogOption, error) {
+	switch strings.ToUpper(v) {
+	case "N":
+		return LogNew, nil
+	case "A":
+		return LogAppend, nil
+	}
+	return LogUnset, errors.New("invalid A/N value " + v)
+}
+
+// Write outputs the Config in .cfg format, omitting any unset values
+func (c *Config) Write(w io.Writer) error {
+
+	var sb strings.Builder
+
+	// Header Comment
+	writeComment(&sb, c.Comment)
+	if len(c.Comment) > 0 {
+		sb.WriteString("\n")
+	}
+
+	// Root Directory
+	if c.MainAXSRootDirectory != "" {
+		c.writeKey(&sb, "MainAXSRootDirectory", c.MainAXSRootDirectory)
+		sb.WriteString("\n")
+	}
+
+	// Log Options
+	c.writeKey(&sb, "OutputLogFile", c.OutputLogFile)
+	c.writeKey(&sb, "OutputLogFileOption", c.OutputLogFileOption.String())
+	c.writeKey(&sb, "OutputLogConsoleOption", c.OutputLogConsole.String())
+
+	// Compiler Options
+	c.writeKey(&sb, "BuildWithDebugInformation", c.BuildWithDebugInfo.String())
+	c.writeKey(&sb, "BuildWithSource", c.BuildWithSource.String())
+	c.writeKey(&sb, "BuildWithWC", c.BuildWithWC.String())
+	sb.WriteString("\n")
+
+	// Additional Paths
+	c.writeKeys(&sb, "AdditionalIncludePath", c.AdditionalIncludePath)
+	c.writeKeys(&sb, "AdditionalModulePath", c.AdditionalModulePath)
+	c.writeKeys(&sb, "AdditionalLibraryPath", c.AdditionalLibraryPath)
+
+	// Files to Compile
+	c.writeKeys(&sb, "AXSFile", c.AXSFiles)
+	for _, s := range c.Sections {
+		marker := ";" + sectionPrefix + s.Name
+		writeComment(&sb, c.comments[strings.ToLower(marker)])
+		sb.WriteString(marker)
+		sb.WriteString("\n")
+		c.writeKeys(&sb, "AXSFile", s.AXSFiles)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Bytes returns the Config in .cfg format
+func (c *Config) Bytes() []byte {
+	var b bytes.Buffer
+	c.Write(&b)
+	return b.Bytes()
+}
+
+// writeKey adds a Key=Value line, after any comments read before it, if the
+// value is set
+func (c *Config) writeKey(sb *strings.Builder, k string, v string) {
+	if v == "" {
+		return
+	}
+	writeComment(sb, c.comments[strings.ToLower(k+"="+v)])
+	sb.WriteString(k)
+	sb.WriteString("=")
+	sb.WriteString(v)
+	sb.WriteString("\n")
+}
+
+// writeKeys adds a Key=Value line for each value, followed by a blank line
+func (c *Config) writeKeys(sb *strings.Builder, k string, vs []string) {
+	for _, v := range vs {
+		c.writeKey(sb, k, v)
+	}
+	if len(vs) > 0 {
+		sb.WriteString("\n")
+	}
+}
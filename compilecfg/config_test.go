@@ -0,0 +1,138 @@
+package compilecfg
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigSamples(t *testing.T) {
+
+	tests := []struct {
+		file     string
+		root     string
+		files    []string
+		log      string
+		option   LogOption
+		debug    Flag
+		includes []string
+		modules  []string
+		libs     []string
+		comment  string
+	}{
+		{
+			file:     "NLRCExample_1.cfg",
+			root:     `C:\AMX Projects\ACME Corporation`,
+			files:    []string{`Modules\TV.axs`, `Modules\DVD.axs`, `General Utility\Network\Network.axs`},
+			log:      `C:\AMXProjects\Example1_Compile.log`,
+			option:   LogNew,
+			debug:    FlagYes,
+			includes: []string{`C:\AMXProjects\Includes`},
+			modules:  []string{`C:\AMXProjects\Small Room Modules`},
+			libs:     []string{`C:\AMXProjects\Small Room Library`},
+			comment:  " The NLRCExample_1.cfg Configuration File",
+		},
+		{
+			file:     "NLRCExample_2.cfg",
+			root:     RelativeRoot,
+			files:    []string{`C:\AMXProjects\MainBoardRoom\QuantumData.axs`, `C:\AMXProjects\MainBoardRoom\Projector.axs`, `C:\AMXProjects\MainBoardRoom\main.axs`},
+			log:      `C:\AMXProjects\Example2_Compile.log`,
+			option:   LogNew,
+			debug:    FlagYes,
+			includes: []string{"Small Room Includes", "GenUtility Includes"},
+			modules:  []string{"Small Room Modules", "My Duet Modules"},
+			libs:     []string{`General AMX Libraries\Network`},
+			comment:  " The NLRCExample_2.cfg Configuration File",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+
+			c, err := LoadConfig(filepath.Join("Samples", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.MainAXSRootDirectory != tt.root {
+				t.Errorf("MainAXSRootDirectory = %q, want %q", c.MainAXSRootDirectory, tt.root)
+			}
+			if !reflect.DeepEqual(c.AXSFiles, tt.files) {
+				t.Errorf("AXSFiles = %q, want %q", c.AXSFiles, tt.files)
+			}
+			if c.OutputLogFile != tt.log {
+				t.Errorf("OutputLogFile = %q, want %q", c.OutputLogFile, tt.log)
+			}
+			if c.OutputLogFileOption != tt.option {
+				t.Errorf("OutputLogFileOption = %v, want %v", c.OutputLogFileOption, tt.option)
+			}
+			if c.BuildWithDebugInfo != tt.debug {
+				t.Errorf("BuildWithDebugInfo = %v, want %v", c.BuildWithDebugInfo, tt.debug)
+			}
+			if !reflect.DeepEqual(c.AdditionalIncludePath, tt.includes) {
+				t.Errorf("AdditionalIncludePath = %q, want %q", c.AdditionalIncludePath, tt.includes)
+			}
+			if !reflect.DeepEqual(c.AdditionalModulePath, tt.modules) {
+				t.Errorf("AdditionalModulePath = %q, want %q", c.AdditionalModulePath, tt.modules)
+			}
+			if !reflect.DeepEqual(c.AdditionalLibraryPath, tt.libs) {
+				t.Errorf("AdditionalLibraryPath = %q, want %q", c.AdditionalLibraryPath, tt.libs)
+			}
+			if len(c.Comment) < 2 || strings.TrimSpace(c.Comment[1]) != strings.TrimSpace(tt.comment) {
+				t.Errorf("Comment = %q, want second line %q", c.Comment, tt.comment)
+			}
+
+			// Write and parse again, keeping every key and comment
+			out := c.Bytes()
+			r, err := ParseConfig(out)
+			if err != nil {
+				t.Fatalf("parsing written config: %v\n%s", err, out)
+			}
+			if !reflect.DeepEqual(c, r) {
+				t.Errorf("round trip differs\nread:    %+v\nwritten: %+v", c, r)
+			}
+			if !strings.Contains(string(out), ";  Main AXS Root Directory Reference") {
+				t.Errorf("comment before MainAXSRootDirectory not written:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestConfigComments(t *testing.T) {
+
+	in := `; Header
+
+; Root
+MainAXSRootDirectory=-R
+; Debug
+BuildWithDebugInformation=y
+; First
+; Section: One
+; Only file
+AXSFile=a.axs
+; Trailing
+`
+	c, err := ParseConfig([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `; Header
+
+; Root
+MainAXSRootDirectory=-R
+
+; Debug
+BuildWithDebugInformation=Y
+
+; First
+; Section: One
+; Only file
+AXSFile=a.axs
+
+`
+	if got := string(c.Bytes()); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
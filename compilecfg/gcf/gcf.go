@@ -9,25 +9,66 @@ import (
 	"github.com/soloworks/go-netlinx/compilecfg"
 )
 
+// Largest .apw accepted
+const maxBody = 10 << 20
+
+// queryFlag sets f from a boolean URL variable if present
+func queryFlag(r *http.Request, name string, f *compilecfg.Flag) {
+	if v, err := strconv.ParseBool(r.URL.Query().Get(name)); err == nil {
+		*f = compilecfg.NewFlag(v)
+	}
+}
+
 // GenerateNetlinxCompileCfg is a Cloud Function which returns a .cfg file for
 // Netlinx compiler from a .apw xml file (passed as body)
 func GenerateNetlinxCompileCfg(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	// Get Body as Bytes Array
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
 	if err != nil {
-		w.WriteHeader(500)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
 		return
 	}
 
 	// Load this into an APW Workspace
 	a, err := apw.NewAPW("myWorkspace.apw", body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get URL Variables
-	root := r.URL.Query().Get("root")
-	logfile := r.URL.Query().Get("logfile")
-	logconsole, _ := strconv.ParseBool(r.URL.Query().Get("logconsole"))
+	opts := compilecfg.DefaultOptions()
+	opts.Root = r.URL.Query().Get("root")
+	opts.LogFile = r.URL.Query().Get("logfile")
+	opts.LogConsole = compilecfg.FlagNo
+	queryFlag(r, "logconsole", &opts.LogConsole)
+	queryFlag(r, "debug", &opts.DebugInfo)
+	queryFlag(r, "source", &opts.Source)
+	queryFlag(r, "wc", &opts.WC)
+	if v, err := strconv.ParseBool(r.URL.Query().Get("sourcesfirst")); err == nil {
+		opts.ModulesFirst = !v
+	}
+	if r.URL.Query().Get("order") == "workspace" {
+		opts.Order = compilecfg.OrderWorkspace
+	}
+	switch r.URL.Query().Get("scope") {
+	case "project":
+		opts.Scope = compilecfg.ScopeProject
+	case "system":
+		opts.Scope = compilecfg.ScopeSystem
+	}
+	opts.Project = r.URL.Query().Get("project")
+	opts.System = r.URL.Query().Get("system")
+
 	// Process and generate the .cfg
-	b := compilecfg.Generate(*a, root, logfile, logconsole)
+	b := compilecfg.Generate(*a, opts)
 
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write(b)
 }
@@ -6,3 +6,7 @@ require (
 	github.com/soloworks/go-netlinx/apw v0.0.0-20190714191235-a674af7ca695
 	github.com/soloworks/go-netlinx/compilecfg v0.0.0-20190714191235-a674af7ca695
 )
+
+replace github.com/soloworks/go-netlinx/apw => ../../apw
+
+replace github.com/soloworks/go-netlinx/compilecfg => ../../compilecfg
@@ -4,4 +4,4 @@ go 1.12
 
 require github.com/soloworks/go-netlinx/apw v0.0.0-20190531151213-8a28d4c5dd30
 
-//replace github.com/soloworks/go-netlinx/apw => ../apw
+replace github.com/soloworks/go-netlinx/apw => ../apw
@@ -0,0 +1,181 @@
+package compilelog
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint identifies a diagnostic by severity, file name, code and
+// message. Line numbers and folders are left out so that edits elsewhere
+// in a file, or building from a different checkout, don't change it
+func (d Diagnostic) Fingerprint() string {
+	fn := strings.ToLower(filepath.Base(strings.Replace(d.File, `\`, `/`, -1)))
+	h := sha1.New()
+	h.Write([]byte(d.Severity.String() + "|" + fn + "|" + d.Code + "|" + d.Message))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// BaselineEntry is a known diagnostic and the number of times it occurs
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+	File        string `json:"file"`
+	Code        string `json:"code,omitempty"`
+	Message     string `json:"message"`
+	Count       int    `json:"count"`
+}
+
+// Baseline holds the set of diagnostics accepted from a previous build
+type Baseline struct {
+	Version int              `json:"version"`
+	Entries []*BaselineEntry `json:"entries"`
+}
+
+// Comparison holds the difference between a Build and a Baseline
+type Comparison struct {
+	New   []Diagnostic
+	Fixed []*BaselineEntry
+}
+
+// NewBaseline creates a Baseline from every diagnostic in a Build
+func NewBaseline(b *Build) *Baseline {
+
+	bl := &Baseline{Version: 1}
+	entries := make(map[string]*BaselineEntry)
+
+	for _, d := range b.Diagnostics() {
+		fp := d.Fingerprint()
+		if e, ok := entries[fp]; ok {
+			e.Count++
+			continue
+		}
+		e := &BaselineEntry{
+			Fingerprint: fp,
+			Severity:    d.Severity.String(),
+			File:        d.File,
+			Code:        d.Code,
+			Message:     d.Message,
+			Count:       1,
+		}
+		entries[fp] = e
+		bl.Entries = append(bl.Entries, e)
+	}
+
+	// Sort so the file diffs cleanly when committed
+	sort.Slice(bl.Entries, func(i, j int) bool {
+		if bl.Entries[i].File != bl.Entries[j].File {
+			return bl.Entries[i].File < bl.Entries[j].File
+		}
+		return bl.Entries[i].Fingerprint < bl.Entries[j].Fingerprint
+	})
+
+	return bl
+}
+
+// LoadBaseline reads a Baseline previously written by Save
+func LoadBaseline(fn string) (*Baseline, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var bl Baseline
+	if err := json.Unmarshal(b, &bl); err != nil {
+		return nil, err
+	}
+	return &bl, nil
+}
+
+// Save writes the Baseline to a file as JSON
+func (bl *Baseline) Save(fn string) error {
+	b, err := json.MarshalIndent(bl, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, b, 0644)
+}
+
+// Compare reports diagnostics in the Build which aren't in the Baseline, and
+// baseline entries which no longer occur. Where a diagnostic is repeated,
+// only occurrences beyond the baseline count are new
+func (bl *Baseline) Compare(b *Build) *Comparison {
+
+	c := &Comparison{}
+
+	// Remaining allowance for each known diagnostic
+	remaining := make(map[string]int)
+	for _, e := range bl.Entries {
+		remaining[e.Fingerprint] += e.Count
+	}
+
+	for _, d := range b.Diagnostics() {
+		fp := d.Fingerprint()
+		if remaining[fp] > 0 {
+			remaining[fp]--
+			continue
+		}
+		c.New = append(c.New, d)
+	}
+
+	for _, e := range bl.Entries {
+		if n := remaining[e.Fingerprint]; n > 0 {
+			fixed := *e
+			fixed.Count = n
+			c.Fixed = append(c.Fixed, &fixed)
+			remaining[e.Fingerprint] = 0
+		}
+	}
+
+	return c
+}
+
+// Regressed returns true if the Build has diagnostics not in the Baseline
+func (c *Comparison) Regressed() bool { return len(c.New) > 0 }
+
+// Text returns a readable summary of the Comparison
+func (c *Comparison) Text() []byte {
+
+	var sb strings.Builder
+
+	if len(c.New) > 0 {
+		sb.WriteString("New Issues:\n")
+		for _, d := range c.New {
+			sb.WriteString(d.Raw)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(c.Fixed) > 0 {
+		sb.WriteString("Fixed Issues:\n")
+		for _, e := range c.Fixed {
+			sb.WriteString(e.Severity)
+			if e.File != "" {
+				sb.WriteString(": " + e.File)
+			}
+			if e.Code != "" {
+				sb.WriteString(": " + e.Code)
+			}
+			sb.WriteString(": " + e.Message)
+			if e.Count > 1 {
+				sb.WriteString(" (x" + strconv.Itoa(e.Count) + ")")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("New: ")
+	sb.WriteString(strconv.Itoa(len(c.New)))
+	sb.WriteString("\n")
+	sb.WriteString("Fixed: ")
+	sb.WriteString(strconv.Itoa(len(c.Fixed)))
+	sb.WriteString("\n")
+
+	return []byte(sb.String())
+}
@@ -0,0 +1,114 @@
+package compilelog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+
+	before, err := Parse([]byte(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bl := NewBaseline(before)
+
+	// The repeated warning is kept once with a count
+	if len(bl.Entries) != 4 {
+		t.Fatalf("%d entries, want 4", len(bl.Entries))
+	}
+	for _, e := range bl.Entries {
+		if want := 1 + strings.Count(e.Message, "[SINTEGER]"); e.Count != want {
+			t.Errorf("%s count = %d, want %d", e.Message, e.Count, want)
+		}
+	}
+
+	// Saved and loaded unchanged
+	dir, err := ioutil.TempDir("", "compilelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "baseline.json")
+	if err := bl.Save(fn); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, bl) {
+		t.Errorf("loaded %+v, want %+v", loaded, bl)
+	}
+
+	// Lines moved by an edit, and files reported from another folder, are
+	// still the same issues
+	shifted := strings.NewReplacer(
+		`WARNING: C:\Builds\Site`, `WARNING: D:\Jenkins\workspace\site`,
+		`ERROR: C:\Builds\Site`, `ERROR: D:\Jenkins\workspace\site`,
+		"main.axs(102)", "main.axs(110)",
+		"main.axs(230)", "main.axs(238)",
+		"UI.axi(48)", "UI.axi(12)",
+	).Replace(testLog)
+	after, err := Parse([]byte(shifted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := loaded.Compare(after)
+	if c.Regressed() || len(c.New) != 0 || len(c.Fixed) != 0 {
+		t.Errorf("shifted lines compared as %s", c.Text())
+	}
+	for _, d := range after.Diagnostics() {
+		if d.Line != 0 && strings.Contains(testLog, d.Raw) {
+			t.Errorf("%s not shifted", d.Raw)
+		}
+	}
+}
+
+func TestBaselineCompare(t *testing.T) {
+
+	bl := NewBaseline(mustParse(t, testLog))
+
+	// One warning fixed, a third copy of the repeated one and a new error
+	log := strings.Replace(testLog,
+		"ERROR: C:\\Builds\\Site\\Includes\\UI.axi(48): C10580: Undefined identifier [nVolume]\n",
+		"WARNING: C:\\Builds\\Site\\Source\\main.axs(300): C10571: Converting type [SINTEGER] to [INTEGER]\n"+
+			"ERROR: C:\\Builds\\Site\\Source\\main.axs(301): C10580: Undefined identifier [nLevel]\n", 1)
+	c := bl.Compare(mustParse(t, log))
+
+	want := `New Issues:
+ERROR: C:\Builds\Site\Source\main.axs(301): C10580: Undefined identifier [nLevel]
+WARNING: C:\Builds\Site\Source\main.axs(230): C10571: Converting type [SINTEGER] to [INTEGER]
+
+Fixed Issues:
+ERROR: C:\Builds\Site\Includes\UI.axi: C10580: Undefined identifier [nVolume]
+
+New: 2
+Fixed: 1
+`
+	if got := string(c.Text()); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+	if !c.Regressed() {
+		t.Error("new issues not a regression")
+	}
+
+	// Only fixing issues isn't a regression
+	fixed := strings.Replace(testLog, "ERROR: C:\\Builds\\Site\\Includes\\UI.axi(48): C10580: Undefined identifier [nVolume]\n", "", 1)
+	if c := bl.Compare(mustParse(t, fixed)); c.Regressed() || len(c.Fixed) != 1 {
+		t.Errorf("fixing compared as %s", c.Text())
+	}
+}
+
+func mustParse(t *testing.T, log string) *Build {
+	t.Helper()
+	b, err := Parse([]byte(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
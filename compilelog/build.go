@@ -0,0 +1,271 @@
+package compilelog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity specifies the level of a compiler diagnostic
+type Severity int
+
+// Severity values for use outside this module
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// Severities as printed by the compiler
+var severities = [...]string{
+	"WARNING",
+	"ERROR",
+}
+
+// String returns the compiler's name for the Severity
+func (s Severity) String() string { return severities[s] }
+
+// Target specifies the type of file a compile produces
+type Target int
+
+// Target values for use outside this module
+const (
+	TargetUnknown Target = iota
+	TargetTKN
+	TargetTKO
+)
+
+// Targets for use outside this module
+var targets = [...]string{
+	"Unknown",
+	"TKN",
+	"TKO",
+}
+
+// String returns the English name of the Target
+func (t Target) String() string { return targets[t] }
+
+// Diagnostic is a single warning or error reported by the compiler
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	FileID   string
+	Line     int
+	Code     string
+	Message  string
+	Raw      string
+}
+
+// Compile holds the output of compiling a single .axs file
+type Compile struct {
+	File        string
+	Version     string
+	StartMarker string
+	EndMarker   string
+	Start       time.Time
+	End         time.Time
+	Duration    time.Duration
+	Target      Target
+	Diagnostics []Diagnostic
+	Summary     []string
+	Errors      int
+	Warnings    int
+}
+
+// Complete returns true if the compiler reported the end of this compile
+func (c *Compile) Complete() bool { return c.EndMarker != "" }
+
+// HasWarnings returns true if the compile produced any warnings
+func (c *Compile) HasWarnings() bool { return c.count(SeverityWarning) > 0 || c.Warnings > 0 }
+
+// HasErrors returns true if the compile produced any errors
+func (c *Compile) HasErrors() bool { return c.count(SeverityError) > 0 || c.Errors > 0 }
+
+func (c *Compile) count(s Severity) int {
+	var n int
+	for _, d := range c.Diagnostics {
+		if d.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Build holds every compile found in a log, plus any lines outside of them
+type Build struct {
+	Compiles []*Compile
+	Other    []string
+}
+
+// Diagnostics returns all diagnostics from every compile in order
+func (b *Build) Diagnostics() []Diagnostic {
+	var ds []Diagnostic
+	for _, c := range b.Compiles {
+		ds = append(ds, c.Diagnostics...)
+	}
+	return ds
+}
+
+// Markers and timestamps as printed by the compiler
+const (
+	startPrefix = "---- Starting NetLinx Compile"
+	endPrefix   = "---- NetLinx Compile Complete"
+	timeLayout  = "01-02-2006 15:04:05"
+)
+
+var (
+	reVersion    = regexp.MustCompile(`Version\[([^\]]*)\]`)
+	reTimestamp  = regexp.MustCompile(`\[(\d{2}-\d{2}-\d{4} \d{2}:\d{2}:\d{2})\]`)
+	reDiagnostic = regexp.MustCompile(`^(WARNING|ERROR):\s*(.*?)\((\d+)\):\s*([A-Za-z]+\d+):\s*(.*)$`)
+	reSummary    = regexp.MustCompile(`^(.*) - (\d+) error\(s\), (\d+) warning\(s\)$`)
+	reTarget     = regexp.MustCompile(`(?i)\.(tkn|tko)\b`)
+)
+
+// Parser holds state while working through a log line by line, so a log
+// can be processed as it is written
+type Parser struct {
+	build *Build
+	cur   *Compile
+}
+
+// NewParser returns a Parser ready for the first line of a log
+func NewParser() *Parser {
+	return &Parser{build: &Build{}}
+}
+
+// Build returns everything parsed so far
+func (p *Parser) Build() *Build { return p.build }
+
+// Line processes a single line of log output, returning any diagnostic
+// found so callers can act on it immediately
+func (p *Parser) Line(l string) *Diagnostic {
+
+	l = strings.TrimSpace(l)
+	if l == "" {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(l, startPrefix):
+		p.cur = &Compile{StartMarker: l}
+		if m := reVersion.FindStringSubmatch(l); m != nil {
+			p.cur.Version = m[1]
+		}
+		p.cur.Start = timestamp(l)
+		p.build.Compiles = append(p.build.Compiles, p.cur)
+		return nil
+
+	case p.cur == nil:
+		// Anything before the first compile isn't part of one
+		if d := parseDiagnostic(l); d != nil {
+			p.cur = &Compile{}
+			p.build.Compiles = append(p.build.Compiles, p.cur)
+		} else {
+			p.build.Other = append(p.build.Other, l)
+			return nil
+		}
+
+	case strings.HasPrefix(l, endPrefix):
+		p.cur.EndMarker = l
+		p.cur.End = timestamp(l)
+		if !p.cur.Start.IsZero() && !p.cur.End.IsZero() {
+			p.cur.Duration = p.cur.End.Sub(p.cur.Start)
+		}
+		p.cur = nil
+		return nil
+	}
+
+	if d := parseDiagnostic(l); d != nil {
+		p.cur.Diagnostics = append(p.cur.Diagnostics, *d)
+		return d
+	}
+
+	// The file name is printed on its own before compiling starts
+	if p.cur.File == "" && len(p.cur.Summary) == 0 && strings.HasSuffix(strings.ToLower(l), ".axs") {
+		p.cur.File = l
+		return nil
+	}
+
+	// Anything else is informational output from the compiler
+	if m := reSummary.FindStringSubmatch(l); m != nil {
+		if p.cur.File == "" {
+			p.cur.File = m[1]
+		}
+		p.cur.Errors, _ = strconv.Atoi(m[2])
+		p.cur.Warnings, _ = strconv.Atoi(m[3])
+	}
+	if m := reTarget.FindStringSubmatch(l); m != nil && p.cur.Target == TargetUnknown {
+		switch strings.ToLower(m[1]) {
+		case "tkn":
+			p.cur.Target = TargetTKN
+		case "tko":
+			p.cur.Target = TargetTKO
+		}
+	}
+	p.cur.Summary = append(p.cur.Summary, l)
+
+	return nil
+}
+
+// timestamp extracts a compiler timestamp from a marker line
+func timestamp(l string) time.Time {
+	var t time.Time
+	if m := reTimestamp.FindStringSubmatch(l); m != nil {
+		t, _ = time.ParseInLocation(timeLayout, m[1], time.Local)
+	}
+	return t
+}
+
+// parseDiagnostic returns a Diagnostic if the line is a warning or error
+func parseDiagnostic(l string) *Diagnostic {
+
+	var d Diagnostic
+	switch {
+	case strings.HasPrefix(l, "WARNING:"):
+		d.Severity = SeverityWarning
+	case strings.HasPrefix(l, "ERROR:"):
+		d.Severity = SeverityError
+	default:
+		return nil
+	}
+	d.Raw = l
+
+	// Most have a file, line and code, but fall back to the whole message
+	if m := reDiagnostic.FindStringSubmatch(l); m != nil {
+		d.File = m[2]
+		d.Line, _ = strconv.Atoi(m[3])
+		d.Code = m[4]
+		d.Message = m[5]
+	} else {
+		d.Message = strings.TrimSpace(l[strings.Index(l, ":")+1:])
+	}
+
+	return &d
+}
+
+// Parse builds a structured Build from a raw Netlinx Compiler log. Lines
+// which aren't recognised are kept rather than treated as errors
+func Parse(logData []byte) (*Build, error) {
+	return Stream(bytes.NewReader(logData), nil)
+}
+
+// Stream parses a log from r as it is read, calling fn (if not nil) with
+// each Diagnostic as soon as it is found. The complete Build is returned
+// once r reaches EOF
+func Stream(r io.Reader, fn func(*Compile, Diagnostic)) (*Build, error) {
+
+	p := NewParser()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if d := p.Line(scanner.Text()); d != nil && fn != nil {
+			fn(p.cur, *d)
+		}
+	}
+
+	return p.build, scanner.Err()
+}
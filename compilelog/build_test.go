@@ -0,0 +1,171 @@
+package compilelog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLog is an NLRC log of a main source and a module followed by a compile
+// which never finished, with lines before the first compile
+const testLog = `Processing Configuration File: C:\Builds\Site\compile.cfg
+
+---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:00:00] ----
+C:\Builds\Site\Source\main.axs
+WARNING: C:\Builds\Site\Source\main.axs(102): C10571: Converting type [SINTEGER] to [INTEGER]
+ERROR: C:\Builds\Site\Includes\UI.axi(48): C10580: Undefined identifier [nVolume]
+WARNING: C:\Builds\Site\Source\main.axs(230): C10571: Converting type [SINTEGER] to [INTEGER]
+C:\Builds\Site\Source\main.axs - 1 error(s), 2 warning(s)
+---- NetLinx Compile Complete [07-14-2019 10:00:02] ----
+
+---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:00:02] ----
+C:\Builds\Site\Modules\Display.axs
+   Generating C:\Builds\Site\Modules\Display.tko
+C:\Builds\Site\Modules\Display.axs - 0 error(s), 0 warning(s)
+---- NetLinx Compile Complete [07-14-2019 10:01:32] ----
+
+---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:01:32] ----
+C:\Builds\Site\Source\touch.axs
+ERROR: Unable to open file [C:\Builds\Site\Includes\Missing.axi]
+
+ERROR: Could not write C:\Builds\Site\Source\other.tkn
+`
+
+// describeCompile summarises a Compile on one line
+func describeCompile(c *Compile) string {
+	return fmt.Sprintf("%s v%s %s %s complete=%t errors=%t/%d warnings=%t/%d diagnostics=%d",
+		c.File, c.Version, c.Target, c.Duration, c.Complete(), c.HasErrors(), c.Errors, c.HasWarnings(), c.Warnings, len(c.Diagnostics))
+}
+
+func TestParse(t *testing.T) {
+
+	b, err := Parse([]byte(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`C:\Builds\Site\Source\main.axs v2.5.2.300 Unknown 2s complete=true errors=true/1 warnings=true/2 diagnostics=3`,
+		`C:\Builds\Site\Modules\Display.axs v2.5.2.300 TKO 1m30s complete=true errors=false/0 warnings=false/0 diagnostics=0`,
+		`C:\Builds\Site\Source\touch.axs v2.5.2.300 Unknown 0s complete=false errors=true/0 warnings=false/0 diagnostics=2`,
+	}
+	if len(b.Compiles) != len(want) {
+		t.Fatalf("%d compiles, want %d", len(b.Compiles), len(want))
+	}
+	for i, c := range b.Compiles {
+		if got := describeCompile(c); got != want[i] {
+			t.Errorf("compile %d = %s\nwant        %s", i, got, want[i])
+		}
+	}
+
+	start := time.Date(2019, 7, 14, 10, 0, 0, 0, time.Local)
+	if c := b.Compiles[0]; !c.Start.Equal(start) || !c.End.Equal(start.Add(2*time.Second)) {
+		t.Errorf("compile 0 ran %v to %v", c.Start, c.End)
+	}
+	if got := b.Compiles[1].Summary; len(got) != 2 || got[0] != `Generating C:\Builds\Site\Modules\Display.tko` {
+		t.Errorf("Summary = %q", got)
+	}
+	if want := []string{`Processing Configuration File: C:\Builds\Site\compile.cfg`}; strings.Join(b.Other, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Other = %q, want %q", b.Other, want)
+	}
+
+	// The last compile never ended, so both errors after it belong to it
+	ds := b.Diagnostics()
+	if len(ds) != 5 {
+		t.Fatalf("%d diagnostics, want 5", len(ds))
+	}
+	if d := ds[1]; d.Severity != SeverityError || d.File != `C:\Builds\Site\Includes\UI.axi` || d.Line != 48 ||
+		d.Code != "C10580" || d.Message != "Undefined identifier [nVolume]" {
+		t.Errorf("diagnostic 1 = %+v", d)
+	}
+	if d := ds[4]; d.Raw != `ERROR: Could not write C:\Builds\Site\Source\other.tkn` {
+		t.Errorf("diagnostic 4 = %+v", d)
+	}
+}
+
+func TestParseOutsideCompile(t *testing.T) {
+
+	// A diagnostic before any compile starts one without a file name, which
+	// the summary then names
+	b, err := Parse([]byte("NLRC started\n" +
+		"ERROR: main.axs(1): C10201: Syntax error\n" +
+		"main.axs - 1 error(s), 0 warning(s)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Compiles) != 1 || len(b.Other) != 1 {
+		t.Fatalf("%d compiles and other lines %q", len(b.Compiles), b.Other)
+	}
+	want := "main.axs v Unknown 0s complete=false errors=true/1 warnings=false/0 diagnostics=1"
+	if got := describeCompile(b.Compiles[0]); got != want {
+		t.Errorf("compile = %s, want %s", got, want)
+	}
+}
+
+func TestParseDiagnostic(t *testing.T) {
+
+	tests := []struct {
+		line string
+		want *Diagnostic
+	}{
+		{
+			line: `WARNING: C:\Builds\Site\Source\main.axs(102): C10571: Converting type [SINTEGER] to [INTEGER]`,
+			want: &Diagnostic{Severity: SeverityWarning, File: `C:\Builds\Site\Source\main.axs`, Line: 102, Code: "C10571", Message: "Converting type [SINTEGER] to [INTEGER]"},
+		},
+		{
+			line: `ERROR: C:\AMX Projects\Site (Old)\main.axs(7): C10580: Undefined identifier [x]`,
+			want: &Diagnostic{Severity: SeverityError, File: `C:\AMX Projects\Site (Old)\main.axs`, Line: 7, Code: "C10580", Message: "Undefined identifier [x]"},
+		},
+		{
+			line: `ERROR:main.axs(1):C10201:Syntax error`,
+			want: &Diagnostic{Severity: SeverityError, File: "main.axs", Line: 1, Code: "C10201", Message: "Syntax error"},
+		},
+		{
+			line: `ERROR: Unable to open file [C:\Builds\Site\Includes\Missing.axi]`,
+			want: &Diagnostic{Severity: SeverityError, Message: `Unable to open file [C:\Builds\Site\Includes\Missing.axi]`},
+		},
+		{
+			line: `WARNING: main.axs(12): Missing code`,
+			want: &Diagnostic{Severity: SeverityWarning, Message: "main.axs(12): Missing code"},
+		},
+		{line: `C:\Builds\Site\Source\main.axs - 0 error(s), 0 warning(s)`},
+		{line: `Warning: lower case isn't the compiler's`},
+	}
+
+	for _, tt := range tests {
+		got := parseDiagnostic(tt.line)
+		if tt.want != nil {
+			tt.want.Raw = tt.line
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseDiagnostic(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+
+	var seen []string
+	b, err := Stream(strings.NewReader(testLog), func(c *Compile, d Diagnostic) {
+		seen = append(seen, fmt.Sprintf("%s %s %d", c.File, d.Code, d.Line))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each diagnostic is passed on with the compile it belongs to
+	want := []string{
+		`C:\Builds\Site\Source\main.axs C10571 102`,
+		`C:\Builds\Site\Source\main.axs C10580 48`,
+		`C:\Builds\Site\Source\main.axs C10571 230`,
+		`C:\Builds\Site\Source\touch.axs  0`,
+		`C:\Builds\Site\Source\touch.axs  0`,
+	}
+	if strings.Join(seen, "\n") != strings.Join(want, "\n") {
+		t.Errorf("streamed:\n%s\nwant:\n%s", strings.Join(seen, "\n"), strings.Join(want, "\n"))
+	}
+	if len(b.Compiles) != 3 {
+		t.Errorf("%d compiles, want 3", len(b.Compiles))
+	}
+}
@@ -0,0 +1,66 @@
+package compilelog
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Checkstyle XML structures
+type checkstyleResult struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr,omitempty"`
+}
+
+// ToCheckstyle renders all diagnostics in the Build as checkstyle XML,
+// grouped by the file they were reported against, or else the file compiled
+func (b *Build) ToCheckstyle() ([]byte, error) {
+
+	r := checkstyleResult{Version: "4.3"}
+	files := make(map[string]int)
+
+	for _, c := range b.Compiles {
+		for _, d := range c.Diagnostics {
+			// Report those without a file against the file being compiled
+			name := d.File
+			if name == "" {
+				name = c.File
+			}
+			name = strings.Replace(name, `\`, `/`, -1)
+			i, ok := files[name]
+			if !ok {
+				i = len(r.Files)
+				files[name] = i
+				r.Files = append(r.Files, checkstyleFile{Name: name})
+			}
+			e := checkstyleError{
+				Line:     d.Line,
+				Severity: "warning",
+				Message:  d.Message,
+				Source:   d.Code,
+			}
+			if d.Severity == SeverityError {
+				e.Severity = "error"
+			}
+			r.Files[i].Errors = append(r.Files[i].Errors, e)
+		}
+	}
+
+	out, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
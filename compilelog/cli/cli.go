@@ -1,45 +1,207 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/soloworks/go-netlinx/apw"
 	"github.com/soloworks/go-netlinx/compilelog"
 )
 
 type myargs struct {
-	Source string
-	Dest   string
-	Root   string
+	Source       string
+	Dest         string
+	Root         string
+	Format       string
+	Baseline     string
+	SaveBaseline string
+	Follow       bool
+	Idle         time.Duration
+	Live         bool
+	Color        string
+	APW          string
 }
 
 var args myargs
 
+// ANSI colours for live output
+const (
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
 func main() {
 	// Get Command Line Variables
-	flag.StringVar(&args.Source, "Source", "", "Source Log File")
-	flag.StringVar(&args.Root, "Root", "", "Root Directory for log")
-	flag.StringVar(&args.Dest, "Dest", "clean.log", "Destination Log File")
+	flag.StringVar(&args.Source, "Source", "", "Source Log File (- for stdin)")
+	flag.StringVar(&args.Root, "Root", "", "Root Directory for log (defaults to the APW folder)")
+	flag.StringVar(&args.Dest, "Dest", "clean.log", "Destination Log File (- for stdout)")
+	flag.StringVar(&args.Format, "Format", "text", "Output Format (text|sarif|junit|checkstyle)")
+	flag.StringVar(&args.Baseline, "Baseline", "", "Compare against Baseline File (exit 2 on new issues)")
+	flag.StringVar(&args.SaveBaseline, "SaveBaseline", "", "Save issues to Baseline File")
+	flag.BoolVar(&args.Follow, "Follow", false, "Keep reading the Source Log File as it grows")
+	flag.DurationVar(&args.Idle, "Idle", 0, "Stop following after no new output for this long (0 = until interrupted)")
+	flag.BoolVar(&args.Live, "Live", false, "Print issues as they are found (default when streaming)")
+	flag.StringVar(&args.Color, "Color", "auto", "Colour live output (auto|always|never)")
+	flag.StringVar(&args.APW, "APW", "", "Workspace used to identify files in the log")
 	flag.Parse()
 
-	// Load in the core APW file
-	b, err := ioutil.ReadFile(args.Source)
+	// Open the log source
+	var r io.ReadCloser
+	stop := func() {}
+	switch {
+	case args.Source == "-":
+		r = os.Stdin
+		args.Live = true
+	case args.Follow:
+		// Stop following cleanly on Ctrl+C, until the log has been read
+		ctx, cancel := context.WithCancel(context.Background())
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		go func() {
+			select {
+			case <-sig:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		stop = func() {
+			signal.Stop(sig)
+			cancel()
+		}
+		r = compilelog.Follow(ctx, args.Source, args.Idle)
+		args.Live = true
+	default:
+		f, err := os.Open(args.Source)
+		if err != nil {
+			println(`Error Loading Log File: "` + args.Source + `"`)
+			println(err.Error())
+			os.Exit(1)
+		}
+		r = f
+	}
+	defer r.Close()
+
+	// Print issues to the console as they arrive if required, on stderr so
+	// they never mix with a document written to stdout
+	var live func(*compilelog.Compile, compilelog.Diagnostic)
+	if args.Live {
+		color := useColor()
+		live = func(c *compilelog.Compile, d compilelog.Diagnostic) {
+			printDiagnostic(d, color)
+		}
+	}
+
+	// Process the log
+	build, err := compilelog.Stream(r, live)
+	stop()
 	if err != nil {
-		println(`Error Loading Log File: "` + args.Source + `"`)
+		println(`Error Processing Log File: "` + args.Source + `"`)
 		println(err.Error())
 		os.Exit(1)
 	}
 
-	// Process the log
-	result, err := compilelog.Process(b, args.Root)
+	// Map paths back to the workspace if supplied
+	if args.APW != "" {
+		a, err := apw.LoadAPW(args.APW)
+		if err != nil {
+			println(`Error Loading APW File: "` + args.APW + `"`)
+			println(err.Error())
+			os.Exit(1)
+		}
+		// Without a root, paths are taken as relative to the workspace
+		if args.Root == "" {
+			args.Root, _ = filepath.Abs(a.OriginPath)
+		}
+		build.MapPaths(compilelog.NewPathMapper(args.Root, a))
+	}
+
+	result, err := compilelog.RenderBuild(build, args.Root, args.Format)
+	if err != nil {
+		println(`Error Processing Log File: "` + args.Source + `"`)
+		println(err.Error())
+		os.Exit(1)
+	}
 
 	// Output to File
-	err = ioutil.WriteFile(args.Dest, result, 0644)
+	if args.Dest == "-" {
+		os.Stdout.Write(result)
+	} else {
+		err = ioutil.WriteFile(args.Dest, result, 0644)
+	}
 	if err != nil {
 		println(`Error Writing Log File: "` + args.Dest + `"`)
-		println(err)
+		println(err.Error())
 		os.Exit(1)
 	}
 
+	// Compare against an existing baseline
+	var c *compilelog.Comparison
+	if args.Baseline != "" {
+		bl, err := compilelog.LoadBaseline(args.Baseline)
+		if err != nil {
+			println(`Error Loading Baseline File: "` + args.Baseline + `"`)
+			println(err.Error())
+			os.Exit(1)
+		}
+		c = bl.Compare(build)
+		os.Stderr.Write(c.Text())
+	}
+
+	// Store a new baseline
+	if args.SaveBaseline != "" {
+		err = compilelog.NewBaseline(build).Save(args.SaveBaseline)
+		if err != nil {
+			println(`Error Writing Baseline File: "` + args.SaveBaseline + `"`)
+			println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Fail if anything new has appeared
+	if c != nil && c.Regressed() {
+		os.Exit(2)
+	}
+}
+
+// useColor decides whether live output should be coloured
+func useColor() bool {
+	switch args.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	// Only colour when writing to a terminal
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+}
+
+// printDiagnostic writes a single issue to stderr
+func printDiagnostic(d compilelog.Diagnostic, color bool) {
+	var line string
+	if d.File != "" {
+		line = d.File + "(" + strconv.Itoa(d.Line) + "): "
+	}
+	if d.Code != "" {
+		line += d.Code + ": "
+	}
+	line = d.Severity.String() + ": " + line + d.Message
+
+	if color {
+		switch d.Severity {
+		case compilelog.SeverityError:
+			line = colorRed + line + colorReset
+		default:
+			line = colorYellow + line + colorReset
+		}
+	}
+	os.Stderr.WriteString(line + "\n")
 }
@@ -3,7 +3,13 @@ module github.com/soloworks/compilelog/cli
 go 1.12
 
 require (
-	github.com/soloworks/go-netlinx/apw v0.0.0-20190531213119-d581c8a74889 // indirect
+	github.com/soloworks/go-netlinx/apw v0.0.0-20190531213119-d581c8a74889
 	github.com/soloworks/go-netlinx/compilecfg v0.0.0-20190531213119-d581c8a74889 // indirect
 	github.com/soloworks/go-netlinx/compilelog v0.0.0-20190531213119-d581c8a74889
 )
+
+replace github.com/soloworks/go-netlinx/apw => ../../apw
+
+replace github.com/soloworks/go-netlinx/compilecfg => ../../compilecfg
+
+replace github.com/soloworks/go-netlinx/compilelog => ../../compilelog
@@ -1,8 +1,7 @@
 package compilelog
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"strconv"
 	"strings"
 )
@@ -11,66 +10,58 @@ import (
 // And returns a more readable version with stats
 func Process(logData []byte, root string) ([]byte, error) {
 
+	// Parse the log into compiles
+	b, err := Parse(logData)
+	if err != nil {
+		return nil, err
+	}
+
+	return ProcessBuild(b, root), nil
+}
+
+// ProcessBuild returns the readable version of an already parsed log
+func ProcessBuild(b *Build, root string) []byte {
+
 	// Variables to hold data
 	var FilesTotal int
 	var FilesTotalWarning int
 	var FilesTotalError int
-	var FileWarning bool
-	var FileError bool
-	var FileName string
 	// String Builder
 	var sb strings.Builder
 
-	// Put the bytes into a scanner
-	scanner := bufio.NewScanner(bytes.NewReader(logData))
-	for scanner.Scan() {
-		// Get a line
-		l := scanner.Text()
-		// Trim Whitespace
-		l = strings.TrimSpace(l)
-		// Remove root if present
-		l = strings.Replace(l, root, "", 1)
-		// Only handle lines with more than 1 char
-		if len(l) > 0 {
-			if strings.HasPrefix(l, "---- Starting NetLinx Compile") {
-				FileWarning = false
-				FileError = false
-				FileName = ""
-			}
-			if strings.HasPrefix(l, "WARNING:") || strings.HasPrefix(l, "ERROR:") {
-				if strings.HasPrefix(l, "WARNING:") {
-					FileWarning = true
-					l = strings.TrimPrefix(l, "WARNING:")
-
-				}
-				if strings.HasPrefix(l, "ERROR:") {
-					FileError = true
-					l = strings.TrimPrefix(l, "ERROR:")
-				}
-				l = strings.TrimSpace(l)
-
-				chunks := strings.SplitN(l, "(", 2)
-				if FileName == "" {
-					FileName = chunks[0]
-					sb.WriteString("\n")
-					sb.WriteString(FileName)
-					sb.WriteString("\n")
-				}
+	m := NewPathMapper(root, nil)
 
-				sb.WriteString("(")
-				sb.WriteString(chunks[1])
+	for _, c := range b.Compiles {
+		// List diagnostics under the file they were found in
+		var FileName string
+		for i, d := range c.Diagnostics {
+			// Remove root if present
+			fn := m.Relative(d.File)
+			if i == 0 || fn != FileName {
+				FileName = fn
 				sb.WriteString("\n")
+				sb.WriteString(FileName)
+				sb.WriteString("\n")
+			}
+			if d.Code != "" {
+				sb.WriteString("(")
+				sb.WriteString(strconv.Itoa(d.Line))
+				sb.WriteString("): ")
+				sb.WriteString(d.Code)
+				sb.WriteString(": ")
+			}
+			sb.WriteString(d.Message)
+			sb.WriteString("\n")
+		}
 
+		if c.Complete() {
+			if c.HasWarnings() {
+				FilesTotalWarning++
 			}
-			if strings.HasPrefix(l, "---- NetLinx Compile Complete") {
-				if FileWarning {
-					FilesTotalWarning++
-				}
-				if FileError {
-					FilesTotalError++
-				}
-				FilesTotal++
+			if c.HasErrors() {
+				FilesTotalError++
 			}
+			FilesTotal++
 		}
 	}
 
@@ -87,5 +78,46 @@ func Process(logData []byte, root string) ([]byte, error) {
 	sb.WriteString(strconv.Itoa(FilesTotal))
 	sb.WriteString("\n")
 
-	return []byte(sb.String()), nil
+	return []byte(sb.String())
+}
+
+// Output formats supported by Render
+const (
+	FormatText       = "text"
+	FormatSARIF      = "sarif"
+	FormatJUnit      = "junit"
+	FormatCheckstyle = "checkstyle"
+)
+
+// Render processes a raw log file into the requested format, with text
+// being the same as Process
+func Render(logData []byte, root string, format string) ([]byte, error) {
+
+	b, err := Parse(logData)
+	if err != nil {
+		return nil, err
+	}
+
+	return RenderBuild(b, root, format)
+}
+
+// RenderBuild outputs an already parsed log in the requested format, with
+// paths made relative to root if it is set
+func RenderBuild(b *Build, root string, format string) ([]byte, error) {
+
+	if root != "" {
+		b.MapPaths(NewPathMapper(root, nil))
+	}
+
+	switch format {
+	case FormatText, "":
+		return ProcessBuild(b, root), nil
+	case FormatSARIF:
+		return b.ToSARIF()
+	case FormatJUnit:
+		return b.ToJUnit()
+	case FormatCheckstyle:
+		return b.ToCheckstyle()
+	}
+	return nil, errors.New("unknown format " + format)
 }
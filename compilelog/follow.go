@@ -0,0 +1,78 @@
+package compilelog
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// How often a followed file is checked for new data
+const followPoll = 250 * time.Millisecond
+
+// follower reads a file which is still being written, waiting for more
+// data at EOF rather than stopping
+type follower struct {
+	ctx  context.Context
+	fn   string
+	f    *os.File
+	idle time.Duration
+	last time.Time
+}
+
+// Follow returns a reader for a log file which the compiler may still be
+// writing, much like tail -f. Reading waits for the file to be created and
+// for new lines to arrive, and returns io.EOF once ctx is cancelled or, if
+// idle is not zero, when nothing new has been written for that long
+func Follow(ctx context.Context, fn string, idle time.Duration) io.ReadCloser {
+	return &follower{ctx: ctx, fn: fn, idle: idle, last: time.Now()}
+}
+
+// Read implements io.Reader
+func (t *follower) Read(p []byte) (int, error) {
+	for {
+		if t.f == nil {
+			// The compiler may not have created the file yet
+			f, err := os.Open(t.fn)
+			if err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+			t.f = f
+		}
+
+		if t.f != nil {
+			// Start again if the file has been replaced by a new build
+			pos, _ := t.f.Seek(0, io.SeekCurrent)
+			if info, err := t.f.Stat(); err == nil && info.Size() < pos {
+				t.f.Seek(0, io.SeekStart)
+			}
+
+			n, err := t.f.Read(p)
+			if n > 0 {
+				t.last = time.Now()
+				return n, nil
+			}
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+
+		// Nothing new, so check for a reason to stop then wait
+		if t.idle > 0 && time.Since(t.last) > t.idle {
+			return 0, io.EOF
+		}
+		select {
+		case <-t.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(followPoll):
+		}
+	}
+}
+
+// Close implements io.Closer
+func (t *follower) Close() error {
+	if t.f != nil {
+		return t.f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package compilelog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// appendLog adds text to the end of a log file, creating it if needed
+func appendLog(t *testing.T, fn string, text string) {
+	t.Helper()
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "compilelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "build.log")
+
+	// The log is created after following starts and written in parts, as
+	// the compiler would
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(2 * followPoll)
+		appendLog(t, fn, testLog[:200])
+		time.Sleep(2 * followPoll)
+		appendLog(t, fn, testLog[200:])
+	}()
+
+	var streamed int
+	r := Follow(context.Background(), fn, 8*followPoll)
+	b, err := Stream(r, func(*Compile, Diagnostic) { streamed++ })
+	r.Close()
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Compiles) != 3 || streamed != 5 {
+		t.Errorf("followed %d compiles and %d diagnostics, want 3 and 5", len(b.Compiles), streamed)
+	}
+}
+
+func TestFollowReplaced(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "compilelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "build.log")
+	appendLog(t, fn, "first build output which is longer than the second\n")
+
+	// A new build truncating the log is read from the start
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(2 * followPoll)
+		if err := ioutil.WriteFile(fn, []byte("second build\n"), 0644); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(2 * followPoll)
+		cancel()
+	}()
+
+	r := Follow(ctx, fn, 0)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first build output which is longer than the second\nsecond build\n"; string(b) != want {
+		t.Errorf("read %q, want %q", b, want)
+	}
+}
@@ -7,22 +7,34 @@ import (
 	"github.com/soloworks/go-netlinx/compilelog"
 )
 
-// ProcessNetlinxCompileLog is a Cloud Function which returns a .cfg file for
-// Netlinx compiler from a .apw xml file (passed as body)
+// Largest log accepted
+const maxBody = 10 << 20
+
+// ProcessNetlinxCompileLog is a Cloud Function which returns a cleaned up
+// Netlinx compiler log from a raw log (passed as body)
 func ProcessNetlinxCompileLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	// Get Body as Bytes Array
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
 	if err != nil {
-		w.WriteHeader(500)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	// Load this into an APW Workspace
-
 	// Get URL Variables
 	root := r.URL.Query().Get("root")
-	// Process and generate the .cfg
+	// Process the log
 	b, err := compilelog.Process(body, root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write(b)
 }
@@ -6,3 +6,7 @@ require (
 	github.com/soloworks/go-netlinx/apw v0.0.0-20190531201957-cef3f8d7d7d6
 	github.com/soloworks/go-netlinx/compilecfg v0.0.0-20190531201957-cef3f8d7d7d6
 )
+
+replace github.com/soloworks/go-netlinx/apw => ../apw
+
+replace github.com/soloworks/go-netlinx/compilecfg => ../compilecfg
@@ -0,0 +1,109 @@
+package compilelog
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// JUnit XML structures as understood by most CI dashboards
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// ToJUnit renders the Build as JUnit XML with one testcase per compiled
+// file. Errors fail the testcase, warnings are listed in its output and
+// compiles which never completed are reported as errors
+func (b *Build) ToJUnit() ([]byte, error) {
+
+	suite := junitTestSuite{Name: "NetLinx Compile"}
+	var total float64
+
+	for _, c := range b.Compiles {
+		name := strings.Replace(c.File, `\`, `/`, -1)
+		tc := junitTestCase{
+			Name:      filepath.Base(name),
+			ClassName: name,
+			Time:      strconv.FormatFloat(c.Duration.Seconds(), 'f', 3, 64),
+		}
+		total += c.Duration.Seconds()
+
+		// Sort the diagnostics by severity
+		var errs, warns []string
+		for _, d := range c.Diagnostics {
+			if d.Severity == SeverityError {
+				errs = append(errs, d.Raw)
+			} else {
+				warns = append(warns, d.Raw)
+			}
+		}
+
+		switch {
+		case len(errs) > 0 || c.Errors > 0:
+			n := len(errs)
+			if c.Errors > n {
+				n = c.Errors
+			}
+			tc.Failure = &junitProblem{
+				Message: strconv.Itoa(n) + " error(s)",
+				Type:    SeverityError.String(),
+				Body:    strings.Join(errs, "\n"),
+			}
+			suite.Failures++
+		case !c.Complete():
+			tc.Error = &junitProblem{
+				Message: "compile did not complete",
+				Type:    "Incomplete",
+				Body:    strings.Join(c.Summary, "\n"),
+			}
+			suite.Errors++
+		}
+		tc.SystemOut = strings.Join(warns, "\n")
+
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+	}
+	suite.Time = strconv.FormatFloat(total, 'f', 3, 64)
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,110 @@
+package compilelog
+
+import (
+	"path"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// PathMapper converts the absolute Windows paths printed by the compiler
+// into POSIX paths relative to the workspace root, and optionally back to
+// the Identifier of the matching file in a workspace
+type PathMapper struct {
+	root  string
+	files map[string]string
+}
+
+// toPOSIX converts a Windows path to forward slashes, removing duplicate
+// and trailing separators
+func toPOSIX(p string) string {
+	p = strings.TrimSpace(strings.Replace(p, `\`, `/`, -1))
+	if p == "" {
+		return ""
+	}
+	// Keep the leading // of UNC paths, which Clean would collapse
+	unc := strings.HasPrefix(p, "//")
+	p = path.Clean(p)
+	if unc {
+		p = "/" + p
+	}
+	return p
+}
+
+// isAbs reports whether a path from toPOSIX is absolute, including Windows
+// drive letter paths
+func isAbs(p string) bool {
+	return strings.HasPrefix(p, "/") || len(p) > 2 && p[1] == ':' && p[2] == '/'
+}
+
+// NewPathMapper returns a PathMapper for the given root folder. If a
+// workspace is supplied its files, found from the folder holding the .apw,
+// can be looked up with Identifier
+func NewPathMapper(root string, a *apw.APW) *PathMapper {
+
+	m := &PathMapper{
+		root:  strings.TrimSuffix(toPOSIX(root), "/"),
+		files: make(map[string]string),
+	}
+
+	if a != nil && a.Workspace != nil {
+		for _, p := range a.Workspace.Projects {
+			for _, s := range p.Systems {
+				for _, f := range s.Files {
+					// Files are stored relative to the .apw, not the root
+					fn := toPOSIX(f.FilePathName)
+					if !isAbs(fn) {
+						fn = path.Join(toPOSIX(a.OriginPath), fn)
+					}
+					m.files[strings.ToLower(m.Relative(fn))] = f.Identifier
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// Relative returns p relative to the root using forward slashes. Paths
+// outside of the root are returned in full. Matching ignores case, as
+// Windows does
+func (m *PathMapper) Relative(p string) string {
+	p = toPOSIX(p)
+	if m.root == "" || m.root == "." {
+		return p
+	}
+	lp, lr := strings.ToLower(p), strings.ToLower(m.root)
+	switch {
+	case lp == lr:
+		return "."
+	case strings.HasPrefix(lp, lr+"/"):
+		return p[len(m.root)+1:]
+	}
+	return p
+}
+
+// Identifier returns the workspace Identifier of the file at p, or "" if
+// it isn't part of the workspace
+func (m *PathMapper) Identifier(p string) string {
+	return m.files[strings.ToLower(m.Relative(p))]
+}
+
+// MapPaths rewrites every file in the Build to be relative to the root,
+// setting FileID on diagnostics for files found in the workspace
+func (b *Build) MapPaths(m *PathMapper) {
+	for _, c := range b.Compiles {
+		if c.File != "" {
+			c.File = m.Relative(c.File)
+		}
+		for i := range c.Diagnostics {
+			d := &c.Diagnostics[i]
+			if d.File == "" {
+				continue
+			}
+			if id := m.Identifier(d.File); id != "" {
+				d.FileID = id
+			}
+			d.File = m.Relative(d.File)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package compilelog
+
+import (
+	"testing"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+func TestRelative(t *testing.T) {
+
+	tests := []struct {
+		root string
+		path string
+		want string
+	}{
+		{`C:\Builds\Site`, `C:\Builds\Site\Source\main.axs`, "Source/main.axs"},
+		{`C:\Builds\Site\`, `c:\builds\SITE\Source\main.axs`, "Source/main.axs"},
+		{`C:/Builds/Site`, `C:\Builds\Site\\Includes\.\UI.axi`, "Includes/UI.axi"},
+		{`C:\Builds\Site`, `C:\Builds\Site`, "."},
+		{`C:\Builds\Site`, `C:\Builds\Site2\main.axs`, "C:/Builds/Site2/main.axs"},
+		{`C:\Builds\Site`, `D:\Shared\util.axi`, "D:/Shared/util.axi"},
+		{`\\server\builds`, `\\server\builds\Site\main.axs`, "Site/main.axs"},
+		{`C:\Builds`, `\\server\builds\main.axs`, "//server/builds/main.axs"},
+		{"", `C:\Builds\Site\main.axs`, "C:/Builds/Site/main.axs"},
+		{".", `Source\main.axs`, "Source/main.axs"},
+	}
+
+	for _, tt := range tests {
+		if got := NewPathMapper(tt.root, nil).Relative(tt.path); got != tt.want {
+			t.Errorf("Relative(%q) from %q = %q, want %q", tt.path, tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestPathMapperWorkspace(t *testing.T) {
+
+	a := &apw.APW{
+		OriginPath: `C:\Builds\Site\Workspace`,
+		Workspace: &apw.Workspace{Projects: []*apw.Project{{Systems: []*apw.System{{Files: []*apw.File{
+			{Identifier: "Main Source", FilePathName: `..\Source\main.axs`},
+			{Identifier: "UI", FilePathName: `Includes\UI.axi`},
+			{Identifier: "Shared", FilePathName: `D:\Shared\util.axi`},
+		}}}}}},
+	}
+
+	// Relative files are found from the .apw folder, below the root
+	m := NewPathMapper(`C:\Builds\Site`, a)
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\Builds\Site\Source\main.axs`, "Main Source"},
+		{`c:\builds\site\workspace\includes\ui.axi`, "UI"},
+		{`D:\Shared\util.axi`, "Shared"},
+		{`C:\Builds\Site\Includes\UI.axi`, ""},
+		{`C:\Builds\Site\Source\other.axs`, ""},
+	}
+	for _, tt := range tests {
+		if got := m.Identifier(tt.path); got != tt.want {
+			t.Errorf("Identifier(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	b := mustParse(t, testLog)
+	b.MapPaths(m)
+	if c := b.Compiles[0]; c.File != "Source/main.axs" || c.Diagnostics[0].FileID != "Main Source" || c.Diagnostics[1].File != "Includes/UI.axi" || c.Diagnostics[1].FileID != "" {
+		t.Errorf("mapped %q with %+v", c.File, c.Diagnostics)
+	}
+	if d := b.Compiles[2].Diagnostics[0]; d.File != "" || d.FileID != "" {
+		t.Errorf("diagnostic without a file mapped to %+v", d)
+	}
+}
@@ -0,0 +1,70 @@
+package compilelog
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestRender(t *testing.T) {
+
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{FormatText, "build.txt"},
+		{FormatSARIF, "build.sarif"},
+		{FormatJUnit, "build.junit.xml"},
+		{FormatCheckstyle, "build.checkstyle.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+
+			got, err := Render([]byte(testLog), `C:\Builds\Site`, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fn := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := ioutil.WriteFile(fn, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Render(%s) =\n%s\nwant\n%s", tt.format, got, want)
+			}
+		})
+	}
+
+	if _, err := Render([]byte(testLog), "", "html"); err == nil {
+		t.Error("unknown format rendered")
+	}
+}
+
+func TestFileURI(t *testing.T) {
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\AMX Projects\main.axs`, "file:///C:/AMX%20Projects/main.axs"},
+		{`\\server\share\Site #2\main.axs`, "file://server/share/Site%20%232/main.axs"},
+		{`\\server`, "file://server/"},
+		{"Source/main.axs", "Source/main.axs"},
+		{"Includes/UI 50%.axi", "Includes/UI%2050%25.axi"},
+	}
+	for _, tt := range tests {
+		if got := fileURI(tt.path); got != tt.want {
+			t.Errorf("fileURI(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
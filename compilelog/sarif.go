@@ -0,0 +1,131 @@
+package compilelog
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// SARIF 2.1.0 structures, only the parts needed to report diagnostics
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// fileURI converts a path from the log into an escaped URI. Absolute Windows
+// and UNC paths become file URIs and relative paths a relative reference
+func fileURI(p string) string {
+	p = strings.Replace(p, `\`, `/`, -1)
+	switch {
+	case len(p) > 1 && p[1] == ':':
+		return (&url.URL{Scheme: "file", Path: "/" + p}).String()
+	case strings.HasPrefix(p, "//"):
+		hp := strings.SplitN(p[2:], "/", 2)
+		u := &url.URL{Scheme: "file", Host: hp[0], Path: "/"}
+		if len(hp) > 1 {
+			u.Path += hp[1]
+		}
+		return u.String()
+	}
+	return (&url.URL{Path: p}).String()
+}
+
+// ToSARIF renders all diagnostics in the Build as a SARIF 2.1.0 log
+func (b *Build) ToSARIF() ([]byte, error) {
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "NLRC",
+			InformationURI: "https://github.com/soloworks/go-netlinx",
+		}},
+		Results: []sarifResult{},
+	}
+
+	// Use the compiler version if it was reported
+	for _, c := range b.Compiles {
+		if c.Version != "" {
+			run.Tool.Driver.Version = c.Version
+			break
+		}
+	}
+
+	rules := make(map[string]bool)
+	for _, d := range b.Diagnostics() {
+		r := sarifResult{
+			RuleID:  d.Code,
+			Level:   "warning",
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.Severity == SeverityError {
+			r.Level = "error"
+		}
+		if d.File != "" {
+			l := sarifLocation{}
+			l.PhysicalLocation.ArtifactLocation.URI = fileURI(d.File)
+			if d.Line > 0 {
+				l.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line}
+			}
+			r.Locations = append(r.Locations, l)
+		}
+		if d.Code != "" && !rules[d.Code] {
+			rules[d.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Code})
+		}
+		run.Results = append(run.Results, r)
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
@@ -0,0 +1,194 @@
+package compiler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/compilecfg"
+	"github.com/soloworks/go-netlinx/deps"
+)
+
+// HashExt is added to the name of an artefact for the file recording the
+// hash of the source it was built from
+const HashExt = ".hash"
+
+// Cache hashes each source file together with every include and module it
+// uses, so sources are only compiled when something they depend on changes.
+// Files are read again for each build, so edits between builds are seen
+type Cache struct {
+	opts   deps.Options
+	graph  *deps.Graph
+	hashes map[string]string
+}
+
+// NewCache returns a Cache which resolves dependencies using the include and
+// module paths of the config being built, followed by any in opts
+func NewCache(opts deps.Options) *Cache {
+	c := &Cache{opts: opts}
+	c.reset(opts)
+	return c
+}
+
+// reset forgets every file and hash, so the next build starts from what is
+// on disk now, resolving files with opts
+func (c *Cache) reset(opts deps.Options) {
+	c.graph = deps.NewGraph(opts)
+	c.hashes = make(map[string]string)
+}
+
+// searchOptions returns the Cache options with the search paths of cfg
+// first, as paths on this machine, so files are found as NLRC finds them
+func (c *Compiler) searchOptions(cfg *compilecfg.Config) deps.Options {
+	opts := c.Cache.opts
+	paths := func(cfgPaths []string, extra []string) []string {
+		var ps []string
+		for _, p := range cfgPaths {
+			ps = append(ps, c.sourcePath(cfg, p))
+		}
+		return append(ps, extra...)
+	}
+	opts.IncludePaths = paths(cfg.AdditionalIncludePath, opts.IncludePaths)
+	opts.ModulePaths = paths(cfg.AdditionalModulePath, opts.ModulePaths)
+	return opts
+}
+
+// options returns the parts of a Config which change the compiled output
+func options(cfg *compilecfg.Config) string {
+	return "debug=" + cfg.BuildWithDebugInfo.String() +
+		";source=" + cfg.BuildWithSource.String() +
+		";wc=" + cfg.BuildWithWC.String()
+}
+
+// Hash returns a hash of a source file, everything it depends on when built
+// with cfg and the build options used, as they are on disk now
+func (c *Compiler) Hash(src string, cfg *compilecfg.Config) (string, error) {
+	if c.Cache == nil {
+		return "", errors.New("no cache set")
+	}
+	c.Cache.reset(c.searchOptions(cfg))
+	return c.Cache.hash(src, cfg)
+}
+
+// hash is Hash, reusing files and hashes from earlier in the same build
+func (c *Cache) hash(src string, cfg *compilecfg.Config) (string, error) {
+
+	key := src + "|" + options(cfg)
+	if h, ok := c.hashes[key]; ok {
+		return h, nil
+	}
+
+	root, err := c.graph.AddRoot(src)
+	if err != nil {
+		return "", err
+	}
+
+	// Sort the files so the hash doesn't depend on walk order
+	var files []string
+	reached := make(map[string]bool)
+	c.graph.Walk(root, func(n *deps.Node) {
+		files = append(files, n.Path)
+		reached[n.Path] = true
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return strings.ToLower(files[i]) < strings.ToLower(files[j])
+	})
+
+	h := sha256.New()
+	h.Write([]byte(options(cfg) + "\n"))
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(b)
+		h.Write([]byte(strings.ToLower(filepath.Base(f)) + " " + hex.EncodeToString(sum[:]) + "\n"))
+	}
+
+	// Unresolved names matter too, as adding the file changes the build
+	for _, r := range c.graph.Unresolved {
+		if !reached[r.From] {
+			continue
+		}
+		h.Write([]byte("? " + strings.ToLower(r.Name) + "\n"))
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	c.hashes[key] = sum
+	return sum, nil
+}
+
+// current returns true if an artefact of src exists with a matching hash
+func (c *Cache) current(src string, hash string) bool {
+	base := strings.TrimSuffix(src, filepath.Ext(src))
+	for _, ext := range []string{".tkn", ".tko"} {
+		if _, err := os.Stat(base + ext); err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(base + ext + HashExt)
+		if err == nil && strings.TrimSpace(string(b)) == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// record writes the hash file alongside an artefact
+func (c *Cache) record(a Artefact, cfg *compilecfg.Config) error {
+	hash, err := c.hash(a.Source, cfg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(a.Path+HashExt, []byte(hash+"\n"), 0644)
+}
+
+// Stale returns a copy of cfg holding only the AXSFile entries which are
+// out of date, plus the number of entries removed as up to date
+func (c *Compiler) Stale(cfg *compilecfg.Config) (*compilecfg.Config, int, error) {
+
+	if c.Cache == nil {
+		return cfg, 0, nil
+	}
+	c.Cache.reset(c.searchOptions(cfg))
+
+	skipped := 0
+	filter := func(files []string) ([]string, error) {
+		var out []string
+		for _, f := range files {
+			src := c.sourcePath(cfg, f)
+			hash, err := c.Cache.hash(src, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if c.Cache.current(src, hash) {
+				skipped++
+				continue
+			}
+			out = append(out, f)
+		}
+		return out, nil
+	}
+
+	stale := *cfg
+	var err error
+	if stale.AXSFiles, err = filter(cfg.AXSFiles); err != nil {
+		return nil, 0, err
+	}
+	stale.Sections = nil
+	for _, s := range cfg.Sections {
+		files, err := filter(s.AXSFiles)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(files) > 0 {
+			stale.Sections = append(stale.Sections, compilecfg.Section{Name: s.Name, AXSFiles: files})
+		}
+	}
+
+	return &stale, skipped, nil
+}
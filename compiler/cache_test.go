@@ -0,0 +1,112 @@
+package compiler
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soloworks/go-netlinx/compilecfg"
+	"github.com/soloworks/go-netlinx/deps"
+)
+
+func TestCacheSeesEdits(t *testing.T) {
+
+	dir := workspace(t, map[string]string{
+		"main.axs":   "PROGRAM_NAME='main'\n#include 'shared.axi'\n",
+		"shared.axi": "DEFINE_CONSTANT\nVERSION = 1\n",
+	})
+	defer os.RemoveAll(dir)
+
+	e := &fakeExecutor{}
+	c := New(e)
+	c.Dir = dir
+	c.Cache = NewCache(deps.Options{IncludePaths: []string{dir}})
+	cfg := &compilecfg.Config{MainAXSRootDirectory: compilecfg.RelativeRoot, AXSFiles: []string{"main.axs"}}
+
+	build := func(want bool) {
+		t.Helper()
+		runs := len(e.jobs)
+		r, err := c.Compile(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(e.jobs) > runs; got != want {
+			t.Errorf("compiled = %v, want %v", got, want)
+		}
+		if r.UpToDate() == want {
+			t.Errorf("UpToDate() = %v, want %v", r.UpToDate(), !want)
+		}
+	}
+
+	build(true)
+	if _, err := os.Stat(filepath.Join(dir, "main.tkn"+HashExt)); err != nil {
+		t.Fatal("hash not recorded:", err)
+	}
+	build(false)
+
+	// Changing an include, even keeping its size, makes the source stale
+	if err := ioutil.WriteFile(filepath.Join(dir, "shared.axi"), []byte("DEFINE_CONSTANT\nVERSION = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build(true)
+	build(false)
+
+	// As does adding a file the source was looking for
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.axs"), []byte("PROGRAM_NAME='main'\n#include 'shared.axi'\n#include 'extra.axi'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build(true)
+	if err := ioutil.WriteFile(filepath.Join(dir, "extra.axi"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	build(true)
+	build(false)
+}
+
+func TestCacheUsesConfigPaths(t *testing.T) {
+
+	dir := workspace(t, map[string]string{
+		"main.axs":       "PROGRAM_NAME='main'\n#include 'shared.axi'\n",
+		"old/shared.axi": "DEFINE_CONSTANT\nVERSION = 1\n",
+		"new/shared.axi": "DEFINE_CONSTANT\nVERSION = 2\n",
+	})
+	defer os.RemoveAll(dir)
+
+	e := &fakeExecutor{}
+	c := New(e)
+	c.Dir = dir
+	c.Cache = NewCache(deps.Options{})
+	cfg := &compilecfg.Config{
+		MainAXSRootDirectory:  compilecfg.RelativeRoot,
+		AXSFiles:              []string{"main.axs"},
+		AdditionalIncludePath: []string{"old"},
+	}
+
+	build := func(want bool) {
+		t.Helper()
+		runs := len(e.jobs)
+		if _, err := c.Compile(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(e.jobs) > runs; got != want {
+			t.Errorf("compiled = %v, want %v", got, want)
+		}
+	}
+
+	build(true)
+	build(false)
+
+	// An include found through the config's own paths is followed
+	if err := ioutil.WriteFile(filepath.Join(dir, "old", "shared.axi"), []byte("DEFINE_CONSTANT\nVERSION = 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build(true)
+	build(false)
+
+	// A path added ahead of it finds another file, so the source is stale
+	cfg.AdditionalIncludePath = []string{"new", "old"}
+	build(true)
+	build(false)
+}
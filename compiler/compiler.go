@@ -0,0 +1,193 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/soloworks/go-netlinx/compilecfg"
+	"github.com/soloworks/go-netlinx/compilelog"
+)
+
+// Extensions of the files NLRC produces alongside each source file
+var artefactExts = []string{".tkn", ".tko", ".src"}
+
+// Allowance for filesystem timestamp resolution when checking artefacts
+const mtimeSkew = 2 * time.Second
+
+// Artefact is a file produced by the compiler
+type Artefact struct {
+	Source string
+	Path   string
+	Ext    string
+}
+
+// Result holds the outcome of a compiler run
+type Result struct {
+	Config    *compilecfg.Config
+	Log       []byte
+	Build     *compilelog.Build
+	Artefacts []Artefact
+	Duration  time.Duration
+	Skipped   int
+	ExitCode  int
+}
+
+// UpToDate returns true if nothing needed compiling
+func (r *Result) UpToDate() bool { return r.Log == nil && r.Skipped > 0 }
+
+// Failed returns true if any compile reported errors or didn't complete. A
+// run with nothing in the log, or where NLRC exited with an error status but
+// reported no errors, also failed
+func (r *Result) Failed() bool {
+	if len(r.Config.Files()) == 0 {
+		return false
+	}
+	if len(r.Build.Compiles) == 0 {
+		return true
+	}
+	for _, c := range r.Build.Compiles {
+		if c.HasErrors() || !c.Complete() {
+			return true
+		}
+	}
+	return r.ExitCode != 0
+}
+
+// Compiler runs NLRC through an Executor and gathers the results
+type Compiler struct {
+	Executor Executor
+	Cache    *Cache
+	Name     string
+	Dir      string
+	// LocalPath maps a path as seen by the compiler to a path on this
+	// machine, used to find artefacts. By default backslashes are converted
+	LocalPath func(string) string
+}
+
+// New returns a Compiler using the given Executor
+func New(e Executor) *Compiler {
+	return &Compiler{
+		Executor:  e,
+		Name:      "build.cfg",
+		LocalPath: defaultLocalPath,
+	}
+}
+
+// defaultLocalPath converts Windows separators to local ones
+func defaultLocalPath(p string) string {
+	return filepath.FromSlash(strings.Replace(p, `\`, `/`, -1))
+}
+
+// Compile runs the compiler for cfg, parses the log and finds the files
+// that were produced. With a Cache set, sources which are up to date are
+// left out and the compiler isn't run at all if nothing has changed
+func (c *Compiler) Compile(ctx context.Context, cfg *compilecfg.Config) (*Result, error) {
+
+	// Only build what has changed
+	cfg, skipped, err := c.Stale(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Files()) == 0 {
+		return &Result{Config: cfg, Build: &compilelog.Build{}, Skipped: skipped}, nil
+	}
+
+	// Without a log file the log must come back through the console
+	run := *cfg
+	if run.OutputLogFile == "" {
+		run.OutputLogConsole = compilecfg.FlagYes
+	}
+
+	name := c.Name
+	if name == "" {
+		name = "build.cfg"
+	}
+
+	start := time.Now()
+	log, err := c.Executor.Run(ctx, Job{
+		Name:    name,
+		Config:  run.Bytes(),
+		LogFile: run.OutputLogFile,
+		Dir:     c.Dir,
+	})
+	var code int
+	if x, ok := err.(*ExitError); ok {
+		code = x.Code
+	} else if err != nil {
+		return nil, err
+	}
+
+	b, err := compilelog.Parse(log)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{
+		Config:    cfg,
+		Log:       log,
+		Build:     b,
+		Artefacts: c.artefacts(cfg, start),
+		Duration:  time.Since(start),
+		Skipped:   skipped,
+		ExitCode:  code,
+	}
+
+	// Note what each new .tkn/.tko was built from
+	if c.Cache != nil {
+		for _, a := range r.Artefacts {
+			if a.Ext == ".src" {
+				continue
+			}
+			if err := c.Cache.record(a, cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// sourcePath returns the local path of an AXSFile entry
+func (c *Compiler) sourcePath(cfg *compilecfg.Config, f string) string {
+
+	local := c.LocalPath
+	if local == nil {
+		local = defaultLocalPath
+	}
+
+	// Absolute paths have a drive letter or start with a separator
+	abs := strings.HasPrefix(f, `\`) || strings.HasPrefix(f, `/`) || (len(f) > 1 && f[1] == ':')
+	if abs {
+		return local(f)
+	}
+
+	// Relative paths are from the root, or the .cfg folder for -R
+	root := cfg.MainAXSRootDirectory
+	if root == "" || root == compilecfg.RelativeRoot {
+		return filepath.Join(c.Dir, local(f))
+	}
+	return local(strings.TrimSuffix(root, `\`) + `\` + f)
+}
+
+// artefacts finds output files written since the compile started
+func (c *Compiler) artefacts(cfg *compilecfg.Config, start time.Time) []Artefact {
+
+	var as []Artefact
+
+	for _, f := range cfg.Files() {
+		src := c.sourcePath(cfg, f)
+		base := strings.TrimSuffix(src, filepath.Ext(src))
+		for _, ext := range artefactExts {
+			info, err := os.Stat(base + ext)
+			if err != nil || info.ModTime().Before(start.Add(-mtimeSkew)) {
+				continue
+			}
+			as = append(as, Artefact{Source: src, Path: base + ext, Ext: ext})
+		}
+	}
+
+	return as
+}
@@ -0,0 +1,394 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soloworks/go-netlinx/compilecfg"
+)
+
+// fakeExecutor behaves like NLRC, writing a .tkn (and .src when building
+// with source) next to each source and returning a log with a warning for
+// each one, or an error for sources named in errors
+type fakeExecutor struct {
+	errors map[string]bool
+	jobs   []Job
+}
+
+func (e *fakeExecutor) Run(ctx context.Context, job Job) ([]byte, error) {
+
+	e.jobs = append(e.jobs, job)
+	cfg, err := compilecfg.ParseConfig(job.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for _, f := range cfg.Files() {
+		src := filepath.Join(job.Dir, f)
+		base := strings.TrimSuffix(src, filepath.Ext(src))
+
+		sb.WriteString("---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:00:00] ----\n")
+		sb.WriteString(src + "\n")
+		if e.errors[f] {
+			fmt.Fprintf(&sb, "ERROR: %s(2): C10580: Undefined identifier\n", src)
+			fmt.Fprintf(&sb, "%s - 1 error(s), 0 warning(s)\n", src)
+		} else {
+			fmt.Fprintf(&sb, "WARNING: %s(1): C10571: Converting type [INTEGER] to [CHAR]\n", src)
+			fmt.Fprintf(&sb, "%s - 0 error(s), 1 warning(s)\n", src)
+			if err := ioutil.WriteFile(base+".tkn", []byte(f), 0644); err != nil {
+				return nil, err
+			}
+			if cfg.BuildWithSource == compilecfg.FlagYes {
+				if err := ioutil.WriteFile(base+".src", []byte(f), 0644); err != nil {
+					return nil, err
+				}
+			}
+		}
+		sb.WriteString("---- NetLinx Compile Complete [07-14-2019 10:00:02] ----\n")
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// workspace creates source files, given by slash separated names, in a
+// temporary folder
+func workspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "compiler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fn, src := range files {
+		fn = filepath.Join(dir, filepath.FromSlash(fn))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCompile(t *testing.T) {
+
+	dir := workspace(t, map[string]string{
+		"main.axs":  "PROGRAM_NAME='main'\n",
+		"other.axs": "PROGRAM_NAME='other'\n",
+		"bad.axs":   "PROGRAM_NAME='bad'\n",
+	})
+	defer os.RemoveAll(dir)
+
+	// An artefact left from an earlier build shouldn't be reported
+	old := filepath.Join(dir, "other.tko")
+	if err := ioutil.WriteFile(old, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	then := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, then, then); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &fakeExecutor{errors: map[string]bool{"bad.axs": true}}
+	c := New(e)
+	c.Dir = dir
+	cfg := &compilecfg.Config{
+		MainAXSRootDirectory: compilecfg.RelativeRoot,
+		BuildWithSource:      compilecfg.FlagYes,
+		AXSFiles:             []string{"main.axs"},
+		Sections:             []compilecfg.Section{{Name: "Rest", AXSFiles: []string{"other.axs", "bad.axs"}}},
+	}
+
+	r, err := c.Compile(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job runs in the build folder and the log comes back on the console
+	if len(e.jobs) != 1 {
+		t.Fatalf("executor ran %d times, want 1", len(e.jobs))
+	}
+	if e.jobs[0].Dir != dir || e.jobs[0].Name != "build.cfg" {
+		t.Errorf("job Dir, Name = %q, %q, want %q, build.cfg", e.jobs[0].Dir, e.jobs[0].Name, dir)
+	}
+	if !strings.Contains(string(e.jobs[0].Config), "OutputLogConsoleOption=Y") {
+		t.Errorf("config doesn't log to the console:\n%s", e.jobs[0].Config)
+	}
+
+	// Log parsing
+	if n := len(r.Build.Compiles); n != 3 {
+		t.Fatalf("got %d compiles, want 3", n)
+	}
+	var codes []string
+	for _, d := range r.Build.Diagnostics() {
+		codes = append(codes, filepath.Base(d.File)+":"+d.Severity.String()+":"+d.Code)
+	}
+	want := []string{"main.axs:WARNING:C10571", "other.axs:WARNING:C10571", "bad.axs:ERROR:C10580"}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("diagnostics = %q, want %q", codes, want)
+	}
+	if !r.Failed() {
+		t.Error("Failed() = false with a compile error")
+	}
+
+	// Artefact collection
+	var got []string
+	for _, a := range r.Artefacts {
+		if filepath.Dir(a.Path) != dir || a.Source != strings.TrimSuffix(a.Path, a.Ext)+".axs" {
+			t.Errorf("artefact %+v not beside its source", a)
+		}
+		got = append(got, filepath.Base(a.Path))
+	}
+	sort.Strings(got)
+	want = []string{"main.src", "main.tkn", "other.src", "other.tkn"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("artefacts = %q, want %q", got, want)
+	}
+}
+
+func TestCompileReplay(t *testing.T) {
+
+	e := &ReplayExecutor{Log: []byte("---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:00:00] ----\n" +
+		"main.axs\n" +
+		"main.axs - 0 error(s), 0 warning(s)\n")}
+	r, err := New(e).Compile(context.Background(), &compilecfg.Config{AXSFiles: []string{"main.axs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Build.Compiles) != 1 || r.Build.Compiles[0].Complete() {
+		t.Fatalf("compiles = %+v, want one incomplete compile", r.Build.Compiles)
+	}
+	if !r.Failed() {
+		t.Error("Failed() = false for an incomplete compile")
+	}
+}
+
+func TestLocalExecutor(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of NLRC")
+	}
+
+	dir := workspace(t, map[string]string{
+		"build.log": "old run\n",
+		"nlrc.sh":   "#!/bin/sh\npwd > where.txt\necho \"$1\" > args.txt\necho 'new run' >> build.log\n",
+	})
+	defer os.RemoveAll(dir)
+	nlrc := filepath.Join(dir, "nlrc.sh")
+	if err := os.Chmod(nlrc, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &LocalExecutor{NLRC: nlrc}
+	log, err := e.Run(context.Background(), Job{Name: "build.cfg", Config: []byte("AXSFile=main.axs\n"), LogFile: "build.log", Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the appended part of the log is returned
+	if string(log) != "new run\n" {
+		t.Errorf("log = %q, want %q", log, "new run\n")
+	}
+
+	// NLRC runs in the build folder with the .cfg written there
+	where, _ := ioutil.ReadFile(filepath.Join(dir, "where.txt"))
+	if real, _ := filepath.EvalSymlinks(dir); strings.TrimSpace(string(where)) != real {
+		t.Errorf("ran in %q, want %q", strings.TrimSpace(string(where)), real)
+	}
+	args, _ := ioutil.ReadFile(filepath.Join(dir, "args.txt"))
+	if got := strings.TrimSpace(string(args)); got != "-C"+filepath.Join(dir, "build.cfg") {
+		t.Errorf("args = %q, want -C%s", got, filepath.Join(dir, "build.cfg"))
+	}
+}
+
+func TestAppended(t *testing.T) {
+
+	tests := []struct {
+		before string
+		after  string
+		want   string
+	}{
+		{"", "new", "new"},
+		{"old\n", "old\nnew\n", "new\n"},
+		{"old log\n", "new\n", "new\n"},
+	}
+	for _, tt := range tests {
+		if got := string(appended([]byte(tt.before), []byte(tt.after))); got != tt.want {
+			t.Errorf("appended(%q, %q) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestSSHFlags(t *testing.T) {
+
+	e := &SSHExecutor{Port: 2222, Identity: "id_build", Args: []string{"-o", "BatchMode=yes"}}
+	if got, want := e.flags("-P"), []string{"-P", "2222", "-i", "id_build", "-o", "BatchMode=yes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scp flags = %q, want %q", got, want)
+	}
+	if got, want := e.flags("-p"), []string{"-p", "2222", "-i", "id_build", "-o", "BatchMode=yes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ssh flags = %q, want %q", got, want)
+	}
+	if got := (&SSHExecutor{}).flags("-p"); len(got) != 0 {
+		t.Errorf("default flags = %q, want none", got)
+	}
+}
+
+func TestFailed(t *testing.T) {
+
+	const clean = "---- Starting NetLinx Compile - Version[2.5.2.300] [07-14-2019 10:00:00] ----\n" +
+		"main.axs\n" +
+		"main.axs - 0 error(s), 0 warning(s)\n" +
+		"---- NetLinx Compile Complete [07-14-2019 10:00:02] ----\n"
+
+	tests := []struct {
+		name   string
+		log    string
+		err    error
+		failed bool
+	}{
+		{name: "clean", log: clean},
+		{name: "clean with a warning status", log: clean, err: &ExitError{Code: 1}, failed: true},
+		{name: "empty log", failed: true},
+		{name: "crashed", log: "Unhandled exception\n", err: &ExitError{Code: -1073741819}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&ReplayExecutor{Log: []byte(tt.log), Err: tt.err}).Compile(context.Background(), &compilecfg.Config{AXSFiles: []string{"main.axs"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x, ok := tt.err.(*ExitError); ok && r.ExitCode != x.Code {
+				t.Errorf("ExitCode = %d, want %d", r.ExitCode, x.Code)
+			}
+			if r.Failed() != tt.failed {
+				t.Errorf("Failed() = %t, want %t", r.Failed(), tt.failed)
+			}
+		})
+	}
+
+	// Other errors from the executor fail the compile itself
+	if _, err := New(&ReplayExecutor{Err: errors.New("no agent")}).Compile(context.Background(), &compilecfg.Config{AXSFiles: []string{"main.axs"}}); err == nil {
+		t.Error("executor error ignored")
+	}
+
+	// Nothing to build hasn't failed
+	r, err := New(&ReplayExecutor{}).Compile(context.Background(), &compilecfg.Config{})
+	if err != nil || r.Failed() {
+		t.Errorf("empty build = %+v, %v", r, err)
+	}
+}
+
+func TestLocalExecutorExit(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of NLRC")
+	}
+
+	dir := workspace(t, map[string]string{
+		"nlrc.sh": "#!/bin/sh\necho 'ERROR: main.axs(1): C10201: Syntax error'\nexit 3\n",
+	})
+	defer os.RemoveAll(dir)
+	nlrc := filepath.Join(dir, "nlrc.sh")
+	if err := os.Chmod(nlrc, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := (&LocalExecutor{NLRC: nlrc}).Run(context.Background(), Job{Name: "build.cfg", Dir: dir})
+	if x, ok := err.(*ExitError); !ok || x.Code != 3 {
+		t.Errorf("err = %v, want exit status 3", err)
+	}
+	if !strings.Contains(string(log), "C10201") {
+		t.Errorf("log = %q", log)
+	}
+}
+
+// fakeSSH puts ssh and scp scripts first on the PATH. ssh records each
+// command line and acts on it in place of the remote machine: type prints
+// the log, NLRC appends to it and exits with $NLRC_EXIT, and the host
+// "offline" fails as ssh does when it can't connect
+func fakeSSH(t *testing.T, dir string) func() {
+	t.Helper()
+	scripts := map[string]string{
+		"scp": "#!/bin/sh\nexit 0\n",
+		"ssh": `#!/bin/sh
+for a; do host=$line; line=$a; done
+if [ "$host" = offline ]; then echo "ssh: connect to host offline port 22: Connection refused" >&2; exit 255; fi
+printf '%s\n' "$line" >> "$SSH_DIR/commands.txt"
+case "$line" in
+'"type "'*) cat "$SSH_DIR/build.log" 2>/dev/null || exit 1 ;;
+*NLRC*) echo "console output"; echo "new run" >> "$SSH_DIR/build.log"; exit $NLRC_EXIT ;;
+esac
+`,
+	}
+	for name, src := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	os.Setenv("SSH_DIR", dir)
+	return func() {
+		os.Setenv("PATH", path)
+		os.Unsetenv("SSH_DIR")
+		os.Unsetenv("NLRC_EXIT")
+	}
+}
+
+func TestSSHExecutor(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts in place of ssh and scp")
+	}
+
+	dir := workspace(t, map[string]string{"build.log": "old run\n"})
+	defer os.RemoveAll(dir)
+	defer fakeSSH(t, dir)()
+
+	e := &SSHExecutor{Host: "builder", Dir: `D:\Builds\`}
+	job := Job{Name: "build.cfg", Config: []byte("AXSFile=main.axs\n"), LogFile: "build.log"}
+
+	// Only the appended part of the log comes back, with NLRC's status
+	os.Setenv("NLRC_EXIT", "1")
+	log, err := e.Run(context.Background(), job)
+	if x, ok := err.(*ExitError); !ok || x.Code != 1 {
+		t.Errorf("err = %v, want exit status 1", err)
+	}
+	if string(log) != "new run\n" {
+		t.Errorf("log = %q, want %q", log, "new run\n")
+	}
+
+	// Each line is quoted as a whole for cmd /c
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "commands.txt"))
+	want := `"type "D:\Builds\build.log""` + "\n" +
+		`""` + DefaultNLRC + `" -C"D:\Builds\build.cfg""` + "\n" +
+		`"type "D:\Builds\build.log""` + "\n"
+	if string(b) != want {
+		t.Errorf("commands:\n%s\nwant:\n%s", b, want)
+	}
+
+	// Without a log file the console output is the log
+	os.Setenv("NLRC_EXIT", "0")
+	log, err = e.Run(context.Background(), Job{Name: "build.cfg"})
+	if err != nil || string(log) != "console output\n" {
+		t.Errorf("console log = %q, %v", log, err)
+	}
+
+	// Failing to connect is an error rather than a compile result
+	e.Host = "offline"
+	if _, err := e.Run(context.Background(), job); err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("err = %v, want the ssh error", err)
+	}
+}
@@ -0,0 +1,331 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Job is a single run of the Netlinx compiler. Dir is the local folder
+// relative source paths in Config are resolved from
+type Job struct {
+	Name    string
+	Config  []byte
+	LogFile string
+	Dir     string
+}
+
+// Executor runs NLRC for a Job and returns the compiler log
+type Executor interface {
+	Run(ctx context.Context, job Job) ([]byte, error)
+}
+
+// ExitError is returned by an Executor, along with the log, when NLRC exits
+// with a non-zero status. NLRC does this for compile errors, so the log
+// decides whether the build failed
+type ExitError struct {
+	Code int
+}
+
+// Error implements error
+func (e *ExitError) Error() string {
+	return "NLRC exited with status " + strconv.Itoa(e.Code)
+}
+
+// exitError converts the error from running a command into an ExitError,
+// returning other errors unchanged
+func exitError(err error) error {
+	if e, ok := err.(*exec.ExitError); ok {
+		return &ExitError{Code: e.ExitCode()}
+	}
+	return err
+}
+
+// DefaultNLRC is where Netlinx Studio installs the console compiler
+const DefaultNLRC = `C:\Program Files (x86)\Common Files\AMXShare\COM\NLRC.exe`
+
+// LocalExecutor runs NLRC on this machine, optionally through Wine. The
+// .cfg file is written into Dir, or the Job Dir if not set, as NLRC finds
+// sources relative to it when the config has no root or a relative root (-R)
+type LocalExecutor struct {
+	NLRC string
+	Dir  string
+	Wine bool
+}
+
+// WinePath converts a local path to the Windows path seen inside Wine,
+// which maps the root filesystem to Z:
+func WinePath(p string) string {
+	return `Z:` + strings.Replace(p, `/`, `\`, -1)
+}
+
+// UnixPath converts a Wine Z: path back to a local path. Other paths are
+// returned with forward slashes
+func UnixPath(p string) string {
+	p = strings.Replace(p, `\`, `/`, -1)
+	if strings.HasPrefix(strings.ToUpper(p), "Z:") {
+		p = p[2:]
+	}
+	return path.Clean(p)
+}
+
+// Run implements Executor
+func (e *LocalExecutor) Run(ctx context.Context, job Job) ([]byte, error) {
+
+	// Write out the config
+	dir := e.Dir
+	if dir == "" {
+		dir = job.Dir
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg := filepath.Join(dir, job.Name)
+	if err := ioutil.WriteFile(cfg, job.Config, 0644); err != nil {
+		return nil, err
+	}
+	defer os.Remove(cfg)
+
+	nlrc := e.NLRC
+	if nlrc == "" {
+		nlrc = DefaultNLRC
+	}
+
+	// Keep any existing log, as NLRC can append to it
+	logFile := job.LogFile
+	var before []byte
+	if logFile != "" {
+		if e.Wine {
+			logFile = UnixPath(logFile)
+		}
+		if !filepath.IsAbs(logFile) {
+			logFile = filepath.Join(dir, logFile)
+		}
+		before, _ = ioutil.ReadFile(logFile)
+	}
+
+	// Build the command
+	var cmd *exec.Cmd
+	if e.Wine {
+		cmd = exec.CommandContext(ctx, "wine", nlrc, "-C"+WinePath(cfg))
+	} else {
+		cmd = exec.CommandContext(ctx, nlrc, "-C"+cfg)
+	}
+	cmd.Dir = dir
+
+	// NLRC returns non-zero on compile errors, which are in the log, so
+	// only fail if it couldn't be run at all
+	out, err := cmd.CombinedOutput()
+	err = exitError(err)
+	if _, ok := err.(*ExitError); err != nil && !ok {
+		return nil, err
+	}
+
+	// Prefer the log file if one was configured
+	if logFile != "" {
+		if b, rerr := ioutil.ReadFile(logFile); rerr == nil {
+			return appended(before, b), err
+		}
+	}
+
+	return out, err
+}
+
+// appended returns the part of a log written by this run. If NLRC appended
+// to the log the earlier runs come first, otherwise the whole log is new
+func appended(before []byte, after []byte) []byte {
+	if len(before) > 0 && bytes.HasPrefix(after, before) {
+		return after[len(before):]
+	}
+	return after
+}
+
+// SSHExecutor runs NLRC on a remote Windows machine over SSH, using the
+// local ssh and scp commands. The config is copied into Dir on the remote
+// machine, so source paths in it must be valid there. Port and Identity are
+// passed to each command with its own flag, and Args are extra options
+// accepted by both, such as -o
+type SSHExecutor struct {
+	Host     string
+	Port     int
+	Identity string
+	NLRC     string
+	Dir      string
+	Args     []string
+}
+
+// flags returns the options for ssh or scp, which differ only in how the
+// port is given
+func (e *SSHExecutor) flags(port string) []string {
+	var fs []string
+	if e.Port != 0 {
+		fs = append(fs, port, strconv.Itoa(e.Port))
+	}
+	if e.Identity != "" {
+		fs = append(fs, "-i", e.Identity)
+	}
+	return append(fs, e.Args...)
+}
+
+// Run implements Executor
+func (e *SSHExecutor) Run(ctx context.Context, job Job) ([]byte, error) {
+
+	if e.Dir == "" {
+		return nil, errors.New("remote directory not set")
+	}
+
+	// Stage the config locally for copying
+	tmp, err := ioutil.TempDir("", "nlrc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	local := filepath.Join(tmp, job.Name)
+	if err := ioutil.WriteFile(local, job.Config, 0644); err != nil {
+		return nil, err
+	}
+
+	// Copy it over
+	remote := strings.TrimSuffix(e.Dir, `\`) + `\` + job.Name
+	scp := exec.CommandContext(ctx, "scp", append(e.flags("-P"), local, e.Host+":"+strings.Replace(remote, `\`, `/`, -1))...)
+	if out, err := scp.CombinedOutput(); err != nil {
+		return nil, errors.New("scp: " + err.Error() + ": " + string(out))
+	}
+
+	nlrc := e.NLRC
+	if nlrc == "" {
+		nlrc = DefaultNLRC
+	}
+
+	// Keep any existing log, as NLRC can append to it
+	logFile := job.LogFile
+	var before []byte
+	if logFile != "" {
+		if !windowsAbs(logFile) {
+			logFile = strings.TrimSuffix(e.Dir, `\`) + `\` + logFile
+		}
+		before, _, err = e.ssh(ctx, `type "`+logFile+`"`)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Run the compiler, keeping its status as compile errors make it non-zero
+	out, code, err := e.ssh(ctx, `"`+nlrc+`" -C"`+remote+`"`)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		err = &ExitError{Code: code}
+	}
+
+	// Prefer the log file if one was configured
+	if logFile != "" {
+		b, c, rerr := e.ssh(ctx, `type "`+logFile+`"`)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if c == 0 {
+			return appended(before, b), err
+		}
+	}
+
+	return out, err
+}
+
+// ssh runs a command line on the remote machine, returning its output and
+// exit status. Windows OpenSSH runs it with cmd /c, which removes the first
+// and last quote from a line starting with one, so the line is wrapped in an
+// extra pair. ssh itself exits with 255 when it can't connect or log in,
+// which is returned as an error
+func (e *SSHExecutor) ssh(ctx context.Context, line string) ([]byte, int, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", append(e.flags("-p"), e.Host, `"`+line+`"`)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if x, ok := err.(*exec.ExitError); ok {
+		if x.ExitCode() == 255 {
+			return nil, 0, errors.New("ssh: " + err.Error() + ": " + strings.TrimSpace(stderr.String()))
+		}
+		return out, x.ExitCode(), nil
+	}
+	return out, 0, err
+}
+
+// windowsAbs returns true for a Windows path with a drive letter or a UNC
+// path
+func windowsAbs(p string) bool {
+	return len(p) > 2 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') || strings.HasPrefix(p, `\\`)
+}
+
+// HTTPExecutor sends the config to a build agent on a Windows machine. The
+// agent is expected to run NLRC with the .cfg POSTed as the body and reply
+// with the compiler log
+type HTTPExecutor struct {
+	URL    string
+	Client *http.Client
+}
+
+// Run implements Executor
+func (e *HTTPExecutor) Run(ctx context.Context, job Job) ([]byte, error) {
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(job.Config))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Config-Name", job.Name)
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("build agent: " + resp.Status + ": " + strings.TrimSpace(string(b)))
+	}
+
+	return b, nil
+}
+
+// ReplayExecutor returns a recorded log instead of running the compiler,
+// allowing builds to be tested on machines without NLRC
+type ReplayExecutor struct {
+	Log  []byte
+	Err  error
+	Jobs []Job
+}
+
+// NewReplayExecutor returns a ReplayExecutor for a recorded log file
+func NewReplayExecutor(fn string) (*ReplayExecutor, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayExecutor{Log: b}, nil
+}
+
+// Run implements Executor, recording the Job for inspection
+func (e *ReplayExecutor) Run(ctx context.Context, job Job) ([]byte, error) {
+	e.Jobs = append(e.Jobs, job)
+	return e.Log, e.Err
+}
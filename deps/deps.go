@@ -0,0 +1,149 @@
+package deps
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// Report holds the result of scanning a workspace's source files
+type Report struct {
+	Graph          *Graph
+	NotInWorkspace []string
+	Unused         []string
+	Unresolved     []Reference
+}
+
+// MasterSources returns the absolute path of every MasterSrc file in the
+// workspace, in project and system order
+func MasterSources(a *apw.APW) []string {
+	var srcs []string
+	for _, p := range a.Workspace.Projects {
+		for _, s := range p.Systems {
+			srcs = append(srcs, SystemSources(a, s)...)
+		}
+	}
+	return srcs
+}
+
+// SystemSources returns the absolute path of each MasterSrc in a system
+func SystemSources(a *apw.APW, s *apw.System) []string {
+	var srcs []string
+	for _, f := range s.Files {
+		if f.Type == "MasterSrc" {
+			srcs = append(srcs, filePath(a, f))
+		}
+	}
+	return srcs
+}
+
+// filePath returns the absolute path of a workspace file
+func filePath(a *apw.APW, f *apw.File) string {
+	p := normalise(f.FilePathName)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(normalise(a.OriginPath), p)
+	}
+	return p
+}
+
+// Scan walks every MasterSrc in the workspace, following #INCLUDE and
+// DEFINE_MODULE statements, and compares the files found to the workspace
+func Scan(a *apw.APW, opts Options) (*Report, error) {
+
+	// Build the graph from each main source file
+	g := NewGraph(workspaceOptions(a, opts))
+	for _, src := range MasterSources(a) {
+		if _, err := g.AddRoot(src); err != nil {
+			// Missing sources are already reported in FilesMissing
+			if contains(a.FilesMissing, src) {
+				continue
+			}
+			return nil, err
+		}
+	}
+
+	r := &Report{
+		Graph:      g,
+		Unresolved: g.Unresolved,
+	}
+
+	// Files used by the source but absent from the workspace
+	inWorkspace := make(map[string]bool)
+	for fn := range a.FilesReferenced {
+		inWorkspace[pathKey(fn)] = true
+	}
+	for k, n := range g.Nodes {
+		if !inWorkspace[k] {
+			r.NotInWorkspace = append(r.NotInWorkspace, n.Path)
+		}
+	}
+
+	// Code files in the workspace that nothing references
+	for fn, t := range a.FilesReferenced {
+		switch strings.ToLower(filepath.Ext(fn)) {
+		case ".axi", ".tko", ".jar":
+		case ".axs":
+			if t != "Module" {
+				continue
+			}
+		default:
+			continue
+		}
+		if g.Find(fn) == nil {
+			r.Unused = append(r.Unused, normalise(fn))
+		}
+	}
+
+	sort.Strings(r.NotInWorkspace)
+	sort.Strings(r.Unused)
+
+	return r, nil
+}
+
+// workspaceOptions adds the folders of files already in the workspace to the
+// search paths
+func workspaceOptions(a *apw.APW, opts Options) Options {
+	includes := append([]string{}, opts.IncludePaths...)
+	modules := append([]string{}, opts.ModulePaths...)
+	// Sort so resolution doesn't depend on map ordering
+	var files []string
+	for fn := range a.FilesReferenced {
+		files = append(files, fn)
+	}
+	sort.Strings(files)
+	for _, fn := range files {
+		switch strings.ToLower(a.FilesReferenced[fn]) {
+		case "include":
+			includes = append(includes, filepath.Dir(normalise(fn)))
+		case "module", "tko", "duet":
+			modules = append(modules, filepath.Dir(normalise(fn)))
+		}
+	}
+	opts.IncludePaths = dedupe(includes)
+	opts.ModulePaths = dedupe(modules)
+	return opts
+}
+
+// dedupe removes repeated paths while preserving order
+func dedupe(paths []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if !seen[pathKey(p)] {
+			seen[pathKey(p)] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if pathKey(x) == pathKey(s) {
+			return true
+		}
+	}
+	return false
+}
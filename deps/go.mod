@@ -0,0 +1,12 @@
+module github.com/soloworks/go-netlinx/deps
+
+go 1.12
+
+require (
+	github.com/soloworks/go-netlinx/apw v0.0.0-20190714191235-a674af7ca695
+	github.com/soloworks/go-netlinx/syntax v0.0.0-20190714191235-a674af7ca695
+)
+
+replace github.com/soloworks/go-netlinx/apw => ../apw
+
+replace github.com/soloworks/go-netlinx/syntax => ../syntax
@@ -0,0 +1,277 @@
+package deps
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/syntax"
+)
+
+// Kind specifies the role a file plays in a build
+type Kind int
+
+// File Kinds for use outside this module
+const (
+	KindSource Kind = iota
+	KindInclude
+	KindModule
+	KindTKO
+	KindDuet
+)
+
+// Kinds for use outside this module
+var kinds = [...]string{
+	"Source",
+	"Include",
+	"Module",
+	"TKO",
+	"Duet",
+}
+
+// String returns the English name of the Kind
+func (k Kind) String() string { return kinds[k] }
+
+// Node is a single file within the dependency graph
+type Node struct {
+	Path string
+	Kind Kind
+	Deps []*Node
+}
+
+// Reference is a single #INCLUDE or DEFINE_MODULE statement
+type Reference struct {
+	From   string
+	Line   int
+	Name   string
+	Module bool
+}
+
+// Options controls how source files are resolved and preprocessed
+type Options struct {
+	IncludePaths []string
+	ModulePaths  []string
+	Defines      []string
+}
+
+// Graph holds every file reachable from a set of root source files
+type Graph struct {
+	Roots      []*Node
+	Nodes      map[string]*Node
+	Unresolved []Reference
+
+	opts    Options
+	res     *resolver
+	tokens  map[string][]syntax.Token
+	modules map[string]bool
+}
+
+// NewGraph returns an empty graph which resolves files using opts
+func NewGraph(opts Options) *Graph {
+	return &Graph{
+		Nodes:   make(map[string]*Node),
+		opts:    opts,
+		res:     newResolver(opts.IncludePaths, opts.ModulePaths),
+		tokens:  make(map[string][]syntax.Token),
+		modules: make(map[string]bool),
+	}
+}
+
+// Find returns the node for a path if it is part of the graph
+func (g *Graph) Find(path string) *Node {
+	return g.Nodes[pathKey(path)]
+}
+
+// AddRoot adds a main source file to the graph and walks all of its
+// dependencies
+func (g *Graph) AddRoot(path string) (*Node, error) {
+
+	n := g.node(normalise(path), KindSource)
+	for _, r := range g.Roots {
+		if r == n {
+			return n, nil
+		}
+	}
+	g.Roots = append(g.Roots, n)
+
+	return n, g.walk(n, n, g.defines(), make(map[string]bool))
+}
+
+// Walk calls fn for every node reachable from n, visiting each once
+func (g *Graph) Walk(n *Node, fn func(*Node)) {
+	seen := make(map[*Node]bool)
+	var visit func(*Node)
+	visit = func(n *Node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		fn(n)
+		for _, d := range n.Deps {
+			visit(d)
+		}
+	}
+	visit(n)
+}
+
+// node returns an existing node or creates a new one
+func (g *Graph) node(path string, k Kind) *Node {
+	n, ok := g.Nodes[pathKey(path)]
+	if !ok {
+		n = &Node{Path: path, Kind: k}
+		g.Nodes[pathKey(path)] = n
+	}
+	return n
+}
+
+// defines returns a fresh copy of the predefined symbols
+func (g *Graph) defines() map[string]bool {
+	d := make(map[string]bool)
+	for _, s := range g.opts.Defines {
+		d[strings.ToUpper(s)] = true
+	}
+	return d
+}
+
+// lexFile returns the tokens for a file, lexing it only once. Lexer errors
+// are left for the compiler to report, keeping the tokens found
+func (g *Graph) lexFile(path string) ([]syntax.Token, error) {
+	if t, ok := g.tokens[pathKey(path)]; ok {
+		return t, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, _ := syntax.Tokens(path, b)
+	g.tokens[pathKey(path)] = t
+	return t, nil
+}
+
+// link adds child as a dependency of n if not already present
+func link(n *Node, child *Node) {
+	for _, d := range n.Deps {
+		if d == child {
+			return
+		}
+	}
+	n.Deps = append(n.Deps, child)
+}
+
+// condition tracks a single #IF_DEFINED / #IF_NOT_DEFINED block
+type condition struct {
+	parent bool
+	met    bool
+}
+
+// walk processes a file in compile order, following includes inline so
+// #DEFINE and #IF_DEFINED behave as they do in the compiler
+func (g *Graph) walk(root *Node, file *Node, defs map[string]bool, visited map[string]bool) error {
+
+	visited[pathKey(file.Path)] = true
+
+	toks, err := g.lexFile(file.Path)
+	if err != nil {
+		return err
+	}
+
+	// Stack of conditional blocks, code is active if the top is
+	var conds []condition
+	active := func() bool {
+		if len(conds) == 0 {
+			return true
+		}
+		c := conds[len(conds)-1]
+		return c.parent && c.met
+	}
+
+	// arg returns the token following i if it is on the same line
+	arg := func(i int, k syntax.Kind) (string, bool) {
+		if i+1 < len(toks) && toks[i+1].Pos.Line == toks[i].Pos.Line && toks[i+1].Kind == k {
+			return toks[i+1].Text, true
+		}
+		return "", false
+	}
+
+	for i, t := range toks {
+		switch t.Kind {
+		case syntax.KindDirective:
+			switch t.Text {
+			case "#IF_DEFINED", "#IF_NOT_DEFINED":
+				name, _ := arg(i, syntax.KindIdent)
+				met := defs[strings.ToUpper(name)]
+				if t.Text == "#IF_NOT_DEFINED" {
+					met = !met
+				}
+				conds = append(conds, condition{parent: active(), met: met})
+			case "#ELSE":
+				if len(conds) > 0 {
+					conds[len(conds)-1].met = !conds[len(conds)-1].met
+				}
+			case "#END_IF":
+				if len(conds) > 0 {
+					conds = conds[:len(conds)-1]
+				}
+			case "#DEFINE":
+				if name, ok := arg(i, syntax.KindIdent); ok && active() {
+					defs[strings.ToUpper(name)] = true
+				}
+			case "#INCLUDE":
+				name, ok := arg(i, syntax.KindString)
+				if !ok || !active() {
+					continue
+				}
+				p := g.res.include(file.Path, root.Path, name)
+				if p == "" {
+					g.Unresolved = append(g.Unresolved, Reference{From: file.Path, Line: t.Pos.Line, Name: name})
+					continue
+				}
+				child := g.node(p, KindInclude)
+				link(file, child)
+				if !visited[pathKey(p)] {
+					if err := g.walk(root, child, defs, visited); err != nil {
+						return err
+					}
+				}
+			}
+
+		case syntax.KindIdent:
+			if !strings.EqualFold(t.Text, "DEFINE_MODULE") || !active() {
+				continue
+			}
+			name, ok := arg(i, syntax.KindString)
+			if !ok {
+				continue
+			}
+			p := g.res.module(file.Path, root.Path, name)
+			if p == "" {
+				g.Unresolved = append(g.Unresolved, Reference{From: file.Path, Line: t.Pos.Line, Name: name, Module: true})
+				continue
+			}
+			child := g.node(p, kindFromExt(p))
+			link(file, child)
+			// Source modules are compiled on their own, so start afresh
+			if child.Kind == KindModule && !g.modules[pathKey(p)] {
+				g.modules[pathKey(p)] = true
+				if err := g.walk(child, child, g.defines(), make(map[string]bool)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// kindFromExt returns the kind of a module file from its extension
+func kindFromExt(p string) Kind {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".tko":
+		return KindTKO
+	case ".jar":
+		return KindDuet
+	case ".axi":
+		return KindInclude
+	}
+	return KindModule
+}
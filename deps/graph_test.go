@@ -0,0 +1,254 @@
+package deps
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fixture writes files, given by slash separated names, under a new folder
+func fixture(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "deps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// describe lists each node as path, kind and dependencies, relative to dir
+func describe(dir string, g *Graph) string {
+	rel := func(p string) string {
+		r, _ := filepath.Rel(dir, p)
+		return filepath.ToSlash(r)
+	}
+	var lines []string
+	for _, n := range g.Nodes {
+		s := rel(n.Path) + " " + n.Kind.String()
+		for _, d := range n.Deps {
+			s += " " + rel(d.Path)
+		}
+		lines = append(lines, s)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+var graphFiles = map[string]string{
+	"main.axs": `PROGRAM_NAME='Main'
+#INCLUDE 'Common'
+#DEFINE USE_EXTRA
+#IF_DEFINED USE_EXTRA
+#INCLUDE 'extra.axi'
+#ELSE
+#INCLUDE 'skipped.axi'
+#END_IF
+#IF_NOT_DEFINED use_extra
+#INCLUDE 'never.axi'
+#END_IF
+#IF_DEFINED PRESET
+#INCLUDE 'preset.axi'
+#END_IF
+// #INCLUDE 'commented.axi'
+(* DEFINE_MODULE 'Hidden' mdlHidden(dvHidden) *)
+sText = "'#INCLUDE ', 39, 'quoted.axi', 39"
+DEFINE_MODULE 'Display' mdlDisplay(dvDisplay)
+DEFINE_MODULE 'Driver' mdlDriver(dvDriver)
+DEFINE_MODULE 'Driver.jar' mdlDuet(dvDuet)
+DEFINE_MODULE 'Missing' mdlMissing(dvMissing)
+#INCLUDE 'nowhere'
+`,
+	"extra.axi":           "#INCLUDE 'includes/common.axi'\n",
+	"skipped.axi":         "",
+	"never.axi":           "",
+	"preset.axi":          "",
+	"commented.axi":       "",
+	"quoted.axi":          "",
+	"Hidden.axs":          "",
+	"includes/COMMON.AXI": "#INCLUDE 'loop'\n",
+	"includes/loop.axi":   "#INCLUDE 'common'\n",
+	"modules/Display.axs": `MODULE_NAME='Display'(DEV dvDisplay)
+#INCLUDE 'display_consts'
+#IF_DEFINED USE_EXTRA
+#INCLUDE 'leak.axi'
+#END_IF
+DEFINE_MODULE 'Display' mdlSelf(dvDisplay)
+`,
+	"modules/display_consts.axi": "",
+	"modules/leak.axi":           "",
+	"modules/Driver.tko":         "",
+	"modules/Driver.jar":         "",
+}
+
+func TestGraph(t *testing.T) {
+
+	dir := fixture(t, graphFiles)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name       string
+		defines    []string
+		graph      string
+		unresolved string
+	}{
+		{
+			name: "defaults",
+			graph: `extra.axi Include includes/COMMON.AXI
+includes/COMMON.AXI Include includes/loop.axi
+includes/loop.axi Include includes/COMMON.AXI
+main.axs Source includes/COMMON.AXI extra.axi modules/Display.axs modules/Driver.tko modules/Driver.jar
+modules/Display.axs Module modules/display_consts.axi modules/Display.axs
+modules/Driver.jar Duet
+modules/Driver.tko TKO
+modules/display_consts.axi Include`,
+			unresolved: "main.axs:21 Missing module, main.axs:22 nowhere",
+		},
+		{
+			name:    "predefined",
+			defines: []string{"preset"},
+			graph: `extra.axi Include includes/COMMON.AXI
+includes/COMMON.AXI Include includes/loop.axi
+includes/loop.axi Include includes/COMMON.AXI
+main.axs Source includes/COMMON.AXI extra.axi preset.axi modules/Display.axs modules/Driver.tko modules/Driver.jar
+modules/Display.axs Module modules/display_consts.axi modules/Display.axs
+modules/Driver.jar Duet
+modules/Driver.tko TKO
+modules/display_consts.axi Include
+preset.axi Include`,
+			unresolved: "main.axs:21 Missing module, main.axs:22 nowhere",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			g := NewGraph(Options{
+				IncludePaths: []string{filepath.Join(dir, "includes")},
+				ModulePaths:  []string{filepath.Join(dir, "modules")},
+				Defines:      tt.defines,
+			})
+			root, err := g.AddRoot(filepath.Join(dir, "main.axs"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := describe(dir, g); got != tt.graph {
+				t.Errorf("graph:\n%s\nwant:\n%s", got, tt.graph)
+			}
+
+			var refs []string
+			for _, r := range g.Unresolved {
+				s := fmt.Sprintf("%s:%d %s", filepath.Base(r.From), r.Line, r.Name)
+				if r.Module {
+					s += " module"
+				}
+				refs = append(refs, s)
+			}
+			if got := strings.Join(refs, ", "); got != tt.unresolved {
+				t.Errorf("unresolved = %q, want %q", got, tt.unresolved)
+			}
+
+			// Each file is visited once despite the include and module cycles
+			seen := make(map[*Node]int)
+			g.Walk(root, func(n *Node) { seen[n]++ })
+			if len(seen) != len(g.Nodes) {
+				t.Errorf("walked %d nodes, want %d", len(seen), len(g.Nodes))
+			}
+			for n, c := range seen {
+				if c != 1 {
+					t.Errorf("%s visited %d times", n.Path, c)
+				}
+			}
+
+			// Adding the same root again changes nothing
+			if again, err := g.AddRoot(filepath.Join(dir, "MAIN.axs")); err != nil || again != root || len(g.Roots) != 1 {
+				t.Errorf("AddRoot again = %v, %v with %d roots", again, err, len(g.Roots))
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	dir := fixture(t, map[string]string{
+		"src/main.axs":      "",
+		"src/lib/util.axi":  "",
+		"src/Local.tko":     "",
+		"inc/Shared.AXI":    "",
+		"inc/util.axi":      "",
+		"mods/Local.axs":    "",
+		"mods/Remote.jar":   "",
+		"mods/Remote.tko":   "",
+		"mods/Source.axs":   "",
+		"mods/Source.tko":   "",
+		"mods/Explicit.axs": "",
+		"mods/Explicit.tko": "",
+	})
+	defer os.RemoveAll(dir)
+
+	r := newResolver([]string{filepath.Join(dir, "inc")}, []string{filepath.Join(dir, "mods")})
+	main := filepath.Join(dir, "src", "main.axs")
+
+	tests := []struct {
+		name   string
+		module bool
+		want   string
+	}{
+		{name: "shared", want: "inc/Shared.AXI"},
+		{name: `lib\util`, want: "src/lib/util.axi"},
+		{name: "util", want: "inc/util.axi"},
+		{name: "missing"},
+		{name: "Local", module: true, want: "src/Local.tko"},
+		{name: "remote", module: true, want: "mods/Remote.tko"},
+		{name: "Source", module: true, want: "mods/Source.axs"},
+		{name: "Explicit.tko", module: true, want: "mods/Explicit.tko"},
+		{name: "Remote.axs", module: true},
+	}
+
+	for _, tt := range tests {
+		var got string
+		if tt.module {
+			got = r.module(main, main, tt.name)
+		} else {
+			got = r.include(main, main, tt.name)
+		}
+		if got != "" {
+			got, _ = filepath.Rel(dir, got)
+			got = filepath.ToSlash(got)
+		}
+		if got != tt.want {
+			t.Errorf("resolve %q = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGraphLexErrors(t *testing.T) {
+
+	// Source the compiler would reject is still followed past the problem
+	dir := fixture(t, map[string]string{
+		"main.axs":  "sText = 'unterminated\n@\n#INCLUDE 'after'\n(* open comment\n",
+		"after.axi": "",
+	})
+	defer os.RemoveAll(dir)
+
+	g := NewGraph(Options{})
+	if _, err := g.AddRoot(filepath.Join(dir, "main.axs")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := describe(dir, g), "after.axi Include\nmain.axs Source after.axi"; got != want {
+		t.Errorf("graph:\n%s\nwant:\n%s", got, want)
+	}
+}
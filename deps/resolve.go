@@ -0,0 +1,110 @@
+package deps
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Extensions tried in order when a DEFINE_MODULE name carries no extension
+var moduleExts = []string{".axs", ".tko", ".jar"}
+
+// normalise converts Windows separators (as stored in .apw files and source)
+// to those of the local platform and cleans the result
+func normalise(p string) string {
+	return filepath.Clean(filepath.FromSlash(strings.Replace(p, `\`, `/`, -1)))
+}
+
+// pathKey returns a case insensitive key for a path, matching the way
+// Windows (and therefore the Netlinx compiler) compares filenames
+func pathKey(p string) string {
+	return strings.ToLower(normalise(p))
+}
+
+// resolver finds files on disk using case insensitive matching
+type resolver struct {
+	includePaths []string
+	modulePaths  []string
+	dirs         map[string]map[string]string
+}
+
+func newResolver(includePaths []string, modulePaths []string) *resolver {
+	r := &resolver{dirs: make(map[string]map[string]string)}
+	for _, p := range includePaths {
+		r.includePaths = append(r.includePaths, normalise(p))
+	}
+	for _, p := range modulePaths {
+		r.modulePaths = append(r.modulePaths, normalise(p))
+	}
+	return r
+}
+
+// find returns the actual path of name within dir, or "" if not present
+func (r *resolver) find(dir string, name string) string {
+
+	p := filepath.Join(dir, name)
+	d, base := filepath.Split(p)
+	d = filepath.Clean(d)
+
+	// Cache directory listings as the same folders are searched repeatedly
+	entries, ok := r.dirs[pathKey(d)]
+	if !ok {
+		entries = make(map[string]string)
+		files, _ := ioutil.ReadDir(d)
+		for _, f := range files {
+			if !f.IsDir() {
+				entries[strings.ToLower(f.Name())] = f.Name()
+			}
+		}
+		r.dirs[pathKey(d)] = entries
+	}
+
+	if actual, ok := entries[strings.ToLower(base)]; ok {
+		return filepath.Join(d, actual)
+	}
+	return ""
+}
+
+// search looks for name in each of the dirs in turn
+func (r *resolver) search(dirs []string, name string) string {
+	if filepath.IsAbs(name) {
+		return r.find(filepath.Dir(name), filepath.Base(name))
+	}
+	for _, d := range dirs {
+		if p := r.find(d, name); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// include resolves an #INCLUDE name referenced from the file at from
+func (r *resolver) include(from string, root string, name string) string {
+	name = normalise(name)
+	if filepath.Ext(name) == "" {
+		name += ".axi"
+	}
+	dirs := append([]string{filepath.Dir(from), filepath.Dir(root)}, r.includePaths...)
+	return r.search(dirs, name)
+}
+
+// module resolves a DEFINE_MODULE name referenced from the file at from
+func (r *resolver) module(from string, root string, name string) string {
+	name = normalise(name)
+	dirs := append([]string{filepath.Dir(from), filepath.Dir(root)}, r.modulePaths...)
+
+	// Use the extension if one was supplied
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".axs", ".tko", ".jar":
+		return r.search(dirs, name)
+	}
+
+	for _, d := range dirs {
+		for _, ext := range moduleExts {
+			if p := r.search([]string{d}, name+ext); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
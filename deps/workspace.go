@@ -0,0 +1,85 @@
+package deps
+
+import (
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// Addition records a file added to a workspace system
+type Addition struct {
+	Project string
+	System  string
+	Path    string
+	File    *apw.File
+}
+
+// AddReport holds the result of adding dependencies to a workspace
+type AddReport struct {
+	Added      []Addition
+	Unresolved []Reference
+}
+
+// AddDependencies walks each system's MasterSrc files and adds any include
+// or module they use which isn't already part of that system
+func AddDependencies(a *apw.APW, opts Options) (*AddReport, error) {
+
+	opts = workspaceOptions(a, opts)
+	r := &AddReport{}
+
+	for _, p := range a.Workspace.Projects {
+		for _, s := range p.Systems {
+
+			// Each system is a separate build, so gets its own graph
+			g := NewGraph(opts)
+			for _, src := range SystemSources(a, s) {
+				if _, err := g.AddRoot(src); err != nil {
+					if contains(a.FilesMissing, src) {
+						continue
+					}
+					return nil, err
+				}
+			}
+			r.Unresolved = append(r.Unresolved, g.Unresolved...)
+
+			// Add everything reachable in the order it was found
+			for _, root := range g.Roots {
+				g.Walk(root, func(n *Node) {
+					t, c := fileType(n.Kind)
+					f := a.AddSystemFile(s, n.Path, t, c)
+					if f == nil {
+						return
+					}
+					r.Added = append(r.Added, Addition{
+						Project: p.Identifier,
+						System:  s.Identifier,
+						Path:    n.Path,
+						File:    f,
+					})
+				})
+			}
+		}
+	}
+
+	// Bring the file lists up to date with the changes
+	if len(r.Added) > 0 {
+		if err := a.RefreshFiles(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// fileType returns the workspace file and compile types for a kind of node
+func fileType(k Kind) (apw.Type, apw.CompileType) {
+	switch k {
+	case KindInclude:
+		return apw.TypeInclude, apw.CompileTypeNetlinx
+	case KindTKO:
+		return apw.TypeTKO, apw.CompileTypeNone
+	case KindDuet:
+		return apw.TypeDuet, apw.CompileTypeNone
+	case KindModule:
+		return apw.TypeModule, apw.CompileTypeNetlinx
+	}
+	return apw.TypeSource, apw.CompileTypeNetlinx
+}
@@ -0,0 +1,114 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+const workspaceAPW = `<?xml version="1.0" encoding="utf-8"?>
+<Workspace CurrentVersion="4.0"><Identifier>Site</Identifier><CreateVersion>4.0</CreateVersion>
+<Project><Identifier>Boardroom</Identifier>
+<System IsActive="true" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>Main</Identifier><SysID>1</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>main</Identifier><FilePathName>Source\main.axs</FilePathName></File>
+<File CompileType="Netlinx" Type="Include"><Identifier>a</Identifier><FilePathName>includes\a.axi</FilePathName></File>
+</System>
+<System IsActive="false" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>Spare</Identifier><SysID>2</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>spare</Identifier><FilePathName>Source\spare.axs</FilePathName></File>
+</System></Project></Workspace>
+`
+
+func TestAddDependencies(t *testing.T) {
+
+	dir := fixture(t, map[string]string{
+		"Site.apw": workspaceAPW,
+		"Source/main.axs": `PROGRAM_NAME='Main'
+#INCLUDE 'a'
+DEFINE_MODULE 'Display' mdlDisplay(dvDisplay)
+DEFINE_MODULE 'Driver' mdlDriver(dvDriver)
+DEFINE_MODULE 'Duet.jar' mdlDuet(dvDuet)
+`,
+		"Source/spare.axs":      "#INCLUDE 'b'\n",
+		"Includes/A.axi":        "#INCLUDE 'b'\n",
+		"Includes/b.axi":        "",
+		"Modules/Display.axs":   "#INCLUDE 'display'\n",
+		"Modules/display.axi":   "",
+		"Modules/Driver.tko":    "",
+		"Modules/Duet.jar":      "",
+		"Modules/Unused.tko":    "",
+		"Source/unrelated.axi":  "",
+		"Includes/unrelated.ax": "",
+	})
+	defer os.RemoveAll(dir)
+
+	a, err := apw.LoadAPW(filepath.Join(dir, "Site.apw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{ModulePaths: []string{filepath.Join(dir, "Modules")}}
+
+	r, err := AddDependencies(a, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each system gains what its own source uses, in the order found, with
+	// files already present ignored whatever their case
+	var added []string
+	for _, ad := range r.Added {
+		f := ad.File
+		added = append(added, strings.Join([]string{ad.System, f.Type, f.CompileType, f.Identifier, f.FilePathName}, " "))
+	}
+	want := []string{
+		`Main Include Netlinx b Includes\b.axi`,
+		`Main Module Netlinx Display Modules\Display.axs`,
+		`Main Include Netlinx display Modules\display.axi`,
+		`Main TKO None Driver Modules\Driver.tko`,
+		`Main Duet None Duet Modules\Duet.jar`,
+		`Spare Include Netlinx b Includes\b.axi`,
+	}
+	if got := strings.Join(added, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("added:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+	if len(r.Unresolved) != 0 {
+		t.Errorf("unresolved = %+v", r.Unresolved)
+	}
+
+	// The systems hold the new files, each once
+	main := a.Workspace.Projects[0].Systems[0]
+	if len(main.Files) != 7 {
+		t.Errorf("Main has %d files, want 7", len(main.Files))
+	}
+	seen := make(map[string]bool)
+	for _, f := range main.Files {
+		k := strings.ToLower(f.FilePathName)
+		if seen[k] {
+			t.Errorf("%s added twice", f.FilePathName)
+		}
+		seen[k] = true
+	}
+
+	// The file lists are refreshed, so a scan finds nothing missing
+	if _, ok := a.FilesReferenced[filepath.Join(dir, "Modules", "Driver.tko")]; !ok {
+		t.Errorf("Driver.tko not referenced: %v", a.FilesReferenced)
+	}
+	s, err := Scan(a, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.NotInWorkspace) != 0 || len(s.Unused) != 0 {
+		t.Errorf("scan found %q missing, %q unused", s.NotInWorkspace, s.Unused)
+	}
+
+	// Adding again changes nothing
+	r, err = AddDependencies(a, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Added) != 0 {
+		t.Errorf("added %d files again", len(r.Added))
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/soloworks/go-netlinx/apw"
+	"github.com/soloworks/go-netlinx/ftp/transfer"
+)
+
+type amxSys struct {
+	Name     string `json:"Name"`
+	Host     string `json:"Host"`
+	Port     int    `json:"Port"`
+	Username string `json:"Username"`
+	Password string `json:"Password"`
+}
+
+type configFile struct {
+	DestPath  string
+	Filename  string
+	Mask      string   `json:"Mask"`
+	Root      string   `json:"Root"`
+	Source    string   `json:"Source"`
+	Include   []string `json:"Include"`
+	Exclude   []string `json:"Exclude"`
+	Recursive bool     `json:"Recursive"`
+	Sync      bool     `json:"Sync"`
+	Verify    bool     `json:"Verify"`
+	Backup    string   `json:"Backup"`
+	Keep      int      `json:"Keep"`
+	Every     string   `json:"Every"`
+	APW       string   `json:"APW"`
+	Project   string   `json:"ProjectFilter"`
+	System    string   `json:"SystemFilter"`
+	AmxSys    []amxSys `json:"Systems"`
+}
+
+// targetFlags are the command line options for finding systems in APWs
+type targetFlags struct {
+	APW     string
+	Project string
+	System  string
+}
+
+// addTargetFlags registers the APW flags shared by every command
+func addTargetFlags(fs *flag.FlagSet, tf *targetFlags) {
+	fs.StringVar(&tf.APW, "APW", "", "Workspace file, or folder to search, for systems (or set APW in the config)")
+	fs.StringVar(&tf.Project, "Project", "", "Only use projects matching this pattern (or set ProjectFilter in the config)")
+	fs.StringVar(&tf.System, "System", "", "Only use systems matching this pattern (or set SystemFilter in the config)")
+}
+
+// load reads the config file over any values already set, then applies the
+// command line APW options and returns the systems to use, exiting on error.
+// The config file is optional when an APW is given
+func (cf *configFile) load(tf targetFlags) []transfer.Target {
+
+	// Load in Config Settings
+	file, err := os.Open(cf.Filename)
+	if err != nil && !(os.IsNotExist(err) && tf.APW != "") {
+		log.Println("Error Loading config file " + cf.Filename)
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// Read Config Settings
+	if err == nil {
+		decoder := json.NewDecoder(file)
+		err = decoder.Decode(cf)
+		file.Close()
+		if err != nil {
+			log.Println("Config File Error: ")
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Command line values win over the config
+	if tf.APW != "" {
+		cf.APW = tf.APW
+	}
+	if tf.Project != "" {
+		cf.Project = tf.Project
+	}
+	if tf.System != "" {
+		cf.System = tf.System
+	}
+
+	ts, err := cf.targets()
+	if err != nil {
+		log.Println("APW Error: ")
+		log.Println(err)
+		os.Exit(1)
+	}
+	if len(ts) == 0 {
+		log.Println("Config File Error: no Systems")
+		os.Exit(1)
+	}
+	return ts
+}
+
+// filter returns the configured patterns, exiting if any are malformed
+func (cf *configFile) filter() transfer.Filter {
+	f := transfer.Filter{Include: cf.Include, Exclude: cf.Exclude}
+	if err := f.Validate(); err != nil {
+		log.Println("Config File Error: ")
+		log.Println(err)
+		os.Exit(1)
+	}
+	return f
+}
+
+// targets returns the systems found in the APW, if set, with the systems
+// in the config file applied as overrides
+func (cf *configFile) targets() ([]transfer.Target, error) {
+
+	var ts []transfer.Target
+	for _, s := range cf.AmxSys {
+		ts = append(ts, transfer.Target{
+			Name:     s.Name,
+			Host:     s.Host,
+			Port:     s.Port,
+			Username: s.Username,
+			Password: s.Password,
+		})
+	}
+	if cf.APW == "" {
+		return ts, nil
+	}
+
+	apws, err := loadAPWs(cf.APW)
+	if err != nil {
+		return nil, err
+	}
+	found, err := transfer.APWTargets(apws, cf.Project, cf.System)
+	if err != nil {
+		return nil, err
+	}
+	return transfer.Merge(found, ts), nil
+}
+
+// loadAPWs loads a workspace, or every workspace in and below a folder
+func loadAPWs(fn string) ([]*apw.APW, error) {
+
+	info, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		a, err := apw.LoadAPW(fn)
+		if err != nil {
+			return nil, err
+		}
+		return []*apw.APW{a}, nil
+	}
+
+	apws := apw.FindAPWs(fn, true)
+	if len(apws) == 0 {
+		return nil, errors.New("no .apw files found in " + fn)
+	}
+	return apws, nil
+}
+
+// addOptionFlags registers the connection flags shared by every command
+func addOptionFlags(fs *flag.FlagSet, opts *transfer.Options) {
+	fs.IntVar(&opts.Concurrency, "Concurrency", opts.Concurrency, "Systems to connect to at once")
+	fs.DurationVar(&opts.Timeout, "Timeout", opts.Timeout, "Time allowed for each system")
+	fs.IntVar(&opts.Retries, "Retries", opts.Retries, "Retries for each system after a failure")
+}
+
+// interruptContext returns a context which is cancelled by Ctrl+C
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	return ctx
+}
+
+// finish prints the summary and exits 1 if any system failed
+func finish(results []transfer.Result) {
+	transfer.Summary(os.Stdout, results)
+	if transfer.Failed(results) {
+		os.Exit(1)
+	}
+}
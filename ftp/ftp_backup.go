@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/soloworks/go-netlinx/ftp/transfer"
+)
+
+// backup saves versioned copies of files from every system, once or on a
+// schedule
+func backup(args []string) {
+
+	// Set ConfigFile Variable
+	cf := configFile{Keep: transfer.DefaultKeep}
+	opts := transfer.DefaultOptions()
+	var tf targetFlags
+	var recursive bool
+	var keep int
+	var every time.Duration
+
+	// Get Command Line Variables
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fs.StringVar(&cf.Filename, "Config", "ftp_backup.json", "Config File name")
+	fs.StringVar(&cf.DestPath, "Dest", "backups", "Backup File Path (one folder per system)")
+	addTargetFlags(fs, &tf)
+	addOptionFlags(fs, &opts)
+	fs.BoolVar(&recursive, "Recursive", false, "Include sub folders (or set Recursive in the config)")
+	fs.IntVar(&keep, "Keep", 0, "Backups to keep for each system (or set Keep in the config, default 10)")
+	fs.DurationVar(&every, "Every", 0, "Repeat the backup at this interval until stopped (or set Every in the config)")
+	fs.Parse(args)
+
+	targets := cf.load(tf)
+
+	// Command line values win over the config
+	if keep > 0 {
+		cf.Keep = keep
+	}
+	if every == 0 && cf.Every != "" {
+		d, err := time.ParseDuration(cf.Every)
+		if err != nil {
+			log.Println("Config File Error: ")
+			log.Println(err)
+			os.Exit(1)
+		}
+		every = d
+	}
+
+	opts.Log = log.New(os.Stderr, "", log.LstdFlags)
+	b := &transfer.Backup{
+		Dest:      cf.DestPath,
+		Root:      cf.Root,
+		Filter:    cf.filter(),
+		Recursive: cf.Recursive || recursive,
+		Keep:      cf.Keep,
+		Options:   opts,
+	}
+	if len(b.Filter.Include) == 0 && cf.Mask != "" {
+		b.Filter.Include = []string{cf.Mask}
+	}
+
+	ctx := interruptContext()
+	for {
+		results := b.Run(ctx, targets)
+		transfer.ChangeReport(os.Stdout, results)
+		if every <= 0 {
+			finish(results)
+			return
+		}
+		transfer.Summary(os.Stdout, results)
+
+		log.Println("Next backup at " + time.Now().Add(every).Format("15:04:05"))
+		select {
+		case <-ctx.Done():
+			// Stopping a schedule early is a failure for callers
+			log.Println("Backup stopped")
+			os.Exit(1)
+		case <-time.After(every):
+		}
+	}
+}
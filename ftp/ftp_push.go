@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/soloworks/go-netlinx/ftp/transfer"
+)
+
+// push uploads files to every system
+func push(args []string) {
+
+	// Set ConfigFile Variable
+	cf := configFile{}
+	opts := transfer.DefaultOptions()
+	var tf targetFlags
+	var recursive, dryRun, verify bool
+	var source, backup string
+
+	// Get Command Line Variables
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	fs.StringVar(&cf.Filename, "Config", "ftp_push.json", "Config File name")
+	fs.StringVar(&source, "Source", "", "Local file or folder to upload (or set Source in the config)")
+	addTargetFlags(fs, &tf)
+	addOptionFlags(fs, &opts)
+	fs.BoolVar(&recursive, "Recursive", false, "Include sub folders (or set Recursive in the config)")
+	fs.BoolVar(&dryRun, "DryRun", false, "List what would be uploaded without changing anything")
+	fs.BoolVar(&verify, "Verify", false, "Download each file after upload and compare checksums (or set Verify in the config)")
+	fs.StringVar(&backup, "Backup", "", "Save remote files to this folder before replacing them (or set Backup in the config)")
+	fs.Parse(args)
+
+	targets := cf.load(tf)
+
+	// Command line values win over the config
+	if source != "" {
+		cf.Source = source
+	}
+	if backup != "" {
+		cf.Backup = backup
+	}
+	if cf.Source == "" {
+		log.Println("Config File Error: no Source")
+		os.Exit(1)
+	}
+
+	opts.Log = log.New(os.Stderr, "", log.LstdFlags)
+	p := &transfer.Pusher{
+		Source:    cf.Source,
+		Root:      cf.Root,
+		Filter:    cf.filter(),
+		Recursive: cf.Recursive || recursive,
+		DryRun:    dryRun,
+		Verify:    cf.Verify || verify,
+		Backup:    cf.Backup,
+		Options:   opts,
+	}
+
+	results := p.Push(interruptContext(), targets)
+	if dryRun {
+		log.Println("Dry run: nothing was uploaded")
+	}
+	finish(results)
+}
@@ -2,4 +2,9 @@ module github.com/soloworks/go-netlinx/ftp
 
 go 1.12
 
-require github.com/jlaffaye/ftp v0.0.0-20190427163646-6a014d5e22e6
+require (
+	github.com/jlaffaye/ftp v0.0.0-20190427163646-6a014d5e22e6
+	github.com/soloworks/go-netlinx/apw v0.0.0-20190714191235-a674af7ca695
+)
+
+replace github.com/soloworks/go-netlinx/apw => ../apw
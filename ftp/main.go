@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+func main() {
+
+	// The command defaults to pull, as it was the only one
+	cmd, args := "pull", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "pull":
+		pull(args)
+	case "push":
+		push(args)
+	case "backup":
+		backup(args)
+	default:
+		println("Unknown command " + cmd)
+		println("Usage: ftp [pull|push|backup] [flags]")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command instead of the tests when the test binary is
+// started by runMain
+func TestMain(m *testing.M) {
+	if args := os.Getenv("FTP_TEST_ARGS"); args != "" {
+		os.Args = append([]string{"ftp"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// command returns the command with args, run by this test binary
+func command(args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "FTP_TEST_ARGS="+strings.Join(args, "\n"))
+	return cmd
+}
+
+// runMain runs the command and returns its output and exit status
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	var out bytes.Buffer
+	cmd := command(args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if e, ok := err.(*exec.ExitError); ok {
+		return out.String(), e.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), 0
+}
+
+// offlineConfig writes a config with one system nothing is listening for
+func offlineConfig(t *testing.T, dir string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	fn := filepath.Join(dir, "config.json")
+	cfg := `{"Mask": "*.tkn", "Systems": [{"Name": "Offline", "Host": "127.0.0.1", "Port": ` + strconv.Itoa(port) + `}]}`
+	if err := ioutil.WriteFile(fn, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestPullFailureExitStatus(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out, code := runMain(t, "pull", "-Config", offlineConfig(t, dir), "-Dest", filepath.Join(dir, "files"), "-Retries", "0")
+	if code != 1 {
+		t.Errorf("exit status = %d, want 1\n%s", code, out)
+	}
+	if !strings.Contains(out, "FAILED") || !strings.Contains(out, "0 of 1 targets succeeded") {
+		t.Errorf("output doesn't report the failure:\n%s", out)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if out, code := runMain(t, "fetch"); code != 1 || !strings.Contains(out, "Unknown command fetch") {
+		t.Errorf("exit status = %d, want 1\n%s", code, out)
+	}
+}
+
+func TestScheduledBackupInterrupted(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt can't be sent on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "ftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := command("backup", "-Config", offlineConfig(t, dir), "-Dest", filepath.Join(dir, "backups"), "-Retries", "0", "-Every", "1h")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupt once the first run is done and it is waiting for the next
+	sc := bufio.NewScanner(stderr)
+	for sc.Scan() {
+		if strings.Contains(sc.Text(), "Next backup at") {
+			cmd.Process.Signal(os.Interrupt)
+			break
+		}
+	}
+	ioutil.ReadAll(stderr)
+
+	err = cmd.Wait()
+	if e, ok := err.(*exec.ExitError); !ok || e.ExitCode() != 1 {
+		t.Errorf("interrupted backup ended with %v, want exit status 1", err)
+	}
+}
@@ -0,0 +1,111 @@
+package transfer
+
+import (
+	"path"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// APWTargets returns a Target for each system in the workspaces which has a
+// TCP/IP connection. Projects and systems are matched on their Identifier
+// by case-insensitive path.Match patterns, where an empty pattern matches
+// all. Systems sharing an address with an earlier one are left out
+func APWTargets(apws []*apw.APW, project string, system string) ([]Target, error) {
+
+	for _, p := range []string{project, system} {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var ts []Target
+	seen := map[string]bool{}
+
+	for _, a := range apws {
+		if a.Workspace == nil {
+			continue
+		}
+		for _, p := range a.Workspace.Projects {
+			if !matchFold(project, p.Identifier) {
+				continue
+			}
+			for _, s := range p.Systems {
+				if !matchFold(system, s.Identifier) {
+					continue
+				}
+				ip := s.IPTransport()
+				if ip == nil {
+					continue
+				}
+				// The transport port is for ICSP, so FTP uses the default
+				t := Target{
+					Name:     p.Identifier + "/" + s.Identifier,
+					Host:     ip.Host,
+					Username: ip.Username,
+					Password: ip.Password,
+				}
+				if seen[t.Addr()] {
+					continue
+				}
+				seen[t.Addr()] = true
+				ts = append(ts, t)
+			}
+		}
+	}
+
+	return ts, nil
+}
+
+// Merge applies overrides to targets, matched by Name or Host. Fields set in
+// an override replace those in the Target, and overrides which match
+// nothing are added to the end
+func Merge(targets []Target, overrides []Target) []Target {
+
+	ts := append([]Target(nil), targets...)
+
+	for _, o := range overrides {
+		found := false
+		for i := range ts {
+			if (o.Name != "" && o.Name == ts[i].Name) || (o.Host != "" && o.Host == ts[i].Host) {
+				ts[i] = ts[i].override(o)
+				found = true
+			}
+		}
+		if !found {
+			ts = append(ts, o)
+		}
+	}
+
+	return ts
+}
+
+// override returns t with any fields set in o replaced
+func (t Target) override(o Target) Target {
+	if o.Name != "" {
+		t.Name = o.Name
+	}
+	if o.Host != "" {
+		t.Host = o.Host
+	}
+	if o.Port != 0 {
+		t.Port = o.Port
+	}
+	if o.Username != "" {
+		t.Username = o.Username
+	}
+	if o.Password != "" {
+		t.Password = o.Password
+	}
+	return t
+}
+
+// matchFold matches a pattern ignoring case, with an empty pattern
+// matching everything
+func matchFold(pattern string, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return ok
+}
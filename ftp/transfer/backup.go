@@ -0,0 +1,276 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultKeep is the number of backups kept for each Target
+const DefaultKeep = 10
+
+// ChangeType is the way a file differs from the previous backup
+type ChangeType int
+
+// Change types
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+var changeTypes = [...]string{
+	"added",
+	"removed",
+	"modified",
+}
+
+func (c ChangeType) String() string {
+	return changeTypes[c]
+}
+
+// Change is a file which differs from the previous backup, by its path
+// relative to the backup folder
+type Change struct {
+	Type ChangeType
+	Path string
+}
+
+// Backup downloads matching files from controllers into a new timestamped
+// folder per Target under Dest, keeping the most recent Keep. A backup
+// which is identical to the previous one is discarded, so every folder
+// kept is a different version. Keep of 0 keeps everything
+type Backup struct {
+	Dest      string
+	Root      string
+	Filter    Filter
+	Recursive bool
+	Keep      int
+	Options
+}
+
+// NewBackup returns a Backup with the default Options
+func NewBackup(dest string) *Backup {
+	return &Backup{Dest: dest, Keep: DefaultKeep, Options: DefaultOptions()}
+}
+
+// Run backs up every Target, returning a Result for each with the files
+// changed since its last backup
+func (b *Backup) Run(ctx context.Context, targets []Target) []Result {
+	stamp := time.Now().Format(BackupTimeFormat)
+	return Run(ctx, targets, b.Options, func(ctx context.Context, c *Conn, t Target, r *Result) error {
+		return b.backup(ctx, c, t, r, stamp)
+	})
+}
+
+// backup is the JobFunc for a single Target. Files are downloaded into a
+// hidden folder which is only renamed once complete
+func (b *Backup) backup(ctx context.Context, c *Conn, t Target, r *Result, stamp string) error {
+
+	dir := filepath.Join(b.Dest, t.Folder())
+	tmp := filepath.Join(dir, "."+stamp)
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	p := Puller{Root: b.Root, Filter: b.Filter, Recursive: b.Recursive, Options: b.Options}
+	if err := p.pullInto(ctx, c, t, r, tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, os.ModePerm); err != nil {
+		return err
+	}
+
+	versions, err := Snapshots(dir)
+	if err != nil {
+		return err
+	}
+	prev := ""
+	if len(versions) > 0 {
+		prev = filepath.Join(dir, versions[len(versions)-1])
+	}
+
+	r.Changes, err = compareDirs(prev, tmp)
+	if err != nil {
+		return err
+	}
+	if prev != "" && len(r.Changes) == 0 {
+		b.logf(t, "no changes since "+filepath.Base(prev))
+		r.Files = moved(r.Files, tmp, prev)
+		return nil
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, stamp)); err != nil {
+		return err
+	}
+	r.Files = moved(r.Files, tmp, filepath.Join(dir, stamp))
+	b.logf(t, "saved backup "+stamp)
+
+	return b.prune(dir, append(versions, stamp))
+}
+
+// moved returns the paths of files in from as they are once in to, so a
+// Result names files which are kept rather than the download folder
+func moved(files []string, from string, to string) []string {
+	var fns []string
+	for _, fn := range files {
+		if rel, err := filepath.Rel(from, fn); err == nil {
+			fn = filepath.Join(to, rel)
+		}
+		fns = append(fns, fn)
+	}
+	return fns
+}
+
+// prune removes the oldest backups beyond Keep
+func (b *Backup) prune(dir string, versions []string) error {
+	if b.Keep < 1 {
+		return nil
+	}
+	for len(versions) > b.Keep {
+		if err := os.RemoveAll(filepath.Join(dir, versions[0])); err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+// Snapshots returns the backup folders in dir, oldest first. A missing dir
+// has none
+func Snapshots(dir string) ([]string, error) {
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(BackupTimeFormat, info.Name()); err == nil {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// compareDirs lists the files added, removed or modified in dir since old.
+// An empty old means every file is added
+func compareDirs(old string, dir string) ([]Change, error) {
+
+	before, err := listFiles(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := listFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for rel, fn := range after {
+		ofn, ok := before[rel]
+		if !ok {
+			changes = append(changes, Change{ChangeAdded, rel})
+			continue
+		}
+		same, err := sameContent(ofn, fn)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			changes = append(changes, Change{ChangeModified, rel})
+		}
+	}
+	for rel := range before {
+		if _, ok := after[rel]; !ok {
+			changes = append(changes, Change{ChangeRemoved, rel})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// listFiles maps the / separated relative path of every file below dir to
+// its full path
+func listFiles(dir string) (map[string]string, error) {
+
+	files := map[string]string{}
+	if dir == "" {
+		return files, nil
+	}
+
+	err := filepath.Walk(dir, func(fn string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fn
+		return nil
+	})
+
+	return files, err
+}
+
+// sameContent returns true if two files hold the same bytes
+func sameContent(a string, b string) (bool, error) {
+
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if ia.Size() != ib.Size() {
+		return false, nil
+	}
+
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	ba := make([]byte, 32*1024)
+	bb := make([]byte, 32*1024)
+	for {
+		na, erra := io.ReadFull(fa, ba)
+		nb, errb := io.ReadFull(fb, bb)
+		if na != nb || !bytes.Equal(ba[:na], bb[:nb]) {
+			return false, nil
+		}
+		if erra == io.EOF || erra == io.ErrUnexpectedEOF {
+			return errb == io.EOF || errb == io.ErrUnexpectedEOF, nil
+		}
+		if erra != nil {
+			return false, erra
+		}
+		if errb != nil {
+			return false, errb
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+
+	remote := tempDir(t, map[string]string{
+		"main.tkn":      "v1",
+		"notes.txt":     "hello",
+		"sub/other.tkn": "nested",
+	})
+	defer os.RemoveAll(remote)
+	dest := tempDir(t, nil)
+	defer os.RemoveAll(dest)
+
+	s := newFakeFTP(remote)
+	defer s.close()
+	target := s.target("Main")
+	dir := filepath.Join(dest, target.Folder())
+
+	b := NewBackup(dest)
+	b.Filter = Filter{Include: []string{"*.tkn"}}
+	b.Recursive = true
+	b.Keep = 2
+	b.Options = testOptions()
+
+	// Run with a fixed stamp per backup, as real ones are a second apart
+	backup := func(stamp string) Result {
+		t.Helper()
+		rs := Run(context.Background(), []Target{target}, b.Options, func(ctx context.Context, c *Conn, tg Target, r *Result) error {
+			return b.backup(ctx, c, tg, r, stamp)
+		})
+		if rs[0].Err != nil {
+			t.Fatalf("backup %s failed: %v", stamp, rs[0].Err)
+		}
+		return rs[0]
+	}
+
+	// check compares the changes and that every file reported is in the
+	// snapshot kept
+	check := func(r Result, snapshot string, changes string) {
+		t.Helper()
+		var cs []string
+		for _, c := range r.Changes {
+			cs = append(cs, c.Type.String()+" "+c.Path)
+		}
+		if got := strings.Join(cs, ", "); got != changes {
+			t.Errorf("changes = %q, want %q", got, changes)
+		}
+		if len(r.Files) == 0 {
+			t.Error("no files reported")
+		}
+		for _, fn := range r.Files {
+			if !strings.HasPrefix(fn, filepath.Join(dir, snapshot)+string(filepath.Separator)) {
+				t.Errorf("file %s not in snapshot %s", fn, snapshot)
+			}
+			if _, err := os.Stat(fn); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	snapshots := func(want ...string) {
+		t.Helper()
+		got, err := Snapshots(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("snapshots = %q, want %q", got, want)
+		}
+		// Nothing is left behind from the downloads
+		infos, _ := ioutil.ReadDir(dir)
+		if len(infos) != len(got) {
+			t.Errorf("%d entries in %s, want %d", len(infos), dir, len(got))
+		}
+	}
+
+	r := backup("20190714-100000")
+	check(r, "20190714-100000", "added main.tkn, added sub/other.tkn")
+	snapshots("20190714-100000")
+
+	// An identical backup isn't kept, and names the files in the last one
+	r = backup("20190714-110000")
+	check(r, "20190714-100000", "")
+	snapshots("20190714-100000")
+
+	if err := ioutil.WriteFile(filepath.Join(remote, "main.tkn"), []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(remote, "sub", "other.tkn")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(remote, "new.tkn"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = backup("20190714-120000")
+	check(r, "20190714-120000", "modified main.tkn, added new.tkn, removed sub/other.tkn")
+	snapshots("20190714-100000", "20190714-120000")
+
+	// Only the newest Keep backups are kept
+	if err := ioutil.WriteFile(filepath.Join(remote, "main.tkn"), []byte("v3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = backup("20190714-130000")
+	check(r, "20190714-130000", "modified main.tkn")
+	snapshots("20190714-120000", "20190714-130000")
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "20190714-130000", "main.tkn")); string(b) != "v3" {
+		t.Errorf("main.tkn = %q, want %q", b, "v3")
+	}
+}
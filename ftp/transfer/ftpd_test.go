@@ -0,0 +1,246 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fakeFTP is a minimal FTP server for a local folder, understanding just
+// the commands used by this package
+type fakeFTP struct {
+	root string
+	ln   net.Listener
+	user string
+	pass string
+	mu   sync.Mutex
+	cmds []string
+
+	// dropStor drops the connection on this upload, counting from 1
+	dropStor int
+	stors    int
+}
+
+// newFakeFTP starts a server for root on a free local port, accepting the
+// default controller login
+func newFakeFTP(root string) *fakeFTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	f := &fakeFTP{root: root, ln: ln, user: "administrator", pass: "password"}
+	go f.serve()
+	return f
+}
+
+// target returns a Target for the server
+func (f *fakeFTP) target(name string) Target {
+	a := f.ln.Addr().(*net.TCPAddr)
+	return Target{Name: name, Host: a.IP.String(), Port: a.Port, Username: "administrator", Password: "password"}
+}
+
+// close stops accepting connections
+func (f *fakeFTP) close() {
+	f.ln.Close()
+}
+
+// commands returns every command received so far
+func (f *fakeFTP) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.cmds...)
+}
+
+// serve accepts connections until the listener is closed
+func (f *fakeFTP) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(c)
+	}
+}
+
+// local returns the file for a remote path
+func (f *fakeFTP) local(cwd string, p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(cwd, p)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(path.Clean(p)))
+}
+
+// handle runs the control connection for one client
+func (f *fakeFTP) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	say := func(s string) { fmt.Fprintf(c, "%s\r\n", s) }
+	say("220 fake")
+	cwd := "/"
+	var dl net.Listener
+	var user string
+	var rnfr string
+	data := func() net.Conn {
+		if dl == nil {
+			return nil
+		}
+		dc, _ := dl.Accept()
+		dl.Close()
+		dl = nil
+		return dc
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		f.mu.Lock()
+		f.cmds = append(f.cmds, line)
+		f.mu.Unlock()
+		cmd, arg := line, ""
+		if i := strings.Index(line, " "); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		switch strings.ToUpper(cmd) {
+		case "USER":
+			user = arg
+			say("331 pass")
+		case "PASS":
+			if user == f.user && arg == f.pass {
+				say("230 ok")
+			} else {
+				say("530 bad login")
+			}
+		case "FEAT":
+			say("211 no features")
+		case "TYPE", "OPTS", "NOOP":
+			say("200 ok")
+		case "EPSV":
+			dl, _ = net.Listen("tcp", "127.0.0.1:0")
+			say(fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)", dl.Addr().(*net.TCPAddr).Port))
+		case "PWD":
+			say(`257 "` + cwd + `"`)
+		case "CWD":
+			if st, err := os.Stat(f.local(cwd, arg)); err == nil && st.IsDir() {
+				cwd = path.Clean(path.Join(cwd, arg))
+				if strings.HasPrefix(arg, "/") {
+					cwd = path.Clean(arg)
+				}
+				say("250 ok")
+			} else {
+				say("550 no dir")
+			}
+		case "MKD":
+			os.MkdirAll(f.local(cwd, arg), 0755)
+			say(`257 "` + arg + `" created`)
+		case "DELE":
+			if os.Remove(f.local(cwd, arg)) == nil {
+				say("250 deleted")
+			} else {
+				say("550 no file")
+			}
+		case "RNFR":
+			rnfr = arg
+			say("350 ready")
+		case "RNTO":
+			if os.Rename(f.local(cwd, rnfr), f.local(cwd, arg)) == nil {
+				say("250 renamed")
+			} else {
+				say("550 failed")
+			}
+		case "SIZE":
+			if st, err := os.Stat(f.local(cwd, arg)); err == nil {
+				say(fmt.Sprintf("213 %d", st.Size()))
+			} else {
+				say("550 no file")
+			}
+		case "MDTM":
+			if st, err := os.Stat(f.local(cwd, arg)); err == nil {
+				say("213 " + st.ModTime().UTC().Format("20060102150405"))
+			} else {
+				say("550 no file")
+			}
+		case "LIST", "MLSD", "NLST":
+			p := strings.TrimSpace(strings.TrimPrefix(arg, "-a"))
+			files, err := ioutil.ReadDir(f.local(cwd, p))
+			if err != nil {
+				if dc := data(); dc != nil {
+					dc.Close()
+				}
+				say("550 no dir")
+				continue
+			}
+			say("150 listing")
+			dc := data()
+			for _, fi := range files {
+				switch strings.ToUpper(cmd) {
+				case "MLSD":
+					t := "file"
+					if fi.IsDir() {
+						t = "dir"
+					}
+					fmt.Fprintf(dc, "type=%s;size=%d;modify=%s; %s\r\n", t, fi.Size(), fi.ModTime().UTC().Format("20060102150405"), fi.Name())
+				case "NLST":
+					fmt.Fprintf(dc, "%s\r\n", fi.Name())
+				default:
+					mode := "-rw-r--r--"
+					if fi.IsDir() {
+						mode = "drwxr-xr-x"
+					}
+					fmt.Fprintf(dc, "%s 1 owner group %d %s %s\r\n", mode, fi.Size(), fi.ModTime().UTC().Format("Jan _2 15:04"), fi.Name())
+				}
+			}
+			dc.Close()
+			say("226 done")
+		case "RETR":
+			fh, err := os.Open(f.local(cwd, arg))
+			if err != nil {
+				if dc := data(); dc != nil {
+					dc.Close()
+				}
+				say("550 no file")
+				continue
+			}
+			say("150 sending")
+			dc := data()
+			io.Copy(dc, fh)
+			fh.Close()
+			dc.Close()
+			say("226 done")
+		case "STOR":
+			f.mu.Lock()
+			f.stors++
+			drop := f.stors == f.dropStor
+			f.mu.Unlock()
+			if drop {
+				return
+			}
+			fn := f.local(cwd, arg)
+			os.MkdirAll(filepath.Dir(fn), 0755)
+			fh, err := os.Create(fn)
+			if err != nil {
+				say("550 cannot create")
+				continue
+			}
+			say("150 receiving")
+			dc := data()
+			io.Copy(fh, dc)
+			fh.Close()
+			dc.Close()
+			say("226 done")
+		case "QUIT":
+			say("221 bye")
+			return
+		default:
+			say("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter selects files by their path relative to the remote root. Patterns
+// use path.Match syntax plus ** for any number of folders. A pattern without
+// a / is matched against the file name alone, so *.txt matches in any folder
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Match returns true if rel is included and not excluded. With no Include
+// patterns everything is included
+func (f Filter) Match(rel string) bool {
+	if f.excluded(rel) {
+		return false
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, p := range f.Include {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Descend returns true if the folder rel should be searched
+func (f Filter) Descend(rel string) bool {
+	return !f.excluded(rel)
+}
+
+func (f Filter) excluded(rel string) bool {
+	for _, p := range f.Exclude {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error for the first malformed pattern
+func (f Filter) Validate() error {
+	for _, p := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		for _, seg := range strings.Split(p, "/") {
+			if _, err := path.Match(seg, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchPattern matches a single pattern against a relative path
+func matchPattern(pattern string, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments, with ** matching zero or more
+func matchSegments(ps []string, ns []string) bool {
+	for len(ps) > 0 {
+		if ps[0] == "**" {
+			for i := 0; i <= len(ns); i++ {
+				if matchSegments(ps[1:], ns[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(ns) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(ps[0], ns[0]); !ok {
+			return false
+		}
+		ps, ns = ps[1:], ns[1:]
+	}
+	return len(ns) == 0
+}
@@ -0,0 +1,139 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// Allowance when comparing modification times, as listings and local
+// filesystems don't all keep seconds
+const mtimeTolerance = 2 * time.Second
+
+// Puller downloads matching files from controllers, keeping each
+// controller's files in its own folder under Dest. Mask is kept for simple
+// configs and is used when Filter has no Include patterns
+type Puller struct {
+	Dest      string
+	Root      string
+	Mask      string
+	Filter    Filter
+	Recursive bool
+	Sync      bool
+	Options
+}
+
+// NewPuller returns a Puller with the default Options
+func NewPuller(dest string, mask string) *Puller {
+	return &Puller{Dest: dest, Mask: mask, Options: DefaultOptions()}
+}
+
+// Pull downloads from every Target, returning a Result for each
+func (p *Puller) Pull(ctx context.Context, targets []Target) []Result {
+	return Run(ctx, targets, p.Options, p.pull)
+}
+
+// filter returns the Filter with Mask applied
+func (p *Puller) filter() Filter {
+	f := p.Filter
+	if len(f.Include) == 0 && p.Mask != "" {
+		f.Include = []string{p.Mask}
+	}
+	return f
+}
+
+// pull is the JobFunc for a single Target
+func (p *Puller) pull(ctx context.Context, c *Conn, t Target, r *Result) error {
+	return p.pullInto(ctx, c, t, r, filepath.Join(p.Dest, t.Folder()))
+}
+
+// pullInto downloads the matching files for a Target into dest
+func (p *Puller) pullInto(ctx context.Context, c *Conn, t Target, r *Result, dest string) error {
+
+	f := p.filter()
+
+	return Walk(ctx, c, p.Root, p.Recursive, func(rel string, e *ftp.Entry) error {
+		if e.Type == ftp.EntryTypeFolder {
+			if !f.Descend(rel) {
+				return SkipDir
+			}
+			return nil
+		}
+		if e.Type != ftp.EntryTypeFile || !f.Match(rel) {
+			return nil
+		}
+
+		fn := filepath.Join(dest, filepath.FromSlash(rel))
+		if p.Sync && same(fn, e) {
+			r.Skipped = append(r.Skipped, fn)
+			return nil
+		}
+
+		n, err := download(c, path.Join(p.Root, rel), fn)
+		if err != nil {
+			return err
+		}
+		if !e.Time.IsZero() {
+			os.Chtimes(fn, e.Time, e.Time)
+		}
+		p.logf(t, "downloaded "+rel)
+		r.Files = append(r.Files, fn)
+		r.Bytes += n
+		return nil
+	})
+}
+
+// same returns true if the local file matches the size and time of the
+// remote one. Times are only compared if the listing included one
+func same(fn string, e *ftp.Entry) bool {
+	info, err := os.Stat(fn)
+	if err != nil || uint64(info.Size()) != e.Size {
+		return false
+	}
+	if e.Time.IsZero() {
+		return true
+	}
+	d := info.ModTime().Sub(e.Time)
+	return d < mtimeTolerance && d > -mtimeTolerance
+}
+
+// download copies a remote file to fn. The file is written alongside and
+// renamed once complete, so a failed transfer never leaves a partial file
+func download(c *Conn, remote string, fn string) (int64, error) {
+
+	resp, err := c.Retr(remote)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+		resp.Close()
+		return 0, err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fn), ".download")
+	if err != nil {
+		resp.Close()
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, resp)
+	if cerr := resp.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+
+	return n, os.Rename(tmp.Name(), fn)
+}
@@ -0,0 +1,164 @@
+package transfer
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempDir returns a new folder holding files by slash separated name
+func tempDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// closedPort returns a local address with nothing listening
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// testOptions returns Options which fail quickly
+func testOptions() Options {
+	return Options{
+		Concurrency: 2,
+		DialTimeout: time.Second,
+		Timeout:     10 * time.Second,
+		Retries:     1,
+		RetryDelay:  10 * time.Millisecond,
+	}
+}
+
+func TestPull(t *testing.T) {
+
+	remote := tempDir(t, map[string]string{
+		"main.tkn":      "compiled",
+		"notes.txt":     "hello",
+		"sub/other.tkn": "nested",
+	})
+	defer os.RemoveAll(remote)
+	dest := tempDir(t, nil)
+	defer os.RemoveAll(dest)
+
+	good := newFakeFTP(remote)
+	defer good.close()
+	locked := newFakeFTP(remote)
+	locked.pass = "changed"
+	defer locked.close()
+
+	targets := []Target{
+		good.target("Good"),
+		locked.target("Locked"),
+		{Name: "Offline", Host: "127.0.0.1", Port: closedPort(t)},
+	}
+
+	p := NewPuller(dest, "*.tkn")
+	p.Recursive = true
+	p.Options = testOptions()
+	rs := p.Pull(context.Background(), targets)
+
+	if len(rs) != 3 {
+		t.Fatalf("got %d results, want 3", len(rs))
+	}
+	for i, r := range rs {
+		if r.Target.Name != targets[i].Name {
+			t.Errorf("result %d is for %s, want %s", i, r.Target.Name, targets[i].Name)
+		}
+	}
+
+	// The working controller has its matching files in its own folder
+	if rs[0].Err != nil {
+		t.Fatalf("Good failed: %v", rs[0].Err)
+	}
+	var got []string
+	for _, fn := range rs[0].Files {
+		rel, _ := filepath.Rel(filepath.Join(dest, "Good"), fn)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "main.tkn,sub/other.tkn" {
+		t.Errorf("Good files = %q, want main.tkn and sub/other.tkn", got)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dest, "Good", "sub", "other.tkn")); string(b) != "nested" {
+		t.Errorf("sub/other.tkn = %q, want %q", b, "nested")
+	}
+	if rs[0].Bytes != int64(len("compiled")+len("nested")) || rs[0].Attempts != 1 {
+		t.Errorf("Good Bytes, Attempts = %d, %d", rs[0].Bytes, rs[0].Attempts)
+	}
+
+	// A refused login isn't retried, a connection failure is
+	if rs[1].Err == nil || !strings.Contains(rs[1].Err.Error(), "530") || rs[1].Attempts != 1 {
+		t.Errorf("Locked Err, Attempts = %v, %d, want a 530 error on the first attempt", rs[1].Err, rs[1].Attempts)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "Locked")); !os.IsNotExist(err) {
+		t.Error("Locked folder created for a failed login")
+	}
+	if rs[2].Err == nil || rs[2].Attempts != 2 {
+		t.Errorf("Offline Err, Attempts = %v, %d, want an error after 2 attempts", rs[2].Err, rs[2].Attempts)
+	}
+
+	if !Failed(rs) {
+		t.Error("Failed() = false with failed targets")
+	}
+	if Failed(rs[:1]) {
+		t.Error("Failed() = true for a successful target")
+	}
+}
+
+func TestPullSync(t *testing.T) {
+
+	remote := tempDir(t, map[string]string{"main.tkn": "compiled"})
+	defer os.RemoveAll(remote)
+	dest := tempDir(t, nil)
+	defer os.RemoveAll(dest)
+
+	s := newFakeFTP(remote)
+	defer s.close()
+
+	p := NewPuller(dest, "*.tkn")
+	p.Sync = true
+	p.Options = testOptions()
+	target := []Target{s.target("Main")}
+
+	if rs := p.Pull(context.Background(), target); rs[0].Err != nil || len(rs[0].Files) != 1 {
+		t.Fatalf("first pull = %+v, want one file", rs[0])
+	}
+	rs := p.Pull(context.Background(), target)
+	if rs[0].Err != nil || len(rs[0].Files) != 0 || len(rs[0].Skipped) != 1 {
+		t.Errorf("second pull = %+v, want the file skipped", rs[0])
+	}
+	retr := 0
+	for _, c := range s.commands() {
+		if strings.HasPrefix(c, "RETR") {
+			retr++
+		}
+	}
+	if retr != 1 {
+		t.Errorf("downloaded %d times, want 1", retr)
+	}
+}
@@ -0,0 +1,266 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// BackupTimeFormat names the timestamped folders backups are written to,
+// so they sort oldest first
+const BackupTimeFormat = "20060102-150405"
+
+// Pusher uploads matching local files to controllers. Files keep their path
+// relative to Source under Root on each controller
+type Pusher struct {
+	Source    string
+	Root      string
+	Filter    Filter
+	Recursive bool
+	DryRun    bool
+	Verify    bool
+	Backup    string
+	Options
+}
+
+// NewPusher returns a Pusher with the default Options
+func NewPusher(source string, root string) *Pusher {
+	return &Pusher{Source: source, Root: root, Options: DefaultOptions()}
+}
+
+// localFile is a file to upload
+type localFile struct {
+	rel  string
+	path string
+	size int64
+}
+
+// Push uploads to every Target, returning a Result for each. With DryRun
+// set each Target is logged in to but nothing is changed, and the Result
+// lists the files which would have been uploaded. With Backup set, any
+// remote file about to be replaced is first downloaded into a folder per
+// Target and Push under Backup
+func (p *Pusher) Push(ctx context.Context, targets []Target) []Result {
+
+	files, err := p.files()
+	if err == nil && len(files) == 0 {
+		err = errors.New("no files to upload from " + p.Source)
+	}
+	if err != nil {
+		rs := make([]Result, len(targets))
+		for i, t := range targets {
+			rs[i] = Result{Target: t, Err: err}
+		}
+		return rs
+	}
+
+	b := &backups{stamp: time.Now().Format(BackupTimeFormat), saved: map[string]bool{}}
+	return Run(ctx, targets, p.Options, func(ctx context.Context, c *Conn, t Target, r *Result) error {
+		return p.push(ctx, c, t, r, files, b)
+	})
+}
+
+// backups records the remote files checked for backup during a Push. A
+// retry may find files the failed attempt had already replaced, so each is
+// only checked once: saved holds true if it was backed up, or false if it
+// didn't exist
+type backups struct {
+	stamp string
+	mu    sync.Mutex
+	saved map[string]bool
+}
+
+// check returns whether fn has been checked, and if it was backed up
+func (b *backups) check(fn string) (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	saved, ok := b.saved[fn]
+	return ok, saved
+}
+
+// set records that fn has been checked
+func (b *backups) set(fn string, saved bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.saved[fn] = saved
+}
+
+// files lists the local files to upload, in path order. Source may be a
+// single file
+func (p *Pusher) files() ([]localFile, error) {
+
+	var files []localFile
+	err := filepath.Walk(p.Source, func(fn string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p.Source, fn)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if rel != "." && (!p.Recursive || !p.Filter.Descend(rel)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == "." {
+			rel = info.Name()
+		}
+		if !info.Mode().IsRegular() || !p.Filter.Match(rel) {
+			return nil
+		}
+		files = append(files, localFile{rel: rel, path: fn, size: info.Size()})
+		return nil
+	})
+
+	return files, err
+}
+
+// push is the JobFunc for a single Target
+func (p *Pusher) push(ctx context.Context, c *Conn, t Target, r *Result, files []localFile, b *backups) error {
+
+	made := map[string]bool{}
+
+	for _, f := range files {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		remote := path.Join(p.Root, f.rel)
+
+		if p.DryRun {
+			p.logf(t, "would upload "+remote)
+			r.Files = append(r.Files, remote)
+			r.Bytes += f.size
+			continue
+		}
+
+		if p.Backup != "" {
+			fn := filepath.Join(p.Backup, t.Folder(), b.stamp, filepath.FromSlash(f.rel))
+			if checked, saved := b.check(fn); checked {
+				if saved {
+					r.Backups = append(r.Backups, fn)
+				}
+			} else if _, err := download(c, remote, fn); err == nil {
+				p.logf(t, "backed up "+remote)
+				r.Backups = append(r.Backups, fn)
+				b.set(fn, true)
+			} else if notFound(err) {
+				b.set(fn, false)
+			} else {
+				return err
+			}
+		}
+
+		makeDirs(c, path.Dir(remote), made)
+
+		sum, n, err := upload(c, f.path, remote)
+		if err != nil {
+			return err
+		}
+		if p.Verify {
+			if err := verify(c, remote, sum); err != nil {
+				return err
+			}
+		}
+
+		p.logf(t, "uploaded "+remote)
+		r.Files = append(r.Files, remote)
+		r.Bytes += n
+	}
+
+	return nil
+}
+
+// notFound returns true if the server said the file doesn't exist
+func notFound(err error) bool {
+	e, ok := err.(*textproto.Error)
+	return ok && e.Code == ftp.StatusFileUnavailable
+}
+
+// makeDirs creates each folder in dir which hasn't already been made.
+// Errors are ignored as the folder usually exists already, and a real
+// problem will fail the upload
+func makeDirs(c *Conn, dir string, made map[string]bool) {
+	p := ""
+	if strings.HasPrefix(dir, "/") {
+		p = "/"
+	}
+	for _, seg := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if seg == "" || seg == "." {
+			continue
+		}
+		p = path.Join(p, seg)
+		if !made[p] {
+			c.MakeDir(p)
+			made[p] = true
+		}
+	}
+}
+
+// upload copies fn to the remote path, returning its checksum and size
+func upload(c *Conn, fn string, remote string) ([]byte, int64, error) {
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	cr := &countReader{r: io.TeeReader(f, h)}
+	if err := c.Stor(remote, cr); err != nil {
+		return nil, cr.n, err
+	}
+
+	return h.Sum(nil), cr.n, nil
+}
+
+// verify downloads the remote file and compares its checksum with sum
+func verify(c *Conn, remote string, sum []byte) error {
+
+	resp, err := c.Retr(remote)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, resp)
+	if cerr := resp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return errors.New("checksum mismatch after uploading " + remote)
+	}
+	return nil
+}
+
+// countReader counts the bytes read through it
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
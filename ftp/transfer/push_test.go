@@ -0,0 +1,84 @@
+package transfer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushBackupRetry(t *testing.T) {
+
+	remote := tempDir(t, map[string]string{"a.tkn": "old a", "b.tkn": "old b"})
+	defer os.RemoveAll(remote)
+	local := tempDir(t, map[string]string{"a.tkn": "new a", "b.tkn": "new b", "c.tkn": "new c"})
+	defer os.RemoveAll(local)
+	backup := tempDir(t, nil)
+	defer os.RemoveAll(backup)
+
+	// The first attempt replaces a.tkn, then fails uploading b.tkn
+	s := newFakeFTP(remote)
+	s.dropStor = 2
+	defer s.close()
+
+	p := NewPusher(local, "/")
+	p.Backup = backup
+	p.Options = testOptions()
+	rs := p.Push(context.Background(), []Target{s.target("Main")})
+
+	r := rs[0]
+	if r.Err != nil {
+		t.Fatalf("push failed: %v", r.Err)
+	}
+	if r.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", r.Attempts)
+	}
+	if len(r.Files) != 3 {
+		t.Errorf("Files = %q, want all 3", r.Files)
+	}
+
+	// The backups hold what was there before the push started
+	if len(r.Backups) != 2 {
+		t.Fatalf("Backups = %q, want a.tkn and b.tkn", r.Backups)
+	}
+	for _, fn := range r.Backups {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "old " + filepath.Base(fn)[:1]; string(b) != want {
+			t.Errorf("backup %s = %q, want %q", filepath.Base(fn), b, want)
+		}
+	}
+	for _, name := range []string{"a.tkn", "b.tkn", "c.tkn"} {
+		b, _ := ioutil.ReadFile(filepath.Join(remote, name))
+		if want := "new " + name[:1]; string(b) != want {
+			t.Errorf("remote %s = %q, want %q", name, b, want)
+		}
+	}
+}
+
+func TestPushDryRun(t *testing.T) {
+
+	remote := tempDir(t, map[string]string{"a.tkn": "old a"})
+	defer os.RemoveAll(remote)
+	local := tempDir(t, map[string]string{"a.tkn": "new a", "sub/b.tkn": "new b"})
+	defer os.RemoveAll(local)
+
+	s := newFakeFTP(remote)
+	defer s.close()
+
+	p := NewPusher(local, "/")
+	p.DryRun = true
+	p.Recursive = true
+	p.Options = testOptions()
+	r := p.Push(context.Background(), []Target{s.target("Main")})[0]
+
+	if r.Err != nil || len(r.Files) != 2 {
+		t.Fatalf("dry run = %+v, want 2 files listed", r)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(remote, "a.tkn")); string(b) != "old a" {
+		t.Errorf("dry run changed a.tkn to %q", b)
+	}
+}
@@ -0,0 +1,63 @@
+package transfer
+
+import (
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary writes a table of Results with one line per Target
+func Summary(w io.Writer, rs []Result) error {
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	io.WriteString(tw, "TARGET\tHOST\tSTATUS\tFILES\tSKIPPED\tBYTES\tATTEMPTS\tTIME\tERROR\n")
+
+	ok := 0
+	for _, r := range rs {
+		status, msg := "OK", ""
+		if r.Err != nil {
+			status, msg = "FAILED", r.Err.Error()
+		} else {
+			ok++
+		}
+		io.WriteString(tw, r.Target.Label()+"\t"+
+			r.Target.Addr()+"\t"+
+			status+"\t"+
+			strconv.Itoa(len(r.Files))+"\t"+
+			strconv.Itoa(len(r.Skipped))+"\t"+
+			strconv.FormatInt(r.Bytes, 10)+"\t"+
+			strconv.Itoa(r.Attempts)+"\t"+
+			r.Duration.Round(time.Millisecond).String()+"\t"+
+			msg+"\n")
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, strconv.Itoa(ok)+" of "+strconv.Itoa(len(rs))+" targets succeeded\n")
+	return err
+}
+
+// ChangeReport writes the files changed for each successful Result, one
+// per line, or that nothing changed
+func ChangeReport(w io.Writer, rs []Result) error {
+	for _, r := range rs {
+		if r.Err != nil {
+			continue
+		}
+		if len(r.Changes) == 0 {
+			if _, err := io.WriteString(w, r.Target.Label()+": no changes\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, c := range r.Changes {
+			if _, err := io.WriteString(w, r.Target.Label()+": "+c.Type.String()+" "+c.Path+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
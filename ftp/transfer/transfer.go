@@ -0,0 +1,287 @@
+package transfer
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// DefaultPort is the FTP port used when a Target doesn't set one
+const DefaultPort = 21
+
+// Target is a controller to transfer files to or from
+type Target struct {
+	Name     string
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Addr returns the host and port to dial
+func (t Target) Addr() string {
+	port := t.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(t.Host, strconv.Itoa(port))
+}
+
+// Label returns the Name of the Target, or the Host if it has none
+func (t Target) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Host
+}
+
+// Folder returns the Label made safe for use as a folder name
+func (t Target) Folder() string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, t.Label())
+}
+
+// Options controls how each Target is connected to
+type Options struct {
+	Concurrency int
+	DialTimeout time.Duration
+	Timeout     time.Duration
+	Retries     int
+	RetryDelay  time.Duration
+	Log         *log.Logger
+}
+
+// DefaultOptions returns the Options used by the command line tool
+func DefaultOptions() Options {
+	return Options{
+		Concurrency: 4,
+		DialTimeout: 10 * time.Second,
+		Timeout:     5 * time.Minute,
+		Retries:     2,
+		RetryDelay:  2 * time.Second,
+	}
+}
+
+// logf writes a line for a Target if logging is enabled
+func (o *Options) logf(t Target, msg string) {
+	if o.Log != nil {
+		o.Log.Println(t.Label() + ": " + msg)
+	}
+}
+
+// Result is the outcome of a transfer with a single Target
+type Result struct {
+	Target   Target
+	Files    []string
+	Skipped  []string
+	Backups  []string
+	Changes  []Change
+	Bytes    int64
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// Failed returns true if any Result has an error
+func Failed(rs []Result) bool {
+	for _, r := range rs {
+		if r.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is an FTP connection which is closed when its context is done, so a
+// stalled controller can't hold up a transfer forever
+type Conn struct {
+	*ftp.ServerConn
+	mu    sync.Mutex
+	conns []net.Conn
+	done  chan struct{}
+}
+
+// Dial connects and logs in to a Target. The connection is dropped when
+// ctx is done, and must be closed with Close
+func Dial(ctx context.Context, t Target, opts Options) (*Conn, error) {
+
+	c := &Conn{done: make(chan struct{})}
+
+	// Keep hold of every connection, including data ones, to close them
+	dialer := net.Dialer{Timeout: opts.DialTimeout}
+	dial := func(network, addr string) (net.Conn, error) {
+		nc, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.conns = append(c.conns, nc)
+		c.mu.Unlock()
+		return nc, nil
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.drop()
+		case <-c.done:
+		}
+	}()
+
+	sc, err := ftp.Dial(t.Addr(), ftp.DialWithDialFunc(dial))
+	if err != nil {
+		close(c.done)
+		c.drop()
+		return nil, contextError(ctx, err)
+	}
+	c.ServerConn = sc
+
+	if err := sc.Login(t.Username, t.Password); err != nil {
+		c.Close()
+		return nil, contextError(ctx, err)
+	}
+
+	return c, nil
+}
+
+// drop closes every network connection without saying goodbye
+func (c *Conn) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, nc := range c.conns {
+		nc.Close()
+	}
+}
+
+// Close logs out and closes the connection
+func (c *Conn) Close() error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	close(c.done)
+	err := c.Quit()
+	c.drop()
+	return err
+}
+
+// contextError returns the context's error in place of the network error
+// caused by dropping the connection
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// permanent returns true for errors which retrying won't fix
+func permanent(err error) bool {
+	if err == context.Canceled {
+		return true
+	}
+	if e, ok := err.(*textproto.Error); ok {
+		// Not logged in, or a file/permission problem
+		return e.Code == ftp.StatusNotLoggedIn || e.Code == ftp.StatusFileUnavailable
+	}
+	return false
+}
+
+// JobFunc does the work for a single Target over an open connection,
+// recording what it did in the Result
+type JobFunc func(ctx context.Context, c *Conn, t Target, r *Result) error
+
+// Run calls fn for every Target with a connection, running up to
+// Concurrency at once. Each attempt is limited to Timeout and failed
+// attempts are retried with a new connection. Results are in Target order
+func Run(ctx context.Context, targets []Target, opts Options, fn JobFunc) []Result {
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runOne(ctx, t, opts, fn)
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runOne connects to a single Target and runs fn, retrying on failure
+func runOne(ctx context.Context, t Target, opts Options, fn JobFunc) Result {
+
+	start := time.Now()
+	var r Result
+
+	for attempt := 1; attempt <= opts.Retries+1; attempt++ {
+
+		if attempt > 1 {
+			opts.logf(t, "retrying after "+r.Err.Error())
+			select {
+			case <-ctx.Done():
+				r.Err = ctx.Err()
+				r.Duration = time.Since(start)
+				return r
+			case <-time.After(opts.RetryDelay):
+			}
+		}
+
+		r = Result{Target: t, Attempts: attempt}
+		r.Err = attemptOne(ctx, t, opts, fn, &r)
+		if r.Err == nil || permanent(r.Err) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	r.Duration = time.Since(start)
+	if r.Err != nil {
+		opts.logf(t, "failed: "+r.Err.Error())
+	} else {
+		opts.logf(t, "done")
+	}
+	return r
+}
+
+// attemptOne makes a single connection and runs fn
+func attemptOne(ctx context.Context, t Target, opts Options, fn JobFunc, r *Result) error {
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	opts.logf(t, "connecting to "+t.Addr())
+	c, err := Dial(ctx, t, opts)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := fn(ctx, c, t, r); err != nil {
+		return contextError(ctx, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// SkipDir is returned by a WalkFunc to skip the contents of a folder
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is called for each entry found by Walk, with its path relative
+// to the root using / separators
+type WalkFunc func(rel string, e *ftp.Entry) error
+
+// Walk lists root on the controller, calling fn for each entry in name
+// order and, if recursive, doing the same for each folder
+func Walk(ctx context.Context, c *Conn, root string, recursive bool, fn WalkFunc) error {
+	return walk(ctx, c, root, "", recursive, fn)
+}
+
+func walk(ctx context.Context, c *Conn, root string, rel string, recursive bool, fn WalkFunc) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := c.List(path.Join(root, rel))
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	for _, e := range entries {
+		if e.Name == "." || e.Name == ".." || e.Name == "" {
+			continue
+		}
+		// Some servers list the full path
+		name := path.Join(rel, path.Base(e.Name))
+
+		err := fn(name, e)
+		if err == SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if recursive && e.Type == ftp.EntryTypeFolder {
+			if err := walk(ctx, c, root, name, recursive, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
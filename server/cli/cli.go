@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/soloworks/go-netlinx/server"
+)
+
+type myargs struct {
+	Addr      string
+	MaxBody   int64
+	MaxUpload int64
+	Quiet     bool
+}
+
+var args myargs
+
+func main() {
+	// Get Command Line Variables
+	flag.StringVar(&args.Addr, "Addr", ":8080", "Address to listen on")
+	flag.Int64Var(&args.MaxBody, "MaxBody", server.DefaultMaxBody, "Largest request body accepted in bytes")
+	flag.Int64Var(&args.MaxUpload, "MaxUpload", server.DefaultMaxUpload, "Largest workspace upload accepted in bytes")
+	flag.BoolVar(&args.Quiet, "Quiet", false, "Don't log requests")
+	flag.Parse()
+
+	opts := server.DefaultOptions()
+	opts.MaxBody = args.MaxBody
+	opts.MaxUpload = args.MaxUpload
+	if args.Quiet {
+		opts.Log = ioutil.Discard
+	}
+
+	srv := &http.Server{
+		Addr:              args.Addr,
+		Handler:           server.New(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       2 * time.Minute,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	// Finish outstanding requests on Ctrl+C or termination
+	done := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		close(done)
+	}()
+
+	println("Listening on " + args.Addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		println("Error Starting Server")
+		println(err.Error())
+		os.Exit(1)
+	}
+	<-done
+}
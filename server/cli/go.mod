@@ -0,0 +1,13 @@
+module github.com/soloworks/go-netlinx/server/cli
+
+go 1.12
+
+require github.com/soloworks/go-netlinx/server v0.0.0-20190714191235-a674af7ca695
+
+replace github.com/soloworks/go-netlinx/apw => ../../apw
+
+replace github.com/soloworks/go-netlinx/compilecfg => ../../compilecfg
+
+replace github.com/soloworks/go-netlinx/compilelog => ../../compilelog
+
+replace github.com/soloworks/go-netlinx/server => ../../server
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+	"github.com/soloworks/go-netlinx/compilecfg"
+	"github.com/soloworks/go-netlinx/compilelog"
+)
+
+// problem is the JSON form of an apw.Problem
+type problem struct {
+	Level   string `json:"level"`
+	Project string `json:"project,omitempty"`
+	System  string `json:"system,omitempty"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// validation is the response from /validate
+type validation struct {
+	Valid    bool      `json:"valid"`
+	Projects int       `json:"projects"`
+	Systems  int       `json:"systems"`
+	Files    int       `json:"files"`
+	Problems []problem `json:"problems"`
+}
+
+// change is the JSON form of an apw.Change
+type change struct {
+	Type    string `json:"type"`
+	Project string `json:"project,omitempty"`
+	System  string `json:"system,omitempty"`
+	File    string `json:"file,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+// Content types for each compile log format
+var formatTypes = map[string]string{
+	compilelog.FormatText:       "text/plain; charset=utf-8",
+	compilelog.FormatSARIF:      "application/sarif+json",
+	compilelog.FormatJUnit:      "application/xml",
+	compilelog.FormatCheckstyle: "application/xml",
+}
+
+// loadWorkspace parses an .apw file, returning a 400 if it isn't valid
+func loadWorkspace(name string, b []byte) (*apw.APW, error) {
+	a, err := apw.NewAPW(name, b)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid workspace: "+err.Error())
+	}
+	return a, nil
+}
+
+// readWorkspace loads the workspace passed as the request body
+func readWorkspace(r *http.Request) (*apw.APW, error) {
+	b, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	return loadWorkspace("workspace.apw", b)
+}
+
+// queryFlag sets f from a boolean URL variable if present
+func queryFlag(r *http.Request, name string, f *compilecfg.Flag) error {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid value for "+name+": "+v)
+	}
+	*f = compilecfg.NewFlag(b)
+	return nil
+}
+
+// cfgOptions reads compilecfg.Options from the URL variables
+func cfgOptions(r *http.Request) (compilecfg.Options, error) {
+
+	q := r.URL.Query()
+
+	opts := compilecfg.DefaultOptions()
+	opts.Root = q.Get("root")
+	opts.LogFile = q.Get("logfile")
+	opts.LogConsole = compilecfg.FlagNo
+	opts.Project = q.Get("project")
+	opts.System = q.Get("system")
+
+	flags := map[string]*compilecfg.Flag{
+		"logconsole": &opts.LogConsole,
+		"debug":      &opts.DebugInfo,
+		"source":     &opts.Source,
+		"wc":         &opts.WC,
+	}
+	for name, f := range flags {
+		if err := queryFlag(r, name, f); err != nil {
+			return opts, err
+		}
+	}
+
+	if v := q.Get("sourcesfirst"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errorf(http.StatusBadRequest, "invalid value for sourcesfirst: "+v)
+		}
+		opts.ModulesFirst = !b
+	}
+
+	switch q.Get("order") {
+	case "", "sorted":
+	case "workspace":
+		opts.Order = compilecfg.OrderWorkspace
+	default:
+		return opts, errorf(http.StatusBadRequest, "order must be sorted or workspace")
+	}
+
+	switch q.Get("scope") {
+	case "", "workspace":
+	case "project":
+		opts.Scope = compilecfg.ScopeProject
+	case "system":
+		opts.Scope = compilecfg.ScopeSystem
+	default:
+		return opts, errorf(http.StatusBadRequest, "scope must be workspace, project or system")
+	}
+
+	return opts, nil
+}
+
+// health reports the server is running
+func (s *Server) health(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// openAPI returns the description of the API
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write([]byte(openAPISpec))
+	return err
+}
+
+// validate checks the structure of a workspace
+func (s *Server) validate(w http.ResponseWriter, r *http.Request) error {
+
+	a, err := readWorkspace(r)
+	if err != nil {
+		return err
+	}
+
+	// Referenced files can't be checked as they aren't on this machine
+	ps := a.Validate(false)
+
+	v := validation{Valid: !apw.HasErrors(ps), Problems: []problem{}}
+	for _, p := range a.Workspace.Projects {
+		v.Projects++
+		for _, sys := range p.Systems {
+			v.Systems++
+			v.Files += len(sys.Files)
+		}
+	}
+	for _, p := range ps {
+		v.Problems = append(v.Problems, problem{
+			Level:   p.Level.String(),
+			Project: p.Project,
+			System:  p.System,
+			File:    p.File,
+			Message: p.Message,
+		})
+	}
+
+	return writeJSON(w, http.StatusOK, v)
+}
+
+// compileCfg generates a .cfg file for the Netlinx compiler
+func (s *Server) compileCfg(w http.ResponseWriter, r *http.Request) error {
+
+	opts, err := cfgOptions(r)
+	if err != nil {
+		return err
+	}
+
+	a, err := readWorkspace(r)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = w.Write(compilecfg.Generate(*a, opts))
+	return err
+}
+
+// compileLog processes a compiler log into the requested format
+func (s *Server) compileLog(w http.ResponseWriter, r *http.Request) error {
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = compilelog.FormatText
+	}
+	ct, ok := formatTypes[format]
+	if !ok {
+		return errorf(http.StatusBadRequest, "format must be text, sarif, junit or checkstyle")
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := compilelog.Render(body, r.URL.Query().Get("root"), format)
+	if err != nil {
+		return errorf(http.StatusUnprocessableEntity, "processing log: "+err.Error())
+	}
+
+	w.Header().Set("Content-Type", ct)
+	_, err = w.Write(b)
+	return err
+}
+
+// formWorkspace loads a workspace uploaded as a multipart form file
+func formWorkspace(r *http.Request, field string) (*apw.APW, error) {
+
+	f, h, err := r.FormFile(field)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "missing workspace "+field)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return loadWorkspace(h.Filename, b)
+}
+
+// diff compares the old and new workspaces uploaded as a form
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) error {
+
+	if err := r.ParseMultipartForm(s.opts.MaxBody); err != nil {
+		return bodyError(err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	a, err := formWorkspace(r, "old")
+	if err != nil {
+		return err
+	}
+	b, err := formWorkspace(r, "new")
+	if err != nil {
+		return err
+	}
+
+	cs := []change{}
+	for _, c := range apw.Diff(a.Workspace, b.Workspace) {
+		cs = append(cs, change{
+			Type:    c.Type.String(),
+			Project: c.Project,
+			System:  c.System,
+			File:    c.File,
+			Field:   c.Field,
+			Old:     c.Old,
+			New:     c.New,
+		})
+	}
+
+	return writeJSON(w, http.StatusOK, map[string]interface{}{"changes": cs})
+}
+
+// pack converts a workspace to a release or handover .apw
+func (s *Server) pack(w http.ResponseWriter, r *http.Request) error {
+
+	kind := strings.ToLower(r.URL.Query().Get("type"))
+	if kind != "release" && kind != "handover" {
+		return errorf(http.StatusBadRequest, "type must be release or handover")
+	}
+
+	a, err := readWorkspace(r)
+	if err != nil {
+		return err
+	}
+	if ps := a.Validate(false); apw.HasErrors(ps) {
+		return errorf(http.StatusUnprocessableEntity, "workspace is not valid, see /validate")
+	}
+
+	switch kind {
+	case "release":
+		a.Workspace.ConvertToRelease()
+	case "handover":
+		a.Workspace.ConvertToHandover()
+	}
+
+	b, err := a.Workspace.ToXML()
+	if err != nil {
+		return err
+	}
+
+	name := a.Workspace.Identifier
+	if name == "" {
+		name = "workspace"
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "_" + kind + ".apw"}))
+	_, err = w.Write(b)
+	return err
+}
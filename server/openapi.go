@@ -0,0 +1,189 @@
+package server
+
+// openAPISpec describes the endpoints, served from /openapi.json
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "go-netlinx server",
+    "description": "Workspace validation, compile config generation, compile log processing, workspace diff and packaging for AMX Netlinx",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/health": {
+      "get": {
+        "summary": "Check the server is running",
+        "responses": {
+          "200": {"description": "Running", "content": {"application/json": {"schema": {"type": "object", "properties": {"status": {"type": "string"}}}}}}
+        }
+      }
+    },
+    "/validate": {
+      "post": {
+        "summary": "Check the structure of a workspace",
+        "requestBody": {"$ref": "#/components/requestBodies/Workspace"},
+        "responses": {
+          "200": {"description": "Validation result", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Validation"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/compilecfg": {
+      "post": {
+        "summary": "Generate a .cfg file for the Netlinx compiler",
+        "requestBody": {"$ref": "#/components/requestBodies/Workspace"},
+        "parameters": [
+          {"name": "root", "in": "query", "schema": {"type": "string"}, "description": "Root directory of the workspace on the compiling machine"},
+          {"name": "logfile", "in": "query", "schema": {"type": "string"}, "description": "Compiler log file"},
+          {"name": "logconsole", "in": "query", "schema": {"type": "boolean", "default": false}},
+          {"name": "debug", "in": "query", "schema": {"type": "boolean", "default": true}},
+          {"name": "source", "in": "query", "schema": {"type": "boolean", "default": false}},
+          {"name": "wc", "in": "query", "schema": {"type": "boolean", "default": true}},
+          {"name": "sourcesfirst", "in": "query", "schema": {"type": "boolean", "default": false}},
+          {"name": "order", "in": "query", "schema": {"type": "string", "enum": ["sorted", "workspace"], "default": "sorted"}},
+          {"name": "scope", "in": "query", "schema": {"type": "string", "enum": ["workspace", "project", "system"], "default": "workspace"}},
+          {"name": "project", "in": "query", "schema": {"type": "string"}, "description": "Only include this project"},
+          {"name": "system", "in": "query", "schema": {"type": "string"}, "description": "Only include this system"}
+        ],
+        "responses": {
+          "200": {"description": "Compiler config", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/compilelog": {
+      "post": {
+        "summary": "Process a Netlinx compiler log",
+        "requestBody": {"required": true, "content": {"text/plain": {"schema": {"type": "string"}}}},
+        "parameters": [
+          {"name": "root", "in": "query", "schema": {"type": "string"}, "description": "Make paths relative to this directory"},
+          {"name": "format", "in": "query", "schema": {"type": "string", "enum": ["text", "sarif", "junit", "checkstyle"], "default": "text"}}
+        ],
+        "responses": {
+          "200": {"description": "Processed log", "content": {"text/plain": {}, "application/sarif+json": {}, "application/xml": {}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/diff": {
+      "post": {
+        "summary": "Compare two workspaces",
+        "requestBody": {
+          "required": true,
+          "content": {"multipart/form-data": {"schema": {"type": "object", "required": ["old", "new"], "properties": {"old": {"type": "string", "format": "binary"}, "new": {"type": "string", "format": "binary"}}}}}
+        },
+        "responses": {
+          "200": {"description": "Changes from old to new", "content": {"application/json": {"schema": {"type": "object", "properties": {"changes": {"type": "array", "items": {"$ref": "#/components/schemas/Change"}}}}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/package": {
+      "post": {
+        "summary": "Convert a workspace to a release or handover workspace",
+        "requestBody": {"$ref": "#/components/requestBodies/Workspace"},
+        "parameters": [
+          {"name": "type", "in": "query", "required": true, "schema": {"type": "string", "enum": ["release", "handover"]}}
+        ],
+        "responses": {
+          "200": {"description": "Converted workspace", "content": {"application/xml": {"schema": {"type": "string"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packup": {
+      "post": {
+        "summary": "Build a release, handover or archive zip from an uploaded workspace folder",
+        "description": "The body is a zip of the workspace folder, or a form with the folder's files (keeping their relative paths) or a zip. Options may be sent as form fields instead of query parameters.",
+        "parameters": [
+          {"name": "type", "in": "query", "schema": {"type": "string", "enum": ["archive", "release", "handover"], "default": "archive"}},
+          {"name": "workspace", "in": "query", "schema": {"type": "string"}, "description": "Workspace to package if more than one .apw was uploaded"},
+          {"name": "build", "in": "query", "schema": {"type": "string"}, "description": "Build ID added to the file name"}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/zip": {"schema": {"type": "string", "format": "binary"}},
+            "multipart/form-data": {"schema": {"type": "object", "properties": {"files": {"type": "array", "items": {"type": "string", "format": "binary"}}, "zip": {"type": "string", "format": "binary"}, "type": {"type": "string"}, "workspace": {"type": "string"}, "build": {"type": "string"}}}}
+          }
+        },
+        "responses": {
+          "200": {"description": "Package", "content": {"application/zip": {"schema": {"type": "string", "format": "binary"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "413": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"},
+          "422": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packup.html": {
+      "get": {
+        "summary": "Form for uploading a workspace from a browser",
+        "responses": {
+          "200": {"description": "HTML form", "content": {"text/html": {}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "requestBodies": {
+      "Workspace": {
+        "required": true,
+        "description": "Netlinx Studio .apw workspace",
+        "content": {"application/xml": {"schema": {"type": "string"}}, "text/xml": {"schema": {"type": "string"}}}
+      }
+    },
+    "responses": {
+      "Error": {
+        "description": "Request failed",
+        "content": {"application/json": {"schema": {"type": "object", "properties": {"error": {"type": "string"}, "status": {"type": "integer"}, "details": {}}}}}
+      }
+    },
+    "schemas": {
+      "Validation": {
+        "type": "object",
+        "properties": {
+          "valid": {"type": "boolean"},
+          "projects": {"type": "integer"},
+          "systems": {"type": "integer"},
+          "files": {"type": "integer"},
+          "problems": {"type": "array", "items": {"$ref": "#/components/schemas/Problem"}}
+        }
+      },
+      "Problem": {
+        "type": "object",
+        "properties": {
+          "level": {"type": "string", "enum": ["warning", "error"]},
+          "project": {"type": "string"},
+          "system": {"type": "string"},
+          "file": {"type": "string"},
+          "message": {"type": "string"}
+        }
+      },
+      "Change": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "enum": ["added", "removed", "modified"]},
+          "project": {"type": "string"},
+          "system": {"type": "string"},
+          "file": {"type": "string"},
+          "field": {"type": "string"},
+          "old": {"type": "string"},
+          "new": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/soloworks/go-netlinx/apw"
+)
+
+// Most files accepted in one upload
+const maxUploadFiles = 10000
+
+// unpacker writes uploaded files into a folder, refusing paths outside of
+// it and stopping once the limit is reached
+type unpacker struct {
+	dir     string
+	limit   int64
+	written int64
+	files   int
+}
+
+// write saves r as name, which may include folders
+func (u *unpacker) write(name string, r io.Reader) error {
+
+	// Clean the name so it can't escape the folder
+	name = path.Clean("/" + strings.Replace(name, `\`, `/`, -1))[1:]
+	if name == "" || strings.HasSuffix(name, "/") {
+		return nil
+	}
+
+	u.files++
+	if u.files > maxUploadFiles {
+		return errorf(http.StatusRequestEntityTooLarge, "more than "+strconv.Itoa(maxUploadFiles)+" files uploaded")
+	}
+
+	fn := filepath.Join(u.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, u.limit-u.written+1))
+	u.written += n
+	if err != nil {
+		return bodyError(err)
+	}
+	if u.written > u.limit {
+		return errorf(http.StatusRequestEntityTooLarge, "upload too large once unpacked")
+	}
+	return nil
+}
+
+// unzip extracts every file in the zip file fn
+func (u *unpacker) unzip(fn string) error {
+
+	z, err := zip.OpenReader(fn)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid zip file: "+err.Error())
+	}
+	defer z.Close()
+
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errorf(http.StatusBadRequest, "invalid zip file: "+err.Error())
+		}
+		err = u.write(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unzipFrom saves r to a temporary file and extracts it
+func (u *unpacker) unzipFrom(r io.Reader) error {
+
+	tmp, err := ioutil.TempFile("", "upload*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return bodyError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return u.unzip(tmp.Name())
+}
+
+// receive unpacks an upload into dir. The body may be a zip, or a form of
+// zips and files from a folder upload (keeping their relative paths). Any
+// other form fields are returned
+func (s *Server) receive(r *http.Request, dir string) (url.Values, error) {
+
+	u := &unpacker{dir: dir, limit: s.opts.MaxUpload}
+	fields := url.Values{}
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "multipart/form-data" {
+		return fields, u.unzipFrom(r.Body)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, err.Error())
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, bodyError(err)
+		}
+
+		// FileName() drops folders, so read the full name from the header
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		name := params["filename"]
+
+		switch {
+		case name == "" && part.FormName() != "":
+			b, err := ioutil.ReadAll(io.LimitReader(part, 1024))
+			if err != nil {
+				return nil, bodyError(err)
+			}
+			fields.Add(part.FormName(), string(b))
+		case strings.EqualFold(path.Ext(name), ".zip"):
+			err = u.unzipFrom(part)
+		case name != "":
+			err = u.write(name, part)
+		}
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if u.files == 0 {
+		return nil, errorf(http.StatusBadRequest, "no files uploaded")
+	}
+	return fields, nil
+}
+
+// pickWorkspace returns the uploaded workspace named id, or the only one
+func pickWorkspace(apws []*apw.APW, id string) (*apw.APW, error) {
+
+	var names []string
+	for _, a := range apws {
+		if id != "" && strings.EqualFold(a.Identifier, id) {
+			return a, nil
+		}
+		names = append(names, a.Identifier)
+	}
+
+	switch {
+	case len(apws) == 0:
+		return nil, errorf(http.StatusUnprocessableEntity, "no .apw workspace found in upload")
+	case id != "":
+		return nil, &Error{Status: http.StatusUnprocessableEntity, Message: "workspace " + id + " not found", Details: names}
+	case len(apws) > 1:
+		return nil, &Error{Status: http.StatusUnprocessableEntity, Message: "more than one workspace uploaded, choose one with workspace", Details: names}
+	}
+	return apws[0], nil
+}
+
+// packup builds a release, handover or archive package from an uploaded
+// workspace folder and streams it back as a zip
+func (s *Server) packup(w http.ResponseWriter, r *http.Request) error {
+
+	dir, err := ioutil.TempDir("", "packup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fields, err := s.receive(r, dir)
+	if err != nil {
+		return err
+	}
+
+	// Options can be in the URL or, from a browser, the form
+	q := r.URL.Query()
+	for k, v := range fields {
+		if q.Get(k) == "" {
+			q[k] = v
+		}
+	}
+
+	kind := strings.ToLower(q.Get("type"))
+	if kind == "" {
+		kind = "archive"
+	}
+	if kind != "archive" && kind != "release" && kind != "handover" {
+		return errorf(http.StatusBadRequest, "type must be archive, release or handover")
+	}
+
+	a, err := pickWorkspace(apw.FindAPWs(dir, true), q.Get("workspace"))
+	if err != nil {
+		return err
+	}
+	if err := checkInside(dir, a); err != nil {
+		return err
+	}
+
+	// Archives need every file, other packages only the compiled ones
+	if ps := a.Validate(kind == "archive"); apw.HasErrors(ps) {
+		var details []problem
+		for _, p := range ps {
+			details = append(details, problem{Level: p.Level.String(), Project: p.Project, System: p.System, File: relPath(dir, p.File), Message: p.Message})
+		}
+		return &Error{Status: http.StatusUnprocessableEntity, Message: "workspace is not valid", Details: details}
+	}
+
+	switch kind {
+	case "release":
+		a.Workspace.ConvertToRelease()
+	case "handover":
+		a.Workspace.ConvertToHandover()
+	}
+	if err := a.RefreshFiles(); err != nil {
+		return err
+	}
+	if err := checkInside(dir, a); err != nil {
+		return err
+	}
+	if len(a.FilesMissing) > 0 {
+		var missing []string
+		for _, fn := range a.FilesMissing {
+			missing = append(missing, relPath(dir, fn))
+		}
+		return &Error{Status: http.StatusUnprocessableEntity, Message: strconv.Itoa(len(missing)) + " files not found", Details: missing}
+	}
+
+	// Point the workspace at the folders used in the zip
+	if _, err := a.ArchiveFiles(); err != nil {
+		return errorf(http.StatusUnprocessableEntity, err.Error())
+	}
+	a.Workspace.SetRelativeFilepaths()
+
+	name := a.Identifier
+	if b := q.Get("build"); b != "" {
+		name += "_" + b
+	}
+	name += "_" + kind + ".zip"
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	return a.WriteArchive(w)
+}
+
+// checkInside returns an error if the workspace references any file outside
+// the upload folder, so an upload can't package other files on the server
+func checkInside(dir string, a *apw.APW) error {
+
+	var refs []string
+	for _, p := range a.Workspace.Projects {
+		for _, sys := range p.Systems {
+			for _, f := range sys.Files {
+				// Resolve the path as apw does for FilesReferenced
+				fn := localPath(f.FilePathName)
+				if !filepath.IsAbs(fn) {
+					fn = filepath.Join(a.OriginPath, fn)
+				}
+				rel, err := filepath.Rel(dir, fn)
+				if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					refs = append(refs, f.FilePathName)
+				}
+			}
+		}
+	}
+
+	if len(refs) > 0 {
+		return &Error{Status: http.StatusBadRequest, Message: strconv.Itoa(len(refs)) + " files referenced outside the upload", Details: refs}
+	}
+	return nil
+}
+
+// localPath converts the Windows separators used in workspaces to those of
+// this machine
+func localPath(p string) string {
+	return filepath.FromSlash(strings.Replace(p, `\`, `/`, -1))
+}
+
+// relPath returns fn relative to the upload folder
+func relPath(dir string, fn string) string {
+	if rel, err := filepath.Rel(dir, fn); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return fn
+}
+
+// packupForm is a page for uploading from a browser
+func (s *Server) packupForm(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write([]byte(packupHTML))
+	return err
+}
+
+const packupHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Netlinx Package</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+label { display: block; margin: 1em 0 0.25em; }
+</style>
+</head>
+<body>
+<h1>Netlinx Package</h1>
+<form method="post" action="packup" enctype="multipart/form-data">
+<label>Workspace folder</label>
+<input type="file" name="files" webkitdirectory multiple>
+<label>or a zip of it</label>
+<input type="file" name="zip" accept=".zip">
+<label>Package</label>
+<select name="type">
+<option value="release">Release</option>
+<option value="handover">Handover</option>
+<option value="archive">Archive</option>
+</select>
+<label>Workspace (if the folder has more than one)</label>
+<input type="text" name="workspace">
+<label>Build ID (optional)</label>
+<input type="text" name="build">
+<p><button type="submit">Download Package</button></p>
+</form>
+</body>
+</html>
+`
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default request size limits
+const (
+	DefaultMaxBody   = 10 << 20
+	DefaultMaxUpload = 200 << 20
+)
+
+// Content types accepted for each kind of request body
+var (
+	workspaceTypes = []string{"application/xml", "text/xml", "text/plain", "application/octet-stream"}
+	logTypes       = []string{"text/plain", "application/octet-stream"}
+	formTypes      = []string{"multipart/form-data"}
+	uploadTypes    = []string{"application/zip", "application/x-zip-compressed", "application/octet-stream", "multipart/form-data"}
+)
+
+// Options configures a Server. MaxUpload applies to uploaded workspace
+// folders, both compressed and once unpacked
+type Options struct {
+	MaxBody   int64
+	MaxUpload int64
+	Log       io.Writer
+}
+
+// DefaultOptions returns the Options used by the command line server
+func DefaultOptions() Options {
+	return Options{
+		MaxBody:   DefaultMaxBody,
+		MaxUpload: DefaultMaxUpload,
+		Log:       os.Stderr,
+	}
+}
+
+// Server is an http.Handler exposing the workspace, compile config and
+// compile log tools
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+	mu   sync.Mutex
+}
+
+// Error is returned by handlers to set the status code of the response,
+// with any Details added to the JSON body
+type Error struct {
+	Status  int
+	Message string
+	Details interface{}
+}
+
+// Error implements error
+func (e *Error) Error() string { return e.Message }
+
+// errorf returns an Error for the status
+func errorf(status int, msg string) *Error {
+	return &Error{Status: status, Message: msg}
+}
+
+// handlerFunc is an endpoint which returns an error rather than writing one
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// New returns a Server with every endpoint registered
+func New(opts Options) *Server {
+
+	if opts.MaxBody <= 0 {
+		opts.MaxBody = DefaultMaxBody
+	}
+	if opts.MaxUpload <= 0 {
+		opts.MaxUpload = DefaultMaxUpload
+	}
+
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+
+	s.handle("/health", http.MethodGet, nil, opts.MaxBody, s.health)
+	s.handle("/openapi.json", http.MethodGet, nil, opts.MaxBody, s.openAPI)
+	s.handle("/validate", http.MethodPost, workspaceTypes, opts.MaxBody, s.validate)
+	s.handle("/compilecfg", http.MethodPost, workspaceTypes, opts.MaxBody, s.compileCfg)
+	s.handle("/compilelog", http.MethodPost, logTypes, opts.MaxBody, s.compileLog)
+	s.handle("/diff", http.MethodPost, formTypes, opts.MaxBody, s.diff)
+	s.handle("/package", http.MethodPost, workspaceTypes, opts.MaxBody, s.pack)
+	s.handle("/packup", http.MethodPost, uploadTypes, opts.MaxUpload, s.packup)
+	s.handle("/packup.html", http.MethodGet, nil, opts.MaxBody, s.packupForm)
+
+	// Anything else is a JSON 404 rather than the default text one
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, func(w http.ResponseWriter, r *http.Request) error {
+			return errorf(http.StatusNotFound, "no endpoint "+r.URL.Path)
+		})
+	})
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle registers an endpoint which only accepts one method, bodies up to
+// limit and, if types is set, one of the listed content types
+func (s *Server) handle(path string, method string, types []string, limit int64, fn handlerFunc) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != method {
+				w.Header().Set("Allow", method)
+				return errorf(http.StatusMethodNotAllowed, "method "+r.Method+" not allowed")
+			}
+			if types != nil {
+				if err := checkContentType(r, types); err != nil {
+					return err
+				}
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			return fn(w, r)
+		})
+	})
+}
+
+// checkContentType returns an error if the request body isn't one of types.
+// A missing content type is allowed for simple clients
+func checkContentType(r *http.Request, types []string) error {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return errorf(http.StatusUnsupportedMediaType, "invalid content type "+ct)
+	}
+	for _, t := range types {
+		if mt == t {
+			return nil
+		}
+	}
+	return errorf(http.StatusUnsupportedMediaType, "content type "+mt+" not supported, expected "+strings.Join(types, ", "))
+}
+
+// readBody reads the whole request body, reporting oversized requests
+func readBody(r *http.Request) ([]byte, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	if len(b) == 0 {
+		return nil, errorf(http.StatusBadRequest, "request body is empty")
+	}
+	return b, nil
+}
+
+// bodyError converts an error reading the request body to an Error
+func bodyError(err error) error {
+	if strings.Contains(err.Error(), "request body too large") {
+		return errorf(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+	return errorf(http.StatusBadRequest, err.Error())
+}
+
+// statusWriter records what was written for logging
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// serve runs a handler, writing any error as JSON and logging the request
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, fn handlerFunc) {
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				s.log(map[string]interface{}{"level": "error", "panic": fmt.Sprint(p), "stack": string(debug.Stack())})
+				err = errorf(http.StatusInternalServerError, "internal error")
+			}
+		}()
+		return fn(sw, r)
+	}()
+
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			e = errorf(http.StatusInternalServerError, err.Error())
+		}
+		if sw.status == 0 {
+			body := map[string]interface{}{"error": e.Message, "status": e.Status}
+			if e.Details != nil {
+				body["details"] = e.Details
+			}
+			writeJSON(sw, e.Status, body)
+		}
+	}
+
+	entry := map[string]interface{}{
+		"level":       "info",
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"status":      sw.status,
+		"bytes":       sw.bytes,
+		"duration_ms": time.Since(start).Seconds() * 1000,
+		"remote":      r.RemoteAddr,
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+		if sw.status >= 500 {
+			entry["level"] = "error"
+		}
+	}
+	s.log(entry)
+}
+
+// log writes a single JSON line to the log
+func (s *Server) log(entry map[string]interface{}) {
+	if s.opts.Log == nil {
+		return
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Log.Write(append(b, '\n'))
+}
+
+// writeJSON writes v as the response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testAPW = `<?xml version="1.0" encoding="utf-8"?>
+<Workspace CurrentVersion="4.0"><Identifier>ws</Identifier><CreateVersion>4.0</CreateVersion>
+<Project><Identifier>P1</Identifier>
+<System IsActive="true" Platform="Netlinx" Transport="TCPIP" TransportEx="TCPIP"><Identifier>001: Main</Identifier><SysID>1</SysID>
+<File CompileType="Netlinx" Type="MasterSrc"><Identifier>main</Identifier><FilePathName>Source\main.axs</FilePathName></File>
+<File CompileType="Netlinx" Type="Include"><Identifier>a</Identifier><FilePathName>Includes\a.axi</FilePathName></File>
+</System></Project></Workspace>
+`
+
+// testServer returns a Server without logging
+func testServer(opts Options) *Server {
+	opts.Log = nil
+	return New(opts)
+}
+
+// zipFiles returns a zip holding files by name
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// post sends body to the server and returns the response
+func post(s *Server, target string, contentType string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// errorMessage returns the error from a JSON error response
+func errorMessage(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var e struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatalf("invalid error response %q: %v", w.Body.String(), err)
+	}
+	if e.Status != w.Code {
+		t.Errorf("status in body = %d, want %d", e.Status, w.Code)
+	}
+	return e.Error
+}
+
+func TestValidate(t *testing.T) {
+
+	s := testServer(DefaultOptions())
+
+	w := post(s, "/validate", "application/xml", []byte(testAPW))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var v validation
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valid || v.Projects != 1 || v.Systems != 1 || v.Files != 2 {
+		t.Errorf("validation = %+v, want valid with 1 project, 1 system and 2 files", v)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		ct     string
+		body   string
+		status int
+	}{
+		{"invalid xml", http.MethodPost, "application/xml", "<Workspace", http.StatusBadRequest},
+		{"empty body", http.MethodPost, "application/xml", "", http.StatusBadRequest},
+		{"wrong type", http.MethodPost, "application/json", testAPW, http.StatusUnsupportedMediaType},
+		{"wrong method", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/validate", strings.NewReader(tt.body))
+			if tt.ct != "" {
+				r.Header.Set("Content-Type", tt.ct)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			errorMessage(t, w)
+		})
+	}
+}
+
+func TestPackup(t *testing.T) {
+
+	s := testServer(DefaultOptions())
+	upload := zipFiles(t, map[string]string{
+		"ws/ws.apw":          testAPW,
+		"ws/Source/main.axs": "PROGRAM_NAME='main'\n",
+		"ws/Includes/a.axi":  "DEFINE_CONSTANT\n",
+	})
+
+	w := post(s, "/packup?type=archive&build=7", "application/zip", upload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "ws_7_archive.zip") {
+		t.Errorf("Content-Disposition = %q, want ws_7_archive.zip", cd)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"Includes/a.axi", "Source/main.axs", "ws.apw"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("package holds %q, want %q", names, want)
+	}
+
+	// A workspace with a missing file can't be archived
+	upload = zipFiles(t, map[string]string{
+		"ws.apw":          testAPW,
+		"Source/main.axs": "PROGRAM_NAME='main'\n",
+	})
+	w = post(s, "/packup", "application/zip", upload)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+	}
+	errorMessage(t, w)
+
+	w = post(s, "/packup?type=other", "application/zip", upload)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+	}
+}
+
+func TestUploadLimit(t *testing.T) {
+
+	s := testServer(Options{MaxBody: 1024, MaxUpload: 4096})
+
+	// Compressed upload over the limit
+	big := make([]byte, 8192)
+	for i := range big {
+		big[i] = byte(i * 7919 >> 3)
+	}
+	w := post(s, "/packup", "application/zip", append(zipFiles(t, map[string]string{"ws.apw": testAPW}), big...))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload status = %d, want 413: %s", w.Code, w.Body)
+	}
+
+	// A small zip which unpacks to more than the limit
+	w = post(s, "/packup", "application/zip", zipFiles(t, map[string]string{
+		"ws.apw":          testAPW,
+		"Source/main.axs": strings.Repeat(" ", 8192),
+	}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized unpacked status = %d, want 413: %s", w.Code, w.Body)
+	}
+	if msg := errorMessage(t, w); !strings.Contains(msg, "unpacked") {
+		t.Errorf("error = %q, want it to mention unpacking", msg)
+	}
+
+	// Other endpoints use the smaller body limit
+	w = post(s, "/validate", "application/xml", []byte(testAPW+strings.Repeat(" ", 1024)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body status = %d, want 413: %s", w.Code, w.Body)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	testServer(DefaultOptions()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	b, _ := ioutil.ReadAll(w.Body)
+	if !bytes.Contains(b, []byte("/nothing")) {
+		t.Errorf("body = %s, want the path", b)
+	}
+}
+
+func TestPackupOutsideUpload(t *testing.T) {
+
+	s := testServer(DefaultOptions())
+
+	for _, ref := range []string{`..\..\..\..\..\..\..\..\etc\passwd`, `/etc/passwd`, `Source\..\..\..\secret.axi`} {
+		t.Run(ref, func(t *testing.T) {
+			ws := strings.Replace(testAPW, `Includes\a.axi`, ref, 1)
+			w := post(s, "/packup?type=archive", "application/zip", zipFiles(t, map[string]string{
+				"ws/ws.apw":          ws,
+				"ws/Source/main.axs": "PROGRAM_NAME='main'\n",
+			}))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want an error rather than a package", ct)
+			}
+			var e struct {
+				Details []string `json:"details"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &e)
+			if len(e.Details) != 1 || e.Details[0] != ref {
+				t.Errorf("details = %q, want %q", e.Details, ref)
+			}
+		})
+	}
+}
+
+func TestPackupNameClash(t *testing.T) {
+
+	// Two different files which would both be Includes/a.axi
+	ws := strings.Replace(testAPW, "</System>", `<File CompileType="Netlinx" Type="Include"><Identifier>a2</Identifier><FilePathName>Other\a.axi</FilePathName></File></System>`, 1)
+	w := post(testServer(DefaultOptions()), "/packup?type=archive", "application/zip", zipFiles(t, map[string]string{
+		"ws.apw":          ws,
+		"Source/main.axs": "PROGRAM_NAME='main'\n",
+		"Includes/a.axi":  "DEFINE_CONSTANT\n",
+		"Other/a.axi":     "DEFINE_VARIABLE\n",
+	}))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+	}
+	if msg := errorMessage(t, w); !strings.Contains(msg, "Includes/a.axi") {
+		t.Errorf("error = %q, want the clashing name", msg)
+	}
+}
@@ -1,127 +1,163 @@
-package main 
+package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
 
-	"golang.org/x/sys/windows/registry" 
+	"github.com/soloworks/go-netlinx/studio/settings"
 )
 
-// ConfigFile is a structure holding all local settings
-type ConfigFile struct {
-	CompileDebug  bool     `json:"CompileWithDebug"` 
-	CompileSrc    bool     `json:"CompileWithSrc"`
-	SendSource    bool     `json:"SendSource"`
-	SmartTransfer bool     `json:"SmartTransfer"`
-	TabWidth      uint32   `json:"TabWidth"`
-	IndentWidth   uint32   `json:"IndentWidth"`
-	BasePath      string   `json:"BasePath"`
-	Modules       []string `json:"Modules"`
-	Includes      []string `json:"Includes"`
-	Libs          []string `json:"Libs"`
-}
-
-type regEntry struct {
-	Name  string
-	Value interface{}
-}
-
 var version = "undefined"
 
 type myargs struct {
 	configFile    string
 	clearExisting bool
+	regFile       string
+	baseFile      string
+	readFile      string
+	diff          bool
+	schemaFile    string
+	listKeys      bool
 }
 
 var args myargs
 
 func main() {
-	// Set ConfigFile Variable
-	cf := ConfigFile{}
 
 	// Get Command Line Variables
 	flag.StringVar(&args.configFile, "Config", "config.json", "Config File name")
 	flag.BoolVar(&args.clearExisting, "Clear", false, "Clear Existing Settings")
+	flag.StringVar(&args.regFile, "Reg", "", "Write the settings to this .reg file instead of the registry")
+	flag.StringVar(&args.baseFile, "Base", "", "Start from the settings in this .reg file instead of the registry")
+	flag.StringVar(&args.readFile, "Read", "", "Export the current settings as a config file (- for the console)")
+	flag.BoolVar(&args.diff, "Diff", false, "Show what would change without changing anything")
+	flag.StringVar(&args.schemaFile, "Schema", "", "Add to or correct the known Options settings from this file")
+	flag.BoolVar(&args.listKeys, "Keys", false, "List the known Options settings")
 	flag.Parse()
 
-	if !args.clearExisting {
-		// Load in Config Settings
-		file, err := os.Open(args.configFile)
+	if args.schemaFile != "" {
+		sc, err := settings.LoadSchema(args.schemaFile)
 		if err != nil {
-			log.Println("Error Loading config file " + args.configFile)
+			log.Println("Error Loading schema file " + args.schemaFile)
 			log.Println(err)
-			os.Exit(0)
+			os.Exit(1)
 		}
+		settings.KnownKeys = settings.KnownKeys.Merge(sc)
+	}
 
-		// Read Config Settings
-		decoder := json.NewDecoder(file)
-		err = decoder.Decode(&cf)
-		if err != nil {
-			log.Println("Config File Error: ")
-			log.Println(err)
-			os.Exit(0)
+	if args.listKeys {
+		for _, d := range settings.KnownKeys {
+			fmt.Printf("%-30s %-7s %s\\%s\n", d.Name, d.Type, d.Key, d.Value)
 		}
+		return
+	}
+
+	if args.baseFile != "" && args.regFile == "" && args.readFile == "" && !args.diff {
+		println("-Base needs -Reg, -Read or -Diff as the registry isn't changed")
+		os.Exit(1)
+	}
 
-		// Alter Config settings for %USERPROFILE% if present
-		cf.BasePath = strings.Replace(cf.BasePath, `%USERPROFILE%`, os.Getenv("USERPROFILE"), 1)
+	// A .reg replaces the folder lists, so needs the lists of the machine
+	// it is for, and leaves %USERPROFILE% for that machine to expand
+	if args.regFile != "" && args.baseFile == "" {
+		println("-Reg needs -Base, an export of the NetLinx Studio keys from the machine it is for")
+		os.Exit(1)
+	}
+	if args.regFile != "" {
+		settings.UserProfile = ""
+	}
 
-		// Change file paths to fully qualified based on base folder
-		for i, stub := range cf.Includes {
-			cf.Includes[i] = filepath.Join(cf.BasePath, stub)
+	// Pick where the settings are stored
+	var store settings.Store
+	var mem *settings.Memory
+	if args.baseFile != "" {
+		m, err := readReg(args.baseFile)
+		if err != nil {
+			log.Println("Error Loading base file " + args.baseFile)
+			log.Println(err)
+			os.Exit(1)
 		}
-		for i, stub := range cf.Modules {
-			cf.Modules[i] = filepath.Join(cf.BasePath, stub)
+		mem = m
+		store = mem
+		if args.regFile != "" {
+			for _, f := range []settings.Folder{settings.FolderInclude, settings.FolderModule, settings.FolderLib} {
+				if _, err := mem.ValueNames(settings.LocalMachine, f.Key()); err == settings.ErrNotExist {
+					println(args.baseFile + ` has no ` + settings.LocalMachine.String() + `\` + f.Key() + `, export it from the machine the .reg is for`)
+					os.Exit(1)
+				}
+			}
 		}
-		for i, stub := range cf.Libs {
-			cf.Libs[i] = filepath.Join(cf.BasePath, stub)
+	} else {
+		s, err := settings.NewSystemStore()
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
 		}
+		store = s
 	}
-	// Create Waitgroup for sync control
-	var wg sync.WaitGroup
 
-	// Call Folder Function
-	wg.Add(3)
-	updateFolders("Include", cf.Includes, &wg)
-	updateFolders("Module", cf.Modules, &wg)
-	updateFolders("Lib", cf.Libs, &wg)
+	if args.readFile != "" {
+		if err := exportConfig(store, args.readFile); err != nil {
+			log.Println("Error Reading Settings:")
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
+	// Load in Config Settings
+	var cf *settings.ConfigFile
 	if !args.clearExisting {
-		// Update Settings - Editor Preferences
-		Values := []regEntry{}
-		if cf.IndentWidth > 0 {
-			Values = append(Values, regEntry{"IndentWidth", cf.IndentWidth})
+		var err error
+		cf, err = settings.LoadConfig(args.configFile)
+		if err != nil {
+			log.Println("Error Loading config file " + args.configFile)
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if args.diff {
+		changes, err := settings.Diff(store, cf, args.clearExisting)
+		if err != nil {
+			log.Println("Error Reading Settings:")
+			log.Println(err)
+			os.Exit(1)
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No Changes")
 		}
-		if cf.TabWidth > 0 {
-			Values = append(Values, regEntry{"TabWidth", cf.TabWidth})
+		return
+	}
+
+	var err error
+	if args.clearExisting {
+		err = settings.Clear(store)
+	} else {
+		err = settings.Apply(store, cf)
+	}
+	if err != nil {
+		log.Println("Error Updating Settings:")
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	if args.regFile != "" {
+		if err := writeReg(mem, args.regFile); err != nil {
+			log.Println("Error Writing " + args.regFile)
+			log.Println(err)
+			os.Exit(1)
 		}
-		wg.Add(1)
-		updateSettings("Editor Preferences", Values, &wg)
-
-		// Update Settings - Batch Transfer User Options
-		Values = []regEntry{}
-		Values = append(Values, regEntry{"TP4 Smart Transfer", cf.SmartTransfer})
-		Values = append(Values, regEntry{"TP5 Smart Transfer", cf.SmartTransfer})
-		Values = append(Values, regEntry{"Auto Send SRC", cf.SendSource})
-		wg.Add(1)
-		updateSettings("Batch Transfer User Options", Values, &wg)
-
-		// Update Settings - NLXCompiler_Options
-		Values = []regEntry{}
-		Values = append(Values, regEntry{"BuildWithDebugInfo", cf.CompileDebug})
-		Values = append(Values, regEntry{"BuildWithSource", cf.CompileSrc})
-		Values = append(Values, regEntry{"EnableWC", true})
-		wg.Add(1)
-		updateSettings("NLXCompiler_Options", Values, &wg)
-	}
-	// Wait, Tidyup, End
-	wg.Wait()
+		fmt.Println("Written to " + args.regFile)
+	}
+
 	if args.clearExisting {
 		fmt.Println("Configuration Cleared")
 	} else {
@@ -129,108 +165,48 @@ func main() {
 	}
 }
 
-func updateSettings(KeyName string, Values []regEntry, wg *sync.WaitGroup) {
-	// Defer Sync
-	defer fmt.Println(`Updated Registry: HKEY_CURRENT_USER\Software\AMX Corp.\NetLinx Studio\` + KeyName)
-	defer wg.Done()
-
-	// Open Include Files Key
-	k, err := registry.OpenKey(registry.CURRENT_USER, `Software\AMX Corp.\NetLinx Studio\`+KeyName, registry.ALL_ACCESS)
+// readReg loads a .reg file
+func readReg(fn string) (*settings.Memory, error) {
+	f, err := os.Open(fn)
 	if err != nil {
-		log.Println("Error opening key: ")
-		log.Fatal(err)
-	}
-	defer k.Close()
-
-	// Set all of the Values
-	for _, v := range Values {
-		var newValue uint32
-		switch v.Value.(type) {
-		case bool:
-			if v.Value == true {
-				newValue = 1
-			}
-		case uint32:
-			newValue = v.Value.(uint32)
-		}
-		// Write Value to ValueName key
-		k.SetDWordValue(v.Name, newValue)
+		return nil, err
 	}
-
+	defer f.Close()
+	return settings.ReadReg(f)
 }
 
-func updateFolders(FolderType string, Folders []string, wg *sync.WaitGroup) {
-	// Defer Sync
-	defer fmt.Println(`Updated Registry: HKEY_LOCAL_MACHINE\SOFTWARE\WOW6432Node\AMX Corp.\NetLinx Studio\NLXCompiler_` + FolderType)
-	defer wg.Done()
-
-	// Open Include Files Key
-	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\WOW6432Node\AMX Corp.\NetLinx Studio\NLXCompiler_`+FolderType+`s`, registry.ALL_ACCESS)
+// writeReg saves a .reg file
+func writeReg(m *settings.Memory, fn string) error {
+	f, err := os.Create(fn)
 	if err != nil {
-		log.Println("Error opening key: ")
-		log.Fatal(err)
+		return err
+	}
+	if err := m.WriteReg(f); err != nil {
+		f.Close()
+		return err
 	}
-	defer k.Close()
+	return f.Close()
+}
 
-	// Read the list of value names
-	ValueNames, err := k.ReadValueNames(0)
+// exportConfig writes the settings in s as a config file, or to the console
+// if fn is -
+func exportConfig(s settings.Store, fn string) error {
+	cf, err := settings.Read(s)
 	if err != nil {
-		log.Println("Error Reading ValueNames: ")
-		log.Fatal(err)
-	}
-
-	// Process Existing Value strings
-	var vd []string
-	for _, vn := range ValueNames {
-		// Get the Value
-		v, _, _ := k.GetStringValue(vn)
-		// Append or Prepend if standard or user
-		if strings.Contains(v, `Common Files\AMXShare`) {
-			// Put system defaults to front of the list
-			vd = append([]string{v}, vd...)
-		} else {
-			// Put custom to end of the list (or not if clear enabled)
-			if !args.clearExisting {
-				vd = append(vd, v)
-			}
-		}
-		// Delete value as we will re-add them in a moment
-		err := k.DeleteValue(vn)
-		if err != nil {
-			log.Println("Error Deleting Key:")
-			log.Println(err)
-
-		}
+		return err
 	}
-
-	// Add our new ones (if not present and not set to clear)
-	if !args.clearExisting {
-		for _, f := range Folders {
-			var found bool
-			for x := range vd {
-				if f == vd[x] {
-					found = true
-				}
-			}
-			if !found {
-				vd = append(vd, f)
-			}
-		}
+	b, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		return err
 	}
-
-	// Store all keys back to the Registry
-	for x := range vd {
-		// Build Value Name
-		var s bytes.Buffer
-		switch FolderType {
-		case "Lib":
-			s.WriteString("Library")
-		default:
-			s.WriteString(FolderType)
-		}
-		s.WriteString("Directory")
-		s.WriteString(fmt.Sprintf("%03d", x))
-		// Write Value to ValueName key
-		k.SetStringValue(s.String(), vd[x])
+	b = append(b, '\n')
+	if fn == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+		return err
 	}
+	fmt.Println("Written to " + fn)
+	return nil
 }
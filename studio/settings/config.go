@@ -0,0 +1,372 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// StudioKey holds the user's Studio settings, under CurrentUser
+const StudioKey = `Software\AMX Corp.\NetLinx Studio`
+
+// CompilerKey holds the compiler folder lists, under LocalMachine
+const CompilerKey = `SOFTWARE\WOW6432Node\AMX Corp.\NetLinx Studio`
+
+// DefaultFolderMarker is in the path of every folder Studio installs
+const DefaultFolderMarker = `Common Files\AMXShare`
+
+// ConfigFile is a structure holding all local settings
+type ConfigFile struct {
+	CompileDebug  bool     `json:"CompileWithDebug"`
+	CompileSrc    bool     `json:"CompileWithSrc"`
+	SendSource    bool     `json:"SendSource"`
+	SmartTransfer bool     `json:"SmartTransfer"`
+	TabWidth      uint32   `json:"TabWidth"`
+	IndentWidth   uint32   `json:"IndentWidth"`
+	BasePath      string   `json:"BasePath"`
+	Modules       []string `json:"Modules"`
+	Includes      []string `json:"Includes"`
+	Libs          []string `json:"Libs"`
+
+	// Options holds any other settings by their KnownKeys name
+	Options map[string]json.RawMessage `json:"Options,omitempty"`
+}
+
+// UserProfile replaces %USERPROFILE% in configured paths. It is cleared
+// when writing a .reg file for another machine, so the paths keep the
+// variable and are stored as REG_EXPAND_SZ for Windows to expand
+var UserProfile = os.Getenv("USERPROFILE")
+
+// LoadConfig reads a ConfigFile, making the folders full paths below
+// BasePath. %USERPROFILE% is replaced when UserProfile is set
+func LoadConfig(fn string) (*ConfigFile, error) {
+
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cf := &ConfigFile{}
+	if err := json.NewDecoder(file).Decode(cf); err != nil {
+		return nil, err
+	}
+
+	// Alter Config settings for %USERPROFILE% if present
+	if UserProfile != "" {
+		cf.BasePath = strings.Replace(cf.BasePath, `%USERPROFILE%`, UserProfile, 1)
+	}
+
+	// Change file paths to fully qualified based on base folder
+	for _, fs := range [][]string{cf.Includes, cf.Modules, cf.Libs} {
+		for i, stub := range fs {
+			fs[i] = joinWindows(cf.BasePath, stub)
+		}
+	}
+
+	if err := cf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
+
+// Validate checks every entry of Options is in KnownKeys and has a
+// suitable value
+func (cf *ConfigFile) Validate() error {
+	var errs Errors
+	for _, name := range cf.optionNames() {
+		d, ok := KnownKeys.Find(name)
+		if !ok {
+			errs = append(errs, errors.New(name+": unknown setting"))
+			continue
+		}
+		if _, err := d.Parse(cf.Options[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errOrNil()
+}
+
+// optionNames returns the names in Options in order
+func (cf *ConfigFile) optionNames() []string {
+	var names []string
+	for name := range cf.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinWindows joins paths with \, as Studio always runs on Windows
+func joinWindows(base string, stub string) string {
+	if base == "" {
+		return stub
+	}
+	return strings.TrimRight(base, `\/`) + `\` + strings.Trim(stub, `\/`)
+}
+
+// Folder is a list of compiler search folders
+type Folder int
+
+// Folder lists
+const (
+	FolderInclude Folder = iota
+	FolderModule
+	FolderLib
+)
+
+var folders = [...]string{
+	"Include",
+	"Module",
+	"Lib",
+}
+
+var folderValues = [...]string{
+	"IncludeDirectory",
+	"ModuleDirectory",
+	"LibraryDirectory",
+}
+
+func (f Folder) String() string {
+	return folders[f]
+}
+
+// Key returns the key holding the list, under LocalMachine
+func (f Folder) Key() string {
+	return CompilerKey + `\NLXCompiler_` + folders[f] + "s"
+}
+
+// valueName returns the name of the value for the folder at index i
+func (f Folder) valueName(i int) string {
+	return fmt.Sprintf("%s%03d", folderValues[f], i)
+}
+
+// ReadFolders returns the folders in a list in order. A missing key has
+// none
+func ReadFolders(s Store, f Folder) ([]string, error) {
+
+	names, err := s.ValueNames(LocalMachine, f.Key())
+	if err == ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var fs []string
+	for _, n := range names {
+		v, err := s.GetValue(LocalMachine, f.Key(), n)
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind == KindString || v.Kind == KindExpandString {
+			fs = append(fs, v.String)
+		}
+	}
+	return fs, nil
+}
+
+// WriteFolders replaces a list with the folders given, numbered in order.
+// Folders using an environment variable are written as REG_EXPAND_SZ
+func WriteFolders(s Store, f Folder, fs []string) error {
+
+	names, err := s.ValueNames(LocalMachine, f.Key())
+	if err != nil && err != ErrNotExist {
+		return err
+	}
+	for _, n := range names {
+		if err := s.DeleteValue(LocalMachine, f.Key(), n); err != nil {
+			return err
+		}
+	}
+
+	for i, folder := range fs {
+		if err := s.SetValue(LocalMachine, f.Key(), f.valueName(i), pathValue(folder)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeFolders returns the folder list Studio should have. Folders Studio
+// installs always come first in their existing order, followed by any other
+// existing folders unless clear is set, then each of add not already listed.
+// Folders are compared ignoring case and only listed once
+func MergeFolders(existing []string, add []string, clear bool) []string {
+
+	var defaults, custom []string
+	for _, f := range existing {
+		if strings.Contains(f, DefaultFolderMarker) {
+			defaults = append(defaults, f)
+		} else if !clear {
+			custom = append(custom, f)
+		}
+	}
+
+	var fs []string
+	for _, list := range [][]string{defaults, custom, add} {
+		for _, f := range list {
+			if !containsFold(fs, f) {
+				fs = append(fs, f)
+			}
+		}
+	}
+	return fs
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Setting is a single value in the user's Studio settings
+type Setting struct {
+	Key   string
+	Name  string
+	Value Value
+}
+
+// option links a value under StudioKey to a field of the ConfigFile. An
+// option without read is only ever written
+type option struct {
+	key   string
+	name  string
+	write func(cf *ConfigFile) (Value, bool)
+	read  func(cf *ConfigFile, v Value)
+}
+
+var options = []option{
+	// Editor Preferences
+	{"Editor Preferences", "IndentWidth",
+		func(cf *ConfigFile) (Value, bool) { return DWordValue(cf.IndentWidth), cf.IndentWidth > 0 },
+		func(cf *ConfigFile, v Value) { cf.IndentWidth = v.DWord }},
+	{"Editor Preferences", "TabWidth",
+		func(cf *ConfigFile) (Value, bool) { return DWordValue(cf.TabWidth), cf.TabWidth > 0 },
+		func(cf *ConfigFile, v Value) { cf.TabWidth = v.DWord }},
+
+	// Batch Transfer User Options
+	{"Batch Transfer User Options", "TP4 Smart Transfer",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(cf.SmartTransfer), true },
+		func(cf *ConfigFile, v Value) { cf.SmartTransfer = v.DWord != 0 }},
+	{"Batch Transfer User Options", "TP5 Smart Transfer",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(cf.SmartTransfer), true },
+		nil},
+	{"Batch Transfer User Options", "Auto Send SRC",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(cf.SendSource), true },
+		func(cf *ConfigFile, v Value) { cf.SendSource = v.DWord != 0 }},
+
+	// NLXCompiler_Options
+	{"NLXCompiler_Options", "BuildWithDebugInfo",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(cf.CompileDebug), true },
+		func(cf *ConfigFile, v Value) { cf.CompileDebug = v.DWord != 0 }},
+	{"NLXCompiler_Options", "BuildWithSource",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(cf.CompileSrc), true },
+		func(cf *ConfigFile, v Value) { cf.CompileSrc = v.DWord != 0 }},
+	{"NLXCompiler_Options", "EnableWC",
+		func(cf *ConfigFile) (Value, bool) { return BoolValue(true), true },
+		nil},
+}
+
+// Settings returns the values the ConfigFile sets under StudioKey. Options
+// which aren't valid are left out
+func (cf *ConfigFile) Settings() []Setting {
+	var ss []Setting
+	for _, o := range options {
+		if v, ok := o.write(cf); ok {
+			ss = append(ss, Setting{o.key, o.name, v})
+		}
+	}
+	for _, name := range cf.optionNames() {
+		if d, ok := KnownKeys.Find(name); ok {
+			if vs, err := d.Parse(cf.Options[name]); err == nil {
+				ss = append(ss, vs...)
+			}
+		}
+	}
+	return ss
+}
+
+// folderLists pairs each Folder with its list in the ConfigFile
+func (cf *ConfigFile) folderLists() map[Folder][]string {
+	return map[Folder][]string{
+		FolderInclude: cf.Includes,
+		FolderModule:  cf.Modules,
+		FolderLib:     cf.Libs,
+	}
+}
+
+// Errors collects the errors from each key, so one failure doesn't stop
+// the rest being applied
+type Errors []error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// errOrNil returns e as an error, or nil if empty
+func (e Errors) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Apply adds the ConfigFile's folders and sets its options in the Store.
+// Nothing is changed if the ConfigFile isn't valid
+func Apply(s Store, cf *ConfigFile) error {
+
+	if err := cf.Validate(); err != nil {
+		return err
+	}
+
+	var errs Errors
+	for _, f := range []Folder{FolderInclude, FolderModule, FolderLib} {
+		if err := updateFolders(s, f, cf.folderLists()[f], false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, st := range cf.Settings() {
+		if err := s.SetValue(CurrentUser, StudioKey+`\`+st.Key, st.Name, st.Value); err != nil {
+			errs = append(errs, fmt.Errorf(`%s\%s\%s: %v`, CurrentUser, StudioKey+`\`+st.Key, st.Name, err))
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+// Clear removes every folder Studio didn't install from the folder lists
+func Clear(s Store) error {
+	var errs Errors
+	for _, f := range []Folder{FolderInclude, FolderModule, FolderLib} {
+		if err := updateFolders(s, f, nil, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.errOrNil()
+}
+
+// updateFolders merges a folder list in the Store
+func updateFolders(s Store, f Folder, add []string, clear bool) error {
+	existing, err := ReadFolders(s, f)
+	if err == nil {
+		err = WriteFolders(s, f, MergeFolders(existing, add, clear))
+	}
+	if err != nil {
+		return fmt.Errorf(`%s\%s: %v`, LocalMachine, f.Key(), err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	amxInclude = `C:\Program Files (x86)\Common Files\AMXShare\AXIs`
+	amxModule  = `C:\Program Files (x86)\Common Files\AMXShare\Duet\module`
+)
+
+func TestMergeFolders(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		existing []string
+		add      []string
+		clear    bool
+		want     []string
+	}{
+		{
+			name: "empty",
+			add:  []string{`C:\Code\Includes`},
+			want: []string{`C:\Code\Includes`},
+		},
+		{
+			name:     "defaults first",
+			existing: []string{`C:\Old`, amxInclude, `C:\Other`, amxModule},
+			add:      []string{`C:\Code\Includes`},
+			want:     []string{amxInclude, amxModule, `C:\Old`, `C:\Other`, `C:\Code\Includes`},
+		},
+		{
+			name:     "existing ignoring case",
+			existing: []string{amxInclude, `C:\Code\Includes`},
+			add:      []string{`c:\code\INCLUDES`, `C:\Code\Modules`},
+			want:     []string{amxInclude, `C:\Code\Includes`, `C:\Code\Modules`},
+		},
+		{
+			name:     "duplicates ignoring case",
+			existing: []string{amxInclude, amxInclude, `C:\Old`, `c:\old`},
+			add:      []string{`C:\New`, `C:\NEW`},
+			want:     []string{amxInclude, `C:\Old`, `C:\New`},
+		},
+		{
+			name:     "clear",
+			existing: []string{`C:\Old`, amxInclude, `C:\Other`},
+			add:      []string{`C:\Code\Includes`},
+			clear:    true,
+			want:     []string{amxInclude, `C:\Code\Includes`},
+		},
+		{
+			name:     "clear keeps a default added again",
+			existing: []string{amxInclude, `C:\Old`},
+			add:      []string{`C:\Old`, amxInclude},
+			clear:    true,
+			want:     []string{amxInclude, `C:\Old`},
+		},
+		{
+			name:     "clear without adding",
+			existing: []string{`C:\Old`},
+			clear:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeFolders(tt.existing, tt.add, tt.clear); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeFolders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFolders(t *testing.T) {
+
+	m := NewMemory()
+	for i, f := range []string{amxInclude, `C:\Old`, `C:\Code\Includes`} {
+		m.SetValue(LocalMachine, FolderInclude.Key(), FolderInclude.valueName(i), StringValue(f))
+	}
+
+	defer func(p string) { UserProfile = p }(UserProfile)
+	UserProfile = ""
+
+	cf := &ConfigFile{Includes: []string{`%USERPROFILE%\Code\Includes`, `c:\old`}}
+	if err := Apply(m, cf); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := ReadFolders(m, FolderInclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{amxInclude, `C:\Old`, `C:\Code\Includes`, `%USERPROFILE%\Code\Includes`}; !reflect.DeepEqual(fs, want) {
+		t.Errorf("folders = %q, want %q", fs, want)
+	}
+
+	// A folder using %USERPROFILE% is left for Windows to expand
+	v, _ := m.GetValue(LocalMachine, FolderInclude.Key(), "IncludeDirectory003")
+	if v.Kind != KindExpandString {
+		t.Errorf("kind = %v, want %v", v.Kind, KindExpandString)
+	}
+
+	// Clearing keeps the default at 000 and removes the rest
+	if err := Clear(m); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := m.ValueNames(LocalMachine, FolderInclude.Key())
+	if want := []string{"IncludeDirectory000"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	deleted := m.key(LocalMachine, FolderInclude.Key(), false).deleted
+	if want := []string{"IncludeDirectory001", "IncludeDirectory002", "IncludeDirectory003"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted = %q, want %q", deleted, want)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+
+	defer func(p string) { UserProfile = p }(UserProfile)
+	d, _ := KnownKeys.Find("Compiler.OutputDirectory")
+
+	UserProfile = `C:\Users\me`
+	ss, err := d.Parse([]byte(`"%USERPROFILE%\\Build"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ss[0].Value; !v.Equal(StringValue(`C:\Users\me\Build`)) {
+		t.Errorf("expanded = %s %q, want REG_SZ C:\\Users\\me\\Build", v.Kind, v.String)
+	}
+
+	UserProfile = ""
+	ss, err = d.Parse([]byte(`"%USERPROFILE%\\Build"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ss[0].Value; !v.Equal(ExpandStringValue(`%USERPROFILE%\Build`)) {
+		t.Errorf("kept = %s %q, want REG_EXPAND_SZ %%USERPROFILE%%\\Build", v.Kind, v.String)
+	}
+}
@@ -0,0 +1,125 @@
+package settings
+
+import (
+	"strings"
+)
+
+// ChangeType is the way a setting would change
+type ChangeType int
+
+// Change types
+const (
+	ChangeAdded ChangeType = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+var changeTypes = [...]string{
+	"+",
+	"-",
+	"~",
+}
+
+func (c ChangeType) String() string {
+	return changeTypes[c]
+}
+
+// Change is a difference between the settings in a Store and those it would
+// have once a ConfigFile is applied. Folders are compared as lists, so a
+// folder added or removed is a Change with no Name
+type Change struct {
+	Type ChangeType
+	Key  string
+	Name string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	label := c.Key
+	if c.Name != "" {
+		label += `\` + c.Name
+	}
+	switch c.Type {
+	case ChangeAdded:
+		return c.Type.String() + " " + label + ": " + c.New
+	case ChangeRemoved:
+		return c.Type.String() + " " + label + ": " + c.Old
+	}
+	return c.Type.String() + " " + label + ": " + c.Old + " -> " + c.New
+}
+
+// Diff lists what Apply would change in the Store, or Clear when clear is
+// set and cf is nil. Nothing is written
+func Diff(s Store, cf *ConfigFile, clear bool) ([]Change, error) {
+
+	var changes []Change
+
+	var lists map[Folder][]string
+	if cf != nil {
+		lists = cf.folderLists()
+	}
+	for _, f := range []Folder{FolderInclude, FolderModule, FolderLib} {
+		old, err := ReadFolders(s, f)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, diffFolders(f, old, MergeFolders(old, lists[f], clear))...)
+	}
+
+	if cf == nil {
+		return changes, nil
+	}
+	if err := cf.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, st := range cf.Settings() {
+		key := CurrentUser.String() + `\` + StudioKey + `\` + st.Key
+		old, err := s.GetValue(CurrentUser, StudioKey+`\`+st.Key, st.Name)
+		if err == ErrNotExist && st.Value.Equal(StringValue("")) {
+			// Studio treats an empty entry of a List as missing
+			continue
+		}
+		if err == ErrNotExist {
+			changes = append(changes, Change{ChangeAdded, key, st.Name, "", st.Value.Text()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !old.Equal(st.Value) {
+			changes = append(changes, Change{ChangeModified, key, st.Name, old.Text(), st.Value.Text()})
+		}
+	}
+
+	return changes, nil
+}
+
+// diffFolders compares two folder lists, reporting folders added and
+// removed, or the whole order if only that changed
+func diffFolders(f Folder, old []string, new []string) []Change {
+
+	key := LocalMachine.String() + `\` + f.Key()
+	var changes []Change
+
+	for _, n := range new {
+		if !containsFold(old, n) {
+			changes = append(changes, Change{Type: ChangeAdded, Key: key, New: n})
+		}
+	}
+	for _, o := range old {
+		if !containsFold(new, o) {
+			changes = append(changes, Change{Type: ChangeRemoved, Key: key, Old: o})
+		}
+	}
+
+	if len(changes) == 0 && len(old) == len(new) {
+		for i := range old {
+			if old[i] != new[i] {
+				return []Change{{ChangeModified, key, "order", strings.Join(old, "; "), strings.Join(new, "; ")}}
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,136 @@
+package settings
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// changeList formats changes one per line
+func changeList(cs []Change) string {
+	var lines []string
+	for _, c := range cs {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDiff(t *testing.T) {
+
+	defer func(p string) { UserProfile = p }(UserProfile)
+	UserProfile = ""
+
+	m := NewMemory()
+	for i, f := range []string{amxInclude, `C:\Old`} {
+		m.SetValue(LocalMachine, FolderInclude.Key(), FolderInclude.valueName(i), StringValue(f))
+	}
+	base := &ConfigFile{
+		Includes: []string{`C:\Code\Includes`},
+		TabWidth: 4,
+		Options: map[string]json.RawMessage{
+			"Editor.FontSize": json.RawMessage(`10`),
+			"Recent.Files":    json.RawMessage(`["a.axs", "b.axs"]`),
+		},
+	}
+	if err := Apply(m, base); err != nil {
+		t.Fatal(err)
+	}
+
+	// A config already applied changes nothing
+	cs, err := Diff(m, base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 0 {
+		t.Errorf("diff of the applied config:\n%s", changeList(cs))
+	}
+
+	cf := &ConfigFile{
+		Includes:     []string{`C:\Code\Includes`, `C:\Code\More`},
+		Modules:      []string{`C:\Code\Modules`},
+		TabWidth:     8,
+		CompileDebug: true,
+		Options: map[string]json.RawMessage{
+			"Editor.FontSize":   json.RawMessage(`12`),
+			"Editor.TextColour": json.RawMessage(`"#FF0000"`),
+			"Recent.Files":      json.RawMessage(`["a.axs"]`),
+		},
+	}
+	inc := `HKEY_LOCAL_MACHINE\` + FolderInclude.Key()
+	mod := `HKEY_LOCAL_MACHINE\` + FolderModule.Key()
+	user := `HKEY_CURRENT_USER\` + StudioKey
+	tests := []struct {
+		name  string
+		cf    *ConfigFile
+		clear bool
+		want  []string
+	}{
+		{
+			name: "apply",
+			cf:   cf,
+			want: []string{
+				`+ ` + inc + `: C:\Code\More`,
+				`+ ` + mod + `: C:\Code\Modules`,
+				`~ ` + user + `\Editor Preferences\TabWidth: 4 -> 8`,
+				`~ ` + user + `\NLXCompiler_Options\BuildWithDebugInfo: 0 -> 1`,
+				`~ ` + user + `\Editor Preferences\FontSize: 10 -> 12`,
+				`+ ` + user + `\Editor Preferences\TextColor: 255`,
+				`~ ` + user + `\Recent File List\File2: b.axs -> `,
+			},
+		},
+		{
+			name:  "apply clearing",
+			cf:    cf,
+			clear: true,
+			want: []string{
+				`+ ` + inc + `: C:\Code\More`,
+				`- ` + inc + `: C:\Old`,
+				`+ ` + mod + `: C:\Code\Modules`,
+				`~ ` + user + `\Editor Preferences\TabWidth: 4 -> 8`,
+				`~ ` + user + `\NLXCompiler_Options\BuildWithDebugInfo: 0 -> 1`,
+				`~ ` + user + `\Editor Preferences\FontSize: 10 -> 12`,
+				`+ ` + user + `\Editor Preferences\TextColor: 255`,
+				`~ ` + user + `\Recent File List\File2: b.axs -> `,
+			},
+		},
+		{
+			name:  "clear",
+			clear: true,
+			want: []string{
+				`- ` + inc + `: C:\Old`,
+				`- ` + inc + `: C:\Code\Includes`,
+			},
+		},
+		{
+			name:  "reorder",
+			cf:    &ConfigFile{Includes: []string{`C:\Code\Includes`, `C:\Old`}, TabWidth: 4},
+			clear: true,
+			want: []string{
+				`~ ` + inc + `\order: ` + amxInclude + `; C:\Old; C:\Code\Includes -> ` + amxInclude + `; C:\Code\Includes; C:\Old`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := Diff(m, tt.cf, tt.clear)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := changeList(cs), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("Diff() =\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	// Nothing was written
+	if cs, err := Diff(m, base, false); err != nil || len(cs) != 0 {
+		t.Errorf("store changed by Diff:\n%s", changeList(cs))
+	}
+
+	// An invalid config is an error rather than a diff
+	bad := &ConfigFile{Options: map[string]json.RawMessage{"Editor.FontSize": json.RawMessage(`100`)}}
+	if _, err := Diff(m, bad, false); err == nil {
+		t.Error("no error diffing an invalid config")
+	}
+}
@@ -0,0 +1,126 @@
+package settings
+
+import (
+	"sort"
+	"strings"
+)
+
+// Memory is a Store held in memory, for tests and for building .reg files.
+// Keys and names are matched ignoring case, as in the registry. Deleted
+// values are remembered so they can be removed when written to a .reg file
+type Memory struct {
+	keys map[string]*memKey
+}
+
+type memKey struct {
+	root    Root
+	path    string
+	names   []string
+	values  map[string]Value
+	deleted []string
+}
+
+// NewMemory returns an empty Memory store
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]*memKey)}
+}
+
+func keyID(root Root, key string) string {
+	return root.String() + `\` + strings.ToLower(strings.Trim(key, `\`))
+}
+
+// key returns the key, creating it if create is set
+func (m *Memory) key(root Root, key string, create bool) *memKey {
+	id := keyID(root, key)
+	k, ok := m.keys[id]
+	if !ok && create {
+		k = &memKey{root: root, path: strings.Trim(key, `\`), values: make(map[string]Value)}
+		m.keys[id] = k
+	}
+	return k
+}
+
+// find returns the stored name matching name ignoring case, and its index
+func find(names []string, name string) (string, int) {
+	for i, n := range names {
+		if strings.EqualFold(n, name) {
+			return n, i
+		}
+	}
+	return "", -1
+}
+
+// ValueNames implements Store
+func (m *Memory) ValueNames(root Root, key string) ([]string, error) {
+	k := m.key(root, key, false)
+	if k == nil {
+		return nil, ErrNotExist
+	}
+	return append([]string(nil), k.names...), nil
+}
+
+// GetValue implements Store
+func (m *Memory) GetValue(root Root, key string, name string) (Value, error) {
+	k := m.key(root, key, false)
+	if k == nil {
+		return Value{}, ErrNotExist
+	}
+	n, i := find(k.names, name)
+	if i < 0 {
+		return Value{}, ErrNotExist
+	}
+	return k.values[n], nil
+}
+
+// SetValue implements Store
+func (m *Memory) SetValue(root Root, key string, name string, v Value) error {
+	k := m.key(root, key, true)
+	if n, i := find(k.names, name); i >= 0 {
+		k.values[n] = v
+		return nil
+	}
+	if _, i := find(k.deleted, name); i >= 0 {
+		k.deleted = append(k.deleted[:i], k.deleted[i+1:]...)
+	}
+	k.names = append(k.names, name)
+	k.values[name] = v
+	return nil
+}
+
+// DeleteValue implements Store
+func (m *Memory) DeleteValue(root Root, key string, name string) error {
+	k := m.key(root, key, false)
+	if k == nil {
+		return ErrNotExist
+	}
+	n, i := find(k.names, name)
+	if i < 0 {
+		return ErrNotExist
+	}
+	k.names = append(k.names[:i], k.names[i+1:]...)
+	delete(k.values, n)
+	k.markDeleted(n)
+	return nil
+}
+
+// markDeleted records that name has been removed
+func (k *memKey) markDeleted(name string) {
+	if _, i := find(k.deleted, name); i < 0 {
+		k.deleted = append(k.deleted, name)
+	}
+}
+
+// sorted returns the keys in order of root and path
+func (m *Memory) sorted() []*memKey {
+	var ks []*memKey
+	for _, k := range m.keys {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		if ks[i].root != ks[j].root {
+			return ks[i].root < ks[j].root
+		}
+		return strings.ToLower(ks[i].path) < strings.ToLower(ks[j].path)
+	})
+	return ks
+}
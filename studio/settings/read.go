@@ -0,0 +1,119 @@
+package settings
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Read returns the settings in the Store as a ConfigFile, in the same form
+// as config.json. Folders Studio installs are left out, and the folder the
+// rest have in common becomes BasePath, using %USERPROFILE% where it
+// applies. Missing values are left as zero, and KnownKeys found are added
+// to Options
+func Read(s Store) (*ConfigFile, error) {
+
+	cf := &ConfigFile{Includes: []string{}, Modules: []string{}, Libs: []string{}}
+
+	lists := map[Folder][]string{}
+	var all []string
+	for _, f := range []Folder{FolderInclude, FolderModule, FolderLib} {
+		fs, err := ReadFolders(s, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range fs {
+			if !strings.Contains(folder, DefaultFolderMarker) {
+				lists[f] = append(lists[f], folder)
+				all = append(all, folder)
+			}
+		}
+	}
+
+	base := commonDir(all)
+	for f, fs := range lists {
+		for _, folder := range fs {
+			if base != "" {
+				folder = folder[len(base)+1:]
+			}
+			switch f {
+			case FolderInclude:
+				cf.Includes = append(cf.Includes, folder)
+			case FolderModule:
+				cf.Modules = append(cf.Modules, folder)
+			case FolderLib:
+				cf.Libs = append(cf.Libs, folder)
+			}
+		}
+	}
+	cf.BasePath = profilePath(base)
+
+	for _, o := range options {
+		if o.read == nil {
+			continue
+		}
+		v, err := s.GetValue(CurrentUser, StudioKey+`\`+o.key, o.name)
+		if err == ErrNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v.Kind == KindDWord {
+			o.read(cf, v)
+		}
+	}
+
+	for _, d := range KnownKeys {
+		raw, ok, err := d.Read(s)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if cf.Options == nil {
+				cf.Options = map[string]json.RawMessage{}
+			}
+			cf.Options[d.Name] = raw
+		}
+	}
+
+	return cf, nil
+}
+
+// commonDir returns the deepest folder, ignoring case, which contains all
+// of paths. Only folders with at least two parts are used, so nothing is
+// returned for paths only sharing a drive
+func commonDir(paths []string) string {
+
+	if len(paths) == 0 {
+		return ""
+	}
+
+	common := strings.Split(paths[0], `\`)
+	common = common[:len(common)-1]
+	for _, p := range paths[1:] {
+		parts := strings.Split(p, `\`)
+		n := 0
+		for n < len(common) && n < len(parts)-1 && strings.EqualFold(common[n], parts[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+
+	if len(common) < 2 {
+		return ""
+	}
+	return strings.Join(common, `\`)
+}
+
+// profilePath replaces the user's profile folder at the start of p with
+// %USERPROFILE%
+func profilePath(p string) string {
+	profile := strings.TrimRight(UserProfile, `\`)
+	if profile == "" || len(p) < len(profile) || !strings.EqualFold(p[:len(profile)], profile) {
+		return p
+	}
+	if len(p) > len(profile) && p[len(profile)] != '\\' {
+		return p
+	}
+	return "%USERPROFILE%" + p[len(profile):]
+}
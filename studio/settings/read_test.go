@@ -0,0 +1,138 @@
+package settings
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+
+	defer func(p string) { UserProfile = p }(UserProfile)
+	UserProfile = `C:\Users\me`
+
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(fn, []byte(`{
+	"CompileWithDebug": true,
+	"SmartTransfer": true,
+	"TabWidth": 4,
+	"BasePath": "%USERPROFILE%\\Code",
+	"Includes": ["Includes", "Shared\\Includes"],
+	"Modules": ["Modules"],
+	"Options": {
+		"Comms.Connection": "usb",
+		"Compiler.OutputDirectory": "%USERPROFILE%\\Build",
+		"Editor.TextColour": "#102030",
+		"Recent.Files": ["a.axs", "b.axs"]
+	}
+}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := LoadConfig(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMemory()
+	m.SetValue(LocalMachine, FolderInclude.Key(), FolderInclude.valueName(0), StringValue(amxInclude))
+	if err := Apply(m, cf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config comes back as it was written, less the folders Studio
+	// installs, with every option read given
+	got, err := Read(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.MarshalIndent(got, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"CompileWithDebug": true,
+	"CompileWithSrc": false,
+	"SendSource": false,
+	"SmartTransfer": true,
+	"TabWidth": 4,
+	"IndentWidth": 0,
+	"BasePath": "%USERPROFILE%\\Code",
+	"Modules": [
+		"Modules"
+	],
+	"Includes": [
+		"Includes",
+		"Shared\\Includes"
+	],
+	"Libs": [],
+	"Options": {
+		"Comms.Connection": "USB",
+		"Compiler.OutputDirectory": "%USERPROFILE%\\Build",
+		"Editor.TextColour": "#102030",
+		"Recent.Files": [
+			"a.axs",
+			"b.axs"
+		]
+	}
+}`
+	if string(b) != want {
+		t.Errorf("Read() =\n%s\nwant:\n%s", b, want)
+	}
+
+	// Applying what was read changes nothing
+	got.BasePath = UserProfile + `\Code`
+	for _, fs := range [][]string{got.Includes, got.Modules, got.Libs} {
+		for i, stub := range fs {
+			fs[i] = joinWindows(got.BasePath, stub)
+		}
+	}
+	cs, err := Diff(m, got, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 0 {
+		t.Errorf("diff of the config read:\n%s", changeList(cs))
+	}
+}
+
+func TestReadEmpty(t *testing.T) {
+
+	got, err := Read(NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(got)
+	want := `{"CompileWithDebug":false,"CompileWithSrc":false,"SendSource":false,"SmartTransfer":false,"TabWidth":0,"IndentWidth":0,"BasePath":"","Modules":[],"Includes":[],"Libs":[]}`
+	if string(b) != want {
+		t.Errorf("Read() = %s, want %s", b, want)
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{`C:\Code\Includes`}, `C:\Code`},
+		{[]string{`C:\Code\Includes`, `c:\code\Shared\Modules`}, `C:\Code`},
+		{[]string{`C:\Code\A\Includes`, `C:\Code\A\Modules`}, `C:\Code\A`},
+		{[]string{`C:\Code\Includes`, `C:\Other\Modules`}, ""},
+		{[]string{`C:\Code\Includes`, `D:\Code\Includes`}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := commonDir(tt.paths); got != tt.want {
+			t.Errorf("commonDir(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
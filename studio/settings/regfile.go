@@ -0,0 +1,252 @@
+package settings
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// RegHeader is the first line of a .reg file
+const RegHeader = "Windows Registry Editor Version 5.00"
+
+// regExpandSZ is the registry type of REG_EXPAND_SZ, written in a .reg file
+// as hex(2) UTF-16 text
+const regExpandSZ = 2
+
+// ReadReg loads a .reg file, as exported by regedit in UTF-16 or saved as
+// UTF-8, into a Memory store
+func ReadReg(r io.Reader) (*Memory, error) {
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := decodeReg(b)
+
+	m := NewMemory()
+	var root Root
+	var key string
+	inKey := false
+
+	lines := joinContinued(strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n"))
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case i == 0:
+			if line != RegHeader && line != "REGEDIT4" {
+				return nil, errors.New("not a .reg file")
+			}
+		case line == "" || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[-"):
+			// Key deletions aren't kept
+			inKey = false
+		case strings.HasPrefix(line, "["):
+			path := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			parts := strings.SplitN(path, `\`, 2)
+			root, err = ParseRoot(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			key = ""
+			if len(parts) > 1 {
+				key = parts[1]
+			}
+			m.key(root, key, true)
+			inKey = true
+		default:
+			if !inKey {
+				return nil, errors.New("value outside a key: " + line)
+			}
+			name, v, del, err := parseRegValue(line)
+			if err != nil {
+				return nil, err
+			}
+			if del {
+				m.key(root, key, true).markDeleted(name)
+				continue
+			}
+			m.SetValue(root, key, name, v)
+		}
+	}
+
+	return m, nil
+}
+
+// WriteReg writes every key in the store as a .reg file, including
+// deletions for values which have been removed
+func (m *Memory) WriteReg(w io.Writer) error {
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(RegHeader + "\r\n")
+
+	for _, k := range m.sorted() {
+		bw.WriteString("\r\n[" + k.root.String() + `\` + k.path + "]\r\n")
+		for _, n := range k.deleted {
+			bw.WriteString(quoteReg(n) + "=-\r\n")
+		}
+		for _, n := range k.names {
+			bw.WriteString(quoteReg(n) + "=" + formatRegValue(k.values[n]) + "\r\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// decodeReg returns the text of a .reg file, converting from UTF-16 when
+// it starts with a byte order mark
+func decodeReg(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe {
+		return decodeUTF16(b[2:])
+	}
+	return string(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+}
+
+// decodeUTF16 converts little endian UTF-16 to a string
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[i*2]) | uint16(b[i*2+1])<<8
+	}
+	return string(utf16.Decode(u))
+}
+
+// encodeUTF16 converts a string to little endian UTF-16
+func encodeUTF16(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+// joinContinued joins lines ending in \, as used for long hex values
+func joinContinued(lines []string) []string {
+	var out []string
+	cont := false
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		if cont {
+			out[len(out)-1] += strings.TrimSpace(l)
+		} else {
+			out = append(out, l)
+		}
+		cont = strings.HasSuffix(l, `\`) && !strings.HasSuffix(l, `"`)
+		if cont {
+			out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], `\`)
+		}
+	}
+	return out
+}
+
+// parseRegValue reads a "name"=value line. del is set for "name"=-
+func parseRegValue(line string) (string, Value, bool, error) {
+
+	var name, rest string
+	if strings.HasPrefix(line, "@=") {
+		rest = line[2:]
+	} else {
+		n, r, err := unquoteReg(line)
+		if err != nil {
+			return "", Value{}, false, err
+		}
+		if !strings.HasPrefix(r, "=") {
+			return "", Value{}, false, errors.New("missing = in " + line)
+		}
+		name, rest = n, r[1:]
+	}
+
+	switch {
+	case rest == "-":
+		return name, Value{}, true, nil
+	case strings.HasPrefix(rest, `"`):
+		s, _, err := unquoteReg(rest)
+		return name, StringValue(s), false, err
+	case strings.HasPrefix(rest, "dword:"):
+		d, err := strconv.ParseUint(rest[6:], 16, 32)
+		return name, DWordValue(uint32(d)), false, err
+	case strings.HasPrefix(rest, "hex:"):
+		b, err := parseHex(rest[4:])
+		return name, BinaryValue(b), false, err
+	case strings.HasPrefix(rest, "hex("):
+		end := strings.Index(rest, "):")
+		if end < 0 {
+			return "", Value{}, false, errors.New("bad value in " + line)
+		}
+		t, err := strconv.ParseInt(rest[4:end], 16, 32)
+		if err != nil {
+			return "", Value{}, false, err
+		}
+		b, err := parseHex(rest[end+2:])
+		if t == regExpandSZ {
+			return name, ExpandStringValue(strings.TrimRight(decodeUTF16(b), "\x00")), false, err
+		}
+		return name, Value{Kind: KindRaw, RawType: int(t), Data: b}, false, err
+	}
+	return "", Value{}, false, errors.New("unsupported value in " + line)
+}
+
+// parseHex reads comma separated hex bytes
+func parseHex(s string) ([]byte, error) {
+	var b []byte
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		c, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, byte(c))
+	}
+	return b, nil
+}
+
+// unquoteReg reads a quoted string from the start of s, returning the rest
+func unquoteReg(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", errors.New("expected quoted string in " + s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", errors.New("unterminated string in " + s)
+}
+
+// quoteReg quotes a name or string for a .reg file
+func quoteReg(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+// formatRegValue formats a Value for a .reg file
+func formatRegValue(v Value) string {
+	switch v.Kind {
+	case KindString:
+		return quoteReg(v.String)
+	case KindDWord:
+		d := strconv.FormatUint(uint64(v.DWord), 16)
+		return "dword:" + strings.Repeat("0", 8-len(d)) + d
+	case KindRaw:
+		return "hex(" + strconv.FormatInt(int64(v.RawType), 16) + "):" + hexBytes(v.Data)
+	case KindExpandString:
+		return "hex(2):" + hexBytes(encodeUTF16(v.String+"\x00"))
+	}
+	return "hex:" + hexBytes(v.Data)
+}
@@ -0,0 +1,129 @@
+package settings
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// utf16Reg returns text as regedit exports it, in UTF-16 with a byte order
+// mark and CRLF line endings
+func utf16Reg(text string) []byte {
+	return append([]byte{0xff, 0xfe}, encodeUTF16(strings.Replace(text, "\n", "\r\n", -1))...)
+}
+
+func TestRegRoundTrip(t *testing.T) {
+
+	key := StudioKey + `\Editor Preferences`
+	m := NewMemory()
+	m.SetValue(CurrentUser, key, "FontName", StringValue(`Consolas "Mono" \ Ünïcode`))
+	m.SetValue(CurrentUser, key, "TabWidth", DWordValue(3))
+	m.SetValue(CurrentUser, key, "Colours", BinaryValue([]byte{0x1e, 0x1e, 0x1e, 0x00}))
+	m.SetValue(CurrentUser, key, "Recent", Value{Kind: KindRaw, RawType: 7, Data: []byte{0x61, 0x00, 0x00, 0x00}})
+	m.SetValue(CurrentUser, key, "Old", StringValue("gone"))
+	m.DeleteValue(CurrentUser, key, "Old")
+	m.SetValue(LocalMachine, FolderInclude.Key(), "IncludeDirectory000", ExpandStringValue(`%USERPROFILE%\Code`))
+
+	var first bytes.Buffer
+	if err := m.WriteReg(&first); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(first.String(), "\r\n\"Old\"=-\r\n") {
+		t.Errorf("deletion not written:\n%s", first.String())
+	}
+
+	r, err := ReadReg(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"FontName", "TabWidth", "Colours", "Recent"} {
+		want, _ := m.GetValue(CurrentUser, key, name)
+		if got, err := r.GetValue(CurrentUser, key, name); err != nil || !got.Equal(want) || got.Kind != want.Kind {
+			t.Errorf("%s = %+v, %v, want %+v", name, got, err, want)
+		}
+	}
+	if got, _ := r.GetValue(LocalMachine, FolderInclude.Key(), "IncludeDirectory000"); !got.Equal(ExpandStringValue(`%USERPROFILE%\Code`)) {
+		t.Errorf("expand string = %+v", got)
+	}
+	if _, err := r.GetValue(CurrentUser, key, "Old"); err != ErrNotExist {
+		t.Errorf("deleted value read as %v", err)
+	}
+
+	var second bytes.Buffer
+	if err := r.WriteReg(&second); err != nil {
+		t.Fatal(err)
+	}
+	if second.String() != first.String() {
+		t.Errorf("written again as\n%s\nwant\n%s", second.String(), first.String())
+	}
+}
+
+func TestReadRegUTF16(t *testing.T) {
+
+	reg := utf16Reg(RegHeader + `
+
+[HKEY_LOCAL_MACHINE\SOFTWARE\WOW6432Node\AMX Corp.\NetLinx Studio\NLXCompiler_Includes]
+"IncludeDirectory000"="C:\\Program Files (x86)\\Common Files\\AMXShare\\AXIs"
+"IncludeDirectory001"=hex(2):25,00,55,00,53,00,45,00,52,00,50,00,52,00,4f,00,46,00,\
+  49,00,4c,00,45,00,25,00,5c,00,43,00,00,00
+"IncludeDirectory002"=-
+
+[-HKEY_CURRENT_USER\Software\AMX Corp.\NetLinx Studio\Removed]
+
+[HKCU\Software\AMX Corp.\NetLinx Studio\Editor Preferences]
+; comment
+"FontName"="Café"
+"TabWidth"=dword:0000000a
+`)
+
+	m, err := ReadReg(bytes.NewReader(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := ReadFolders(m, FolderInclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 2 || fs[0] != amxInclude || fs[1] != `%USERPROFILE%\C` {
+		t.Errorf("folders = %q", fs)
+	}
+	if v, _ := m.GetValue(CurrentUser, StudioKey+`\Editor Preferences`, "fontname"); !v.Equal(StringValue("Café")) {
+		t.Errorf("FontName = %+v", v)
+	}
+	if v, _ := m.GetValue(CurrentUser, StudioKey+`\Editor Preferences`, "TabWidth"); !v.Equal(DWordValue(10)) {
+		t.Errorf("TabWidth = %+v", v)
+	}
+	if _, err := m.ValueNames(CurrentUser, StudioKey+`\Removed`); err != ErrNotExist {
+		t.Errorf("deleted key kept: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := m.WriteReg(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\"IncludeDirectory002\"=-\r\n") {
+		t.Errorf("deletion not kept:\n%s", b.String())
+	}
+}
+
+func TestReadRegErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		reg  string
+	}{
+		{"no header", "[HKEY_CURRENT_USER\\Software]\n"},
+		{"value outside a key", RegHeader + "\n\"Name\"=\"x\"\n"},
+		{"unknown root", RegHeader + "\n[HKEY_USERS\\Software]\n"},
+		{"unterminated", RegHeader + "\n[HKCU\\Software]\n\"Name\"=\"x\n"},
+		{"bad dword", RegHeader + "\n[HKCU\\Software]\n\"Name\"=dword:xyz\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadReg(strings.NewReader(tt.reg)); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
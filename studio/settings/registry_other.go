@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package settings
+
+import "errors"
+
+// NewSystemStore returns the Store used by Studio on this machine, which is
+// only available on Windows
+func NewSystemStore() (Store, error) {
+	return nil, errors.New("the registry is only available on Windows, write a .reg file instead")
+}
@@ -0,0 +1,110 @@
+package settings
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// Registry is a Store using the Windows registry
+type Registry struct{}
+
+// NewRegistry returns a Store for the Windows registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewSystemStore returns the Store used by Studio on this machine
+func NewSystemStore() (Store, error) {
+	return NewRegistry(), nil
+}
+
+func rootKey(root Root) registry.Key {
+	if root == LocalMachine {
+		return registry.LOCAL_MACHINE
+	}
+	return registry.CURRENT_USER
+}
+
+// regError converts the registry's not found error
+func regError(err error) error {
+	if err == registry.ErrNotExist {
+		return ErrNotExist
+	}
+	return err
+}
+
+// ValueNames implements Store
+func (r *Registry) ValueNames(root Root, key string) ([]string, error) {
+	k, err := registry.OpenKey(rootKey(root), key, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, regError(err)
+	}
+	defer k.Close()
+	names, err := k.ReadValueNames(0)
+	return names, regError(err)
+}
+
+// GetValue implements Store
+func (r *Registry) GetValue(root Root, key string, name string) (Value, error) {
+
+	k, err := registry.OpenKey(rootKey(root), key, registry.QUERY_VALUE)
+	if err != nil {
+		return Value{}, regError(err)
+	}
+	defer k.Close()
+
+	n, typ, err := k.GetValue(name, nil)
+	if err != nil {
+		return Value{}, regError(err)
+	}
+
+	switch typ {
+	case registry.SZ:
+		s, _, err := k.GetStringValue(name)
+		return StringValue(s), regError(err)
+	case registry.EXPAND_SZ:
+		s, _, err := k.GetStringValue(name)
+		return ExpandStringValue(s), regError(err)
+	case registry.DWORD:
+		d, _, err := k.GetIntegerValue(name)
+		return DWordValue(uint32(d)), regError(err)
+	case registry.BINARY:
+		b, _, err := k.GetBinaryValue(name)
+		return BinaryValue(b), regError(err)
+	}
+
+	b := make([]byte, n)
+	_, _, err = k.GetValue(name, b)
+	return Value{Kind: KindRaw, RawType: int(typ), Data: b}, regError(err)
+}
+
+// SetValue implements Store
+func (r *Registry) SetValue(root Root, key string, name string, v Value) error {
+
+	k, _, err := registry.CreateKey(rootKey(root), key, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	switch v.Kind {
+	case KindString:
+		return k.SetStringValue(name, v.String)
+	case KindExpandString:
+		return k.SetExpandStringValue(name, v.String)
+	case KindDWord:
+		return k.SetDWordValue(name, v.DWord)
+	case KindBinary:
+		return k.SetBinaryValue(name, v.Data)
+	}
+	return errUnsupported(v)
+}
+
+// DeleteValue implements Store
+func (r *Registry) DeleteValue(root Root, key string, name string) error {
+	k, err := registry.OpenKey(rootKey(root), key, registry.SET_VALUE)
+	if err != nil {
+		return regError(err)
+	}
+	defer k.Close()
+	return regError(k.DeleteValue(name))
+}
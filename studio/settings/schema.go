@@ -0,0 +1,412 @@
+package settings
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Type is the form a setting takes in a config file, and how it is stored
+type Type int
+
+// Setting types. Bool, Int, Choice and Colour are stored as REG_DWORD, a
+// Choice by its index and a Colour as a Windows COLORREF. String and Path
+// are REG_SZ, with %USERPROFILE% replaced in a Path, or REG_EXPAND_SZ when
+// it is kept. Binary is REG_BINARY given as hex. List is a numbered set of
+// REG_SZ values, such as File1 to File10, as Studio keeps its recent lists
+const (
+	TypeBool Type = iota
+	TypeInt
+	TypeChoice
+	TypeString
+	TypePath
+	TypeColour
+	TypeBinary
+	TypeList
+)
+
+var types = [...]string{
+	"bool",
+	"int",
+	"choice",
+	"string",
+	"path",
+	"colour",
+	"binary",
+	"list",
+}
+
+func (t Type) String() string {
+	return types[t]
+}
+
+// ParseType returns the Type with the given name
+func ParseType(s string) (Type, error) {
+	for i, n := range types {
+		if strings.EqualFold(n, s) {
+			return Type(i), nil
+		}
+	}
+	return 0, errors.New("unknown type " + s)
+}
+
+// MarshalJSON writes the Type by name
+func (t Type) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON reads the Type by name
+func (t *Type) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := ParseType(s)
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// KeyDef describes a setting which can be given under Options in a config
+// file, by the Name used there, such as Editor.FontSize. Key is below
+// StudioKey and Value is the value name, or the prefix of each value for a
+// List. Min and Max limit an Int, and Max limits the length of a String or
+// Path and is the number of entries in a List. A Max of 0 is no limit,
+// except for a List which needs one. Choices are the names of a Choice in
+// order
+type KeyDef struct {
+	Name    string   `json:"Name"`
+	Key     string   `json:"Key"`
+	Value   string   `json:"Value"`
+	Type    Type     `json:"Type"`
+	Min     int      `json:"Min,omitempty"`
+	Max     int      `json:"Max,omitempty"`
+	Choices []string `json:"Choices,omitempty"`
+}
+
+// Schema is a set of known settings
+type Schema []KeyDef
+
+// KnownKeys are the settings config files can give under Options. Value
+// names can differ between Studio versions, so a schema file can correct or
+// add to them with Merge
+var KnownKeys = Schema{
+	// Compiler
+	{Name: "Compiler.WarningLevel", Key: "NLXCompiler_Options", Value: "WarningLevel", Type: TypeInt, Min: 0, Max: 4},
+	{Name: "Compiler.WarningsAsErrors", Key: "NLXCompiler_Options", Value: "WarningsAsErrors", Type: TypeBool},
+	{Name: "Compiler.SaveBeforeCompile", Key: "NLXCompiler_Options", Value: "SaveBeforeCompile", Type: TypeBool},
+	{Name: "Compiler.OutputDirectory", Key: "NLXCompiler_Options", Value: "OutputDirectory", Type: TypePath, Max: 260},
+	{Name: "Compiler.UseOutputDirectory", Key: "NLXCompiler_Options", Value: "UseOutputDirectory", Type: TypeBool},
+
+	// Transfer
+	{Name: "Transfer.RebootAfterTransfer", Key: "Batch Transfer User Options", Value: "Reboot After Transfer", Type: TypeBool},
+	{Name: "Transfer.SendTKN", Key: "Batch Transfer User Options", Value: "Auto Send TKN", Type: TypeBool},
+	{Name: "Transfer.SendIRL", Key: "Batch Transfer User Options", Value: "Auto Send IRL", Type: TypeBool},
+	{Name: "Transfer.Timeout", Key: "Batch Transfer User Options", Value: "Timeout", Type: TypeInt, Min: 5, Max: 600},
+
+	// Editor
+	{Name: "Editor.FontName", Key: "Editor Preferences", Value: "FontName", Type: TypeString, Max: 31},
+	{Name: "Editor.FontSize", Key: "Editor Preferences", Value: "FontSize", Type: TypeInt, Min: 6, Max: 72},
+	{Name: "Editor.InsertSpaces", Key: "Editor Preferences", Value: "InsertSpaces", Type: TypeBool},
+	{Name: "Editor.LineNumbers", Key: "Editor Preferences", Value: "LineNumbers", Type: TypeBool},
+	{Name: "Editor.BackgroundColour", Key: "Editor Preferences", Value: "BackgroundColor", Type: TypeColour},
+	{Name: "Editor.TextColour", Key: "Editor Preferences", Value: "TextColor", Type: TypeColour},
+	{Name: "Editor.KeywordColour", Key: "Editor Preferences", Value: "KeywordColor", Type: TypeColour},
+	{Name: "Editor.CommentColour", Key: "Editor Preferences", Value: "CommentColor", Type: TypeColour},
+	{Name: "Editor.StringColour", Key: "Editor Preferences", Value: "StringColor", Type: TypeColour},
+	{Name: "Editor.NumberColour", Key: "Editor Preferences", Value: "NumberColor", Type: TypeColour},
+
+	// Recent lists
+	{Name: "Recent.Workspaces", Key: "Recent Workspace List", Value: "File", Type: TypeList, Max: 10},
+	{Name: "Recent.Files", Key: "Recent File List", Value: "File", Type: TypeList, Max: 10},
+
+	// Communication
+	{Name: "Comms.Connection", Key: "Communication Settings", Value: "Connection Type", Type: TypeChoice, Choices: []string{"TCP/IP", "Serial", "USB", "Virtual"}},
+	{Name: "Comms.Address", Key: "Communication Settings", Value: "IP Address", Type: TypeString, Max: 255},
+	{Name: "Comms.Port", Key: "Communication Settings", Value: "IP Port", Type: TypeInt, Min: 1, Max: 65535},
+	{Name: "Comms.SerialPort", Key: "Communication Settings", Value: "COM Port", Type: TypeString, Max: 16},
+	{Name: "Comms.BaudRate", Key: "Communication Settings", Value: "Baud Rate", Type: TypeInt, Min: 300, Max: 115200},
+	{Name: "Comms.PingTimeout", Key: "Communication Settings", Value: "Ping Timeout", Type: TypeInt, Min: 1, Max: 60},
+}
+
+// Find returns the KeyDef with the given name, ignoring case
+func (sc Schema) Find(name string) (KeyDef, bool) {
+	for _, d := range sc {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return KeyDef{}, false
+}
+
+// Merge returns the Schema with each of extra added, replacing any KeyDef
+// of the same name
+func (sc Schema) Merge(extra Schema) Schema {
+	merged := append(Schema{}, sc...)
+	for _, d := range extra {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, d.Name) {
+				merged[i] = d
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// LoadSchema reads a JSON list of KeyDefs, checking each is usable
+func LoadSchema(fn string) (Schema, error) {
+
+	file, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sc Schema
+	if err := json.NewDecoder(file).Decode(&sc); err != nil {
+		return nil, err
+	}
+
+	var errs Errors
+	for _, d := range sc {
+		if err := d.check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return sc, errs.errOrNil()
+}
+
+// check returns an error if the KeyDef can't be used
+func (d KeyDef) check() error {
+	switch {
+	case d.Name == "" || d.Key == "" || d.Value == "":
+		return fmt.Errorf("%q: Name, Key and Value are needed", d.Name)
+	case d.Type == TypeList && d.Max < 1:
+		return fmt.Errorf("%s: a list needs a Max", d.Name)
+	case d.Type == TypeChoice && len(d.Choices) == 0:
+		return fmt.Errorf("%s: a choice needs Choices", d.Name)
+	case d.Type == TypeInt && d.Max != 0 && d.Max < d.Min:
+		return fmt.Errorf("%s: Max is below Min", d.Name)
+	}
+	return nil
+}
+
+// valueName returns the name of the value at index i of a List, or the
+// value name of any other Type
+func (d KeyDef) valueName(i int) string {
+	if d.Type == TypeList {
+		return d.Value + strconv.Itoa(i+1)
+	}
+	return d.Value
+}
+
+// Parse checks a setting from a config file, returning the values to write.
+// Every entry of a List is written, with those unused left empty
+func (d KeyDef) Parse(raw json.RawMessage) ([]Setting, error) {
+
+	v, err := d.parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", d.Name, err)
+	}
+
+	if d.Type != TypeList {
+		return []Setting{{d.Key, d.Value, v[0]}}, nil
+	}
+	ss := make([]Setting, d.Max)
+	for i := range ss {
+		ss[i] = Setting{d.Key, d.valueName(i), StringValue("")}
+		if i < len(v) {
+			ss[i].Value = v[i]
+		}
+	}
+	return ss, nil
+}
+
+func (d KeyDef) parse(raw json.RawMessage) ([]Value, error) {
+
+	switch d.Type {
+	case TypeBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, errors.New("expected true or false")
+		}
+		return []Value{BoolValue(b)}, nil
+
+	case TypeInt:
+		var n int64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, errors.New("expected a whole number")
+		}
+		if n < int64(d.Min) || (d.Max != 0 && n > int64(d.Max)) || n < 0 || n > 0xFFFFFFFF {
+			return nil, fmt.Errorf("%d is outside %d to %d", n, d.Min, d.Max)
+		}
+		return []Value{DWordValue(uint32(n))}, nil
+
+	case TypeChoice:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.New("expected one of " + strings.Join(d.Choices, ", "))
+		}
+		for i, c := range d.Choices {
+			if strings.EqualFold(c, s) {
+				return []Value{DWordValue(uint32(i))}, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of %s", s, strings.Join(d.Choices, ", "))
+
+	case TypeColour:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.New("expected a colour as #RRGGBB")
+		}
+		c, err := parseColour(s)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{DWordValue(c)}, nil
+
+	case TypeBinary:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.New("expected hex bytes")
+		}
+		b, err := hex.DecodeString(strings.NewReplacer(",", "", " ", "").Replace(s))
+		if err != nil {
+			return nil, errors.New("expected hex bytes")
+		}
+		return []Value{BinaryValue(b)}, nil
+
+	case TypeList:
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, errors.New("expected a list of text")
+		}
+		if len(list) > d.Max {
+			return nil, fmt.Errorf("%d entries is more than %d", len(list), d.Max)
+		}
+		var vs []Value
+		for _, s := range list {
+			vs = append(vs, StringValue(s))
+		}
+		return vs, nil
+	}
+
+	// String and Path
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, errors.New("expected text")
+	}
+	if d.Type == TypePath {
+		if UserProfile != "" {
+			s = strings.Replace(s, `%USERPROFILE%`, UserProfile, 1)
+		}
+		if !windowsAbs(s) && !strings.HasPrefix(s, `%USERPROFILE%\`) {
+			return nil, fmt.Errorf("%q is not a full path", s)
+		}
+	}
+	if d.Max != 0 && len(s) > d.Max {
+		return nil, fmt.Errorf("longer than %d characters", d.Max)
+	}
+	if d.Type == TypePath {
+		return []Value{pathValue(s)}, nil
+	}
+	return []Value{StringValue(s)}, nil
+}
+
+// Read returns the setting from the Store in config file form, and false if
+// none of its values are there
+func (d KeyDef) Read(s Store) (json.RawMessage, bool, error) {
+
+	key := StudioKey + `\` + d.Key
+	n := 1
+	if d.Type == TypeList {
+		n = d.Max
+	}
+
+	var vs []Value
+	for i := 0; i < n; i++ {
+		v, err := s.GetValue(CurrentUser, key, d.valueName(i))
+		if err == ErrNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		vs = append(vs, v)
+	}
+	if len(vs) == 0 {
+		return nil, false, nil
+	}
+
+	var x interface{}
+	switch d.Type {
+	case TypeBool:
+		x = vs[0].DWord != 0
+	case TypeInt:
+		x = vs[0].DWord
+	case TypeChoice:
+		x = vs[0].DWord
+		if int(vs[0].DWord) < len(d.Choices) {
+			x = d.Choices[vs[0].DWord]
+		}
+	case TypeColour:
+		x = formatColour(vs[0].DWord)
+	case TypeBinary:
+		x = hex.EncodeToString(vs[0].Data)
+	case TypeList:
+		list := []string{}
+		for _, v := range vs {
+			if v.String != "" {
+				list = append(list, v.String)
+			}
+		}
+		x = list
+	case TypePath:
+		x = profilePath(vs[0].String)
+	default:
+		x = vs[0].String
+	}
+
+	b, err := json.Marshal(x)
+	return b, err == nil, err
+}
+
+// parseColour converts #RRGGBB to a COLORREF, which holds blue highest
+func parseColour(s string) (uint32, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, fmt.Errorf("%q is not a colour as #RRGGBB", s)
+	}
+	rgb, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a colour as #RRGGBB", s)
+	}
+	r, g, b := rgb>>16, rgb>>8&0xFF, rgb&0xFF
+	return uint32(b<<16 | g<<8 | r), nil
+}
+
+// formatColour converts a COLORREF to #RRGGBB
+func formatColour(c uint32) string {
+	return fmt.Sprintf("#%02X%02X%02X", c&0xFF, c>>8&0xFF, c>>16&0xFF)
+}
+
+// windowsAbs returns true for a Windows path from a drive or share
+func windowsAbs(p string) bool {
+	if strings.HasPrefix(p, `\\`) {
+		return true
+	}
+	if len(p) < 3 || p[1] != ':' || (p[2] != '\\' && p[2] != '/') {
+		return false
+	}
+	return (p[0] >= 'A' && p[0] <= 'Z') || (p[0] >= 'a' && p[0] <= 'z')
+}
@@ -0,0 +1,189 @@
+package settings
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+
+	for i, name := range types {
+		got, err := ParseType(strings.ToUpper(name))
+		if err != nil || got != Type(i) {
+			t.Errorf("ParseType(%q) = %v, %v, want %v", strings.ToUpper(name), got, err, Type(i))
+		}
+	}
+	if _, err := ParseType("dword"); err == nil {
+		t.Error("ParseType(dword) gave no error")
+	}
+
+	// Types are read and written by name in schema files
+	var d KeyDef
+	if err := json.Unmarshal([]byte(`{"Type": "Colour"}`), &d); err != nil || d.Type != TypeColour {
+		t.Errorf("unmarshalled Type = %v, %v", d.Type, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Type": "rgb"}`), &d); err == nil {
+		t.Error("unknown Type unmarshalled")
+	}
+	if b, _ := json.Marshal(TypeList); string(b) != `"list"` {
+		t.Errorf("marshalled TypeList = %s", b)
+	}
+}
+
+func TestKeyDefParse(t *testing.T) {
+
+	defer func(p string) { UserProfile = p }(UserProfile)
+	UserProfile = `C:\Users\me`
+
+	list := KeyDef{Name: "Recent", Key: "Recent File List", Value: "File", Type: TypeList, Max: 3}
+	tests := []struct {
+		name string
+		d    KeyDef
+		raw  string
+		want string
+		err  string
+	}{
+		{name: "bool", d: KeyDef{Type: TypeBool}, raw: `true`, want: "REG_DWORD 1"},
+		{name: "bool text", d: KeyDef{Type: TypeBool}, raw: `"yes"`, err: "expected true or false"},
+
+		{name: "int", d: KeyDef{Type: TypeInt, Min: 6, Max: 72}, raw: `72`, want: "REG_DWORD 72"},
+		{name: "int below min", d: KeyDef{Type: TypeInt, Min: 6, Max: 72}, raw: `5`, err: "5 is outside 6 to 72"},
+		{name: "int above max", d: KeyDef{Type: TypeInt, Min: 6, Max: 72}, raw: `73`, err: "73 is outside 6 to 72"},
+		{name: "int no max", d: KeyDef{Type: TypeInt}, raw: `4294967295`, want: "REG_DWORD 4294967295"},
+		{name: "int beyond dword", d: KeyDef{Type: TypeInt}, raw: `4294967296`, err: "outside"},
+		{name: "int negative", d: KeyDef{Type: TypeInt, Min: -5}, raw: `-1`, err: "outside"},
+		{name: "int fraction", d: KeyDef{Type: TypeInt}, raw: `1.5`, err: "expected a whole number"},
+
+		{name: "choice", d: KeyDef{Type: TypeChoice, Choices: []string{"TCP/IP", "Serial", "USB"}}, raw: `"TCP/IP"`, want: "REG_DWORD 0"},
+		{name: "choice any case", d: KeyDef{Type: TypeChoice, Choices: []string{"TCP/IP", "Serial", "USB"}}, raw: `"usb"`, want: "REG_DWORD 2"},
+		{name: "choice unknown", d: KeyDef{Type: TypeChoice, Choices: []string{"TCP/IP", "Serial", "USB"}}, raw: `"Modem"`, err: `"Modem" is not one of TCP/IP, Serial, USB`},
+		{name: "choice index", d: KeyDef{Type: TypeChoice, Choices: []string{"TCP/IP", "Serial", "USB"}}, raw: `1`, err: "expected one of"},
+
+		{name: "colour", d: KeyDef{Type: TypeColour}, raw: `"#102030"`, want: "REG_DWORD 3153936"},
+		{name: "colour red", d: KeyDef{Type: TypeColour}, raw: `"#ff0000"`, want: "REG_DWORD 255"},
+		{name: "colour blue", d: KeyDef{Type: TypeColour}, raw: `"#0000FF"`, want: "REG_DWORD 16711680"},
+		{name: "colour short", d: KeyDef{Type: TypeColour}, raw: `"#FFF"`, err: "not a colour"},
+		{name: "colour not hex", d: KeyDef{Type: TypeColour}, raw: `"#GG0000"`, err: "not a colour"},
+
+		{name: "binary", d: KeyDef{Type: TypeBinary}, raw: `"01,ab 0F"`, want: "REG_BINARY 01,ab,0f"},
+		{name: "binary odd", d: KeyDef{Type: TypeBinary}, raw: `"abc"`, err: "expected hex bytes"},
+
+		{name: "string", d: KeyDef{Type: TypeString, Max: 5}, raw: `"Arial"`, want: "REG_SZ Arial"},
+		{name: "string too long", d: KeyDef{Type: TypeString, Max: 5}, raw: `"Courier"`, err: "longer than 5 characters"},
+		{name: "path", d: KeyDef{Type: TypePath}, raw: `"D:\\Build"`, want: `REG_SZ D:\Build`},
+		{name: "path expanded", d: KeyDef{Type: TypePath}, raw: `"%USERPROFILE%\\Build"`, want: `REG_SZ C:\Users\me\Build`},
+		{name: "path share", d: KeyDef{Type: TypePath}, raw: `"\\\\server\\build"`, want: `REG_SZ \\server\build`},
+		{name: "path relative", d: KeyDef{Type: TypePath}, raw: `"Build"`, err: `"Build" is not a full path`},
+		{name: "path too long", d: KeyDef{Type: TypePath, Max: 8}, raw: `"D:\\Build\\Out"`, err: "longer than 8 characters"},
+
+		{name: "list", d: list, raw: `["a.axs", "b.axs"]`, want: "REG_SZ a.axs, REG_SZ b.axs, REG_SZ "},
+		{name: "list full", d: list, raw: `["a", "b", "c"]`, want: "REG_SZ a, REG_SZ b, REG_SZ c"},
+		{name: "list empty", d: list, raw: `[]`, want: "REG_SZ , REG_SZ , REG_SZ "},
+		{name: "list too long", d: list, raw: `["a", "b", "c", "d"]`, err: "4 entries is more than 3"},
+		{name: "list text", d: list, raw: `"a"`, err: "expected a list of text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Name = "Test"
+			ss, err := tt.d.Parse(json.RawMessage(tt.raw))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.HasPrefix(err.Error(), "Test: ") {
+					t.Errorf("Parse(%s) error = %v, want %q", tt.raw, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range ss {
+				got = append(got, s.Value.Kind.String()+" "+s.Value.Text())
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("Parse(%s) = %q, want %q", tt.raw, strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyDefList(t *testing.T) {
+
+	d, _ := KnownKeys.Find("recent.files")
+	ss, err := d.Parse(json.RawMessage(`["a.axs", "b.axs"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every entry is written, numbered from 1, so older ones are cleared
+	if len(ss) != 10 {
+		t.Fatalf("%d settings, want 10", len(ss))
+	}
+	for i, s := range ss {
+		want := Setting{"Recent File List", "File" + strconv.Itoa(i+1), StringValue("")}
+		if i < 2 {
+			want.Value = StringValue([]string{"a.axs", "b.axs"}[i])
+		}
+		if s.Key != want.Key || s.Name != want.Name || !s.Value.Equal(want.Value) {
+			t.Errorf("setting %d = %+v, want %+v", i, s, want)
+		}
+	}
+
+	// Reading skips the empty entries
+	m := NewMemory()
+	for _, s := range ss {
+		m.SetValue(CurrentUser, StudioKey+`\`+s.Key, s.Name, s.Value)
+	}
+	raw, ok, err := d.Read(m)
+	if err != nil || !ok || string(raw) != `["a.axs","b.axs"]` {
+		t.Errorf("Read() = %s, %v, %v", raw, ok, err)
+	}
+}
+
+func TestColour(t *testing.T) {
+
+	for _, s := range []string{"#000000", "#FFFFFF", "#102030", "#ABCDEF"} {
+		c, err := parseColour(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatColour(c); got != s {
+			t.Errorf("formatColour(parseColour(%s)) = %s", s, got)
+		}
+	}
+
+	// A COLORREF holds red lowest
+	if c, _ := parseColour("#112233"); c != 0x332211 {
+		t.Errorf("parseColour(#112233) = %#x, want 0x332211", c)
+	}
+}
+
+func TestKeyDefCheck(t *testing.T) {
+
+	tests := []struct {
+		d   KeyDef
+		err string
+	}{
+		{KeyDef{Name: "A", Key: "K", Value: "V", Type: TypeBool}, ""},
+		{KeyDef{Name: "A", Key: "K", Type: TypeBool}, "Name, Key and Value are needed"},
+		{KeyDef{Name: "A", Key: "K", Value: "V", Type: TypeList}, "a list needs a Max"},
+		{KeyDef{Name: "A", Key: "K", Value: "V", Type: TypeChoice}, "a choice needs Choices"},
+		{KeyDef{Name: "A", Key: "K", Value: "V", Type: TypeInt, Min: 5, Max: 1}, "Max is below Min"},
+	}
+
+	for _, tt := range tests {
+		err := tt.d.check()
+		if (err == nil) != (tt.err == "") || err != nil && !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("check(%+v) = %v, want %q", tt.d, err, tt.err)
+		}
+	}
+
+	// Every built in key is usable
+	for _, d := range KnownKeys {
+		if err := d.check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
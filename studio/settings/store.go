@@ -0,0 +1,169 @@
+package settings
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Root is the top level registry key a setting is under
+type Root int
+
+// Roots used by Netlinx Studio
+const (
+	LocalMachine Root = iota
+	CurrentUser
+)
+
+var roots = [...]string{
+	"HKEY_LOCAL_MACHINE",
+	"HKEY_CURRENT_USER",
+}
+
+func (r Root) String() string {
+	return roots[r]
+}
+
+// ParseRoot returns the Root named s, accepting the short forms HKLM and HKCU
+func ParseRoot(s string) (Root, error) {
+	switch strings.ToUpper(s) {
+	case "HKEY_LOCAL_MACHINE", "HKLM":
+		return LocalMachine, nil
+	case "HKEY_CURRENT_USER", "HKCU":
+		return CurrentUser, nil
+	}
+	return 0, errors.New("unsupported registry root " + s)
+}
+
+// Kind is the type of a Value
+type Kind int
+
+// Value kinds. Raw holds any other registry type as bytes, so it can be
+// passed through .reg files unchanged. ExpandString is text with
+// environment variables Windows expands when it is read
+const (
+	KindString Kind = iota
+	KindDWord
+	KindBinary
+	KindRaw
+	KindExpandString
+)
+
+var kinds = [...]string{
+	"REG_SZ",
+	"REG_DWORD",
+	"REG_BINARY",
+	"REG_RAW",
+	"REG_EXPAND_SZ",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+// Value is a registry value of any Kind
+type Value struct {
+	Kind    Kind
+	String  string
+	DWord   uint32
+	Data    []byte
+	RawType int
+}
+
+// StringValue returns a REG_SZ Value
+func StringValue(s string) Value {
+	return Value{Kind: KindString, String: s}
+}
+
+// ExpandStringValue returns a REG_EXPAND_SZ Value
+func ExpandStringValue(s string) Value {
+	return Value{Kind: KindExpandString, String: s}
+}
+
+// pathValue returns a path as a REG_EXPAND_SZ Value if it uses an
+// environment variable such as %USERPROFILE%, otherwise as REG_SZ
+func pathValue(p string) Value {
+	if strings.Count(p, "%") >= 2 {
+		return ExpandStringValue(p)
+	}
+	return StringValue(p)
+}
+
+// DWordValue returns a REG_DWORD Value
+func DWordValue(d uint32) Value {
+	return Value{Kind: KindDWord, DWord: d}
+}
+
+// BoolValue returns a REG_DWORD Value of 1 or 0
+func BoolValue(b bool) Value {
+	if b {
+		return DWordValue(1)
+	}
+	return DWordValue(0)
+}
+
+// BinaryValue returns a REG_BINARY Value
+func BinaryValue(b []byte) Value {
+	return Value{Kind: KindBinary, Data: b}
+}
+
+// Equal returns true if both Values have the same Kind and contents
+func (v Value) Equal(o Value) bool {
+	if v.Kind != o.Kind {
+		return false
+	}
+	switch v.Kind {
+	case KindString, KindExpandString:
+		return v.String == o.String
+	case KindDWord:
+		return v.DWord == o.DWord
+	case KindRaw:
+		if v.RawType != o.RawType {
+			return false
+		}
+	}
+	return string(v.Data) == string(o.Data)
+}
+
+// Text returns the Value as it is shown to people
+func (v Value) Text() string {
+	switch v.Kind {
+	case KindString, KindExpandString:
+		return v.String
+	case KindDWord:
+		return strconv.FormatUint(uint64(v.DWord), 10)
+	}
+	return hexBytes(v.Data)
+}
+
+// ErrNotExist is returned for a key or value which doesn't exist
+var ErrNotExist = errors.New("not found")
+
+// Store reads and writes Studio settings. Keys are paths below a Root, such
+// as Software\AMX Corp.\NetLinx Studio\Editor Preferences, and are created
+// as needed when a value is set. Names are returned in the order the
+// values were created
+type Store interface {
+	ValueNames(root Root, key string) ([]string, error)
+	GetValue(root Root, key string, name string) (Value, error)
+	SetValue(root Root, key string, name string, v Value) error
+	DeleteValue(root Root, key string, name string) error
+}
+
+// errUnsupported is returned when a backend can't store a Value
+func errUnsupported(v Value) error {
+	return errors.New("unsupported value type " + v.Kind.String() + " " + strconv.Itoa(v.RawType))
+}
+
+// hexBytes formats bytes as comma separated hex, as in .reg files
+func hexBytes(b []byte) string {
+	var parts []string
+	for _, c := range b {
+		h := strconv.FormatUint(uint64(c), 16)
+		if len(h) == 1 {
+			h = "0" + h
+		}
+		parts = append(parts, h)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,409 @@
+package syntax
+
+// Node is any part of the syntax tree, positioned where it starts
+type Node interface {
+	Pos() Pos
+}
+
+// Decl is a top level part of a File: a *Section, *FuncDecl or *Directive
+type Decl interface {
+	Node
+	declNode()
+}
+
+// Stmt is a statement within a block, DEFINE_START or DEFINE_PROGRAM
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// File is a parsed .axs or .axi file. Header is nil for an include without
+// PROGRAM_NAME or MODULE_NAME
+type File struct {
+	Name   string
+	Header *Header
+	Decls  []Decl
+}
+
+// Header is the PROGRAM_NAME or MODULE_NAME of a File, with the parameters
+// of a module
+type Header struct {
+	At     Pos
+	Module bool
+	Name   string
+	Params []*VarDecl
+}
+
+// Directive is a preprocessor line such as #INCLUDE 'file.axi'. Ident is
+// the name given to #DEFINE, #IF_DEFINED and #IF_NOT_DEFINED, and Value is
+// the rest of the line, such as the file of an #INCLUDE
+type Directive struct {
+	At    Pos
+	Name  string
+	Ident string
+	Value Expr
+}
+
+// Section is a DEFINE_ section. Items are *VarDecl in DEFINE_DEVICE,
+// DEFINE_CONSTANT and DEFINE_VARIABLE, *TypeDecl in DEFINE_TYPE, *EventDecl
+// in DEFINE_EVENT, *ModuleInst in DEFINE_MODULE, and Stmt in the rest. Any
+// may also be a *Directive
+type Section struct {
+	At    Pos
+	Kind  SectionKind
+	Items []Node
+}
+
+// VarDecl declares a variable, constant, device, parameter or structure
+// field. Type is upper case for built in types and empty if not given.
+// Dims holds an Expr for each dimension, nil if the size isn't given
+type VarDecl struct {
+	At         Pos
+	Qualifiers []string
+	Type       string
+	Name       string
+	Dims       []Expr
+	Value      Expr
+}
+
+// TypeDecl is a STRUCTURE in DEFINE_TYPE
+type TypeDecl struct {
+	At     Pos
+	Name   string
+	Fields []*VarDecl
+}
+
+// FuncDecl is a DEFINE_FUNCTION, DEFINE_CALL or DEFINE_LIBRARY_FUNCTION.
+// Type is the return type, if any, with TypeDims for a sized CHAR[n]
+// result. Body is nil for a library function
+type FuncDecl struct {
+	At       Pos
+	Kind     FuncKind
+	Type     string
+	TypeDims []Expr
+	Name     string
+	Params   []*VarDecl
+	Body     *Block
+}
+
+// EventDecl is a handler for one or more events in DEFINE_EVENT. Events
+// such as BUTTON_EVENT have Handlers for PUSH, RELEASE and so on, while
+// LEVEL_EVENT and TIMELINE_EVENT have a Body
+type EventDecl struct {
+	At       Pos
+	Events   []*EventHead
+	Handlers []*Handler
+	Body     *Block
+}
+
+// EventHead is an event being handled, such as BUTTON_EVENT[dvTP, 1]
+type EventHead struct {
+	At   Pos
+	Kind string
+	Args []Expr
+}
+
+// Handler is a part of an EventDecl, such as PUSH: or HOLD[20, REPEAT]:
+type Handler struct {
+	At   Pos
+	Name string
+	Args []Expr
+	Body Stmt
+}
+
+// ModuleInst is a module used in DEFINE_MODULE, such as
+// 'Projector_Comm' mdlProj(vdvProj, dvProj)
+type ModuleInst struct {
+	At       Pos
+	Module   string
+	Instance string
+	Args     []Expr
+}
+
+// Block is a list of statements in braces
+type Block struct {
+	At    Pos
+	Stmts []Stmt
+}
+
+// DeclStmt declares variables within a block
+type DeclStmt struct {
+	At   Pos
+	Vars []*VarDecl
+}
+
+// AssignStmt is Lhs = Rhs
+type AssignStmt struct {
+	At  Pos
+	Lhs Expr
+	Rhs Expr
+}
+
+// IncDecStmt is X++ or X--
+type IncDecStmt struct {
+	At Pos
+	X  Expr
+	Op string
+}
+
+// ExprStmt is an expression used as a statement, usually a call
+type ExprStmt struct {
+	At Pos
+	X  Expr
+}
+
+// IfStmt is IF with an optional ELSE
+type IfStmt struct {
+	At   Pos
+	Cond Expr
+	Then Stmt
+	Else Stmt
+}
+
+// WhileStmt is WHILE, MEDIUM_WHILE or LONG_WHILE, by Kind
+type WhileStmt struct {
+	At   Pos
+	Kind string
+	Cond Expr
+	Body Stmt
+}
+
+// ForStmt is FOR (Init; Cond; Post) Body, where any part may be nil
+type ForStmt struct {
+	At   Pos
+	Init Stmt
+	Cond Expr
+	Post Stmt
+	Body Stmt
+}
+
+// SwitchStmt is SWITCH with its CASE and DEFAULT clauses
+type SwitchStmt struct {
+	At    Pos
+	Tag   Expr
+	Cases []*CaseClause
+}
+
+// SelectStmt is SELECT with its ACTIVE clauses
+type SelectStmt struct {
+	At    Pos
+	Cases []*CaseClause
+}
+
+// CaseClause is a CASE or ACTIVE, with Value its value or condition, or
+// DEFAULT with a nil Value
+type CaseClause struct {
+	At    Pos
+	Value Expr
+	Body  []Stmt
+}
+
+// WaitStmt is WAIT, WAIT_UNTIL or TIMED_WAIT_UNTIL, by Kind. Cond is nil
+// for WAIT and Time is nil for WAIT_UNTIL. Name is empty if not given
+type WaitStmt struct {
+	At   Pos
+	Kind string
+	Cond Expr
+	Time Expr
+	Name string
+	Body Stmt
+}
+
+// ReturnStmt is RETURN with an optional Value
+type ReturnStmt struct {
+	At    Pos
+	Value Expr
+}
+
+// BreakStmt is BREAK
+type BreakStmt struct {
+	At Pos
+}
+
+// CallStmt is CALL or SYSTEM_CALL of a DEFINE_CALL by Name. Instance is
+// the optional SYSTEM_CALL instance
+type CallStmt struct {
+	At       Pos
+	System   bool
+	Instance Expr
+	Name     string
+	Args     []Expr
+}
+
+// CommandStmt is a keyword taking arguments without brackets, such as
+// SEND_STRING dvProj, 'PON' or ON[dvRelay, 1]. Name is upper case
+type CommandStmt struct {
+	At   Pos
+	Name string
+	Args []Expr
+}
+
+// Ident is a name
+type Ident struct {
+	At   Pos
+	Name string
+}
+
+// BasicLit is an Int, Float or String. Value is as written, without the
+// quotes of a String
+type BasicLit struct {
+	At    Pos
+	Kind  Kind
+	Value string
+}
+
+// StringExpr is a double quoted string expression such as "'PON', 13"
+type StringExpr struct {
+	At    Pos
+	Elems []Expr
+}
+
+// ArrayLit is an array in braces, such as {1, 2, 3}
+type ArrayLit struct {
+	At    Pos
+	Elems []Expr
+}
+
+// BracketExpr is a list in square brackets, such as [dvTP, 1]
+type BracketExpr struct {
+	At    Pos
+	Elems []Expr
+}
+
+// ParenExpr is an expression in brackets
+type ParenExpr struct {
+	At Pos
+	X  Expr
+}
+
+// TupleExpr is a list in brackets, such as (vdvCombined, dvTP1, dvTP2)
+type TupleExpr struct {
+	At    Pos
+	Elems []Expr
+}
+
+// DeviceExpr is a device address such as 10001:1:0
+type DeviceExpr struct {
+	At    Pos
+	Parts []Expr
+}
+
+// IndexExpr is X[Index]
+type IndexExpr struct {
+	At    Pos
+	X     Expr
+	Index Expr
+}
+
+// SelectorExpr is X.Sel
+type SelectorExpr struct {
+	At  Pos
+	X   Expr
+	Sel string
+}
+
+// CallExpr is a function call
+type CallExpr struct {
+	At   Pos
+	Fun  Expr
+	Args []Expr
+}
+
+// UnaryExpr is Op X. Word operators are given by their symbol, so NOT is !
+// and BNOT is ~
+type UnaryExpr struct {
+	At Pos
+	Op string
+	X  Expr
+}
+
+// BinaryExpr is X Op Y. Word operators are given by their symbol, such as
+// && for AND, and the comparisons = and <> are given as == and !=. A range
+// such as [dv, 1]..[dv, 8] has Op ..
+type BinaryExpr struct {
+	At Pos
+	X  Expr
+	Op string
+	Y  Expr
+}
+
+func (n *Header) Pos() Pos       { return n.At }
+func (n *Directive) Pos() Pos    { return n.At }
+func (n *Section) Pos() Pos      { return n.At }
+func (n *VarDecl) Pos() Pos      { return n.At }
+func (n *TypeDecl) Pos() Pos     { return n.At }
+func (n *FuncDecl) Pos() Pos     { return n.At }
+func (n *EventDecl) Pos() Pos    { return n.At }
+func (n *EventHead) Pos() Pos    { return n.At }
+func (n *Handler) Pos() Pos      { return n.At }
+func (n *ModuleInst) Pos() Pos   { return n.At }
+func (n *Block) Pos() Pos        { return n.At }
+func (n *DeclStmt) Pos() Pos     { return n.At }
+func (n *AssignStmt) Pos() Pos   { return n.At }
+func (n *IncDecStmt) Pos() Pos   { return n.At }
+func (n *ExprStmt) Pos() Pos     { return n.At }
+func (n *IfStmt) Pos() Pos       { return n.At }
+func (n *WhileStmt) Pos() Pos    { return n.At }
+func (n *ForStmt) Pos() Pos      { return n.At }
+func (n *SwitchStmt) Pos() Pos   { return n.At }
+func (n *SelectStmt) Pos() Pos   { return n.At }
+func (n *CaseClause) Pos() Pos   { return n.At }
+func (n *WaitStmt) Pos() Pos     { return n.At }
+func (n *ReturnStmt) Pos() Pos   { return n.At }
+func (n *BreakStmt) Pos() Pos    { return n.At }
+func (n *CallStmt) Pos() Pos     { return n.At }
+func (n *CommandStmt) Pos() Pos  { return n.At }
+func (n *Ident) Pos() Pos        { return n.At }
+func (n *BasicLit) Pos() Pos     { return n.At }
+func (n *StringExpr) Pos() Pos   { return n.At }
+func (n *ArrayLit) Pos() Pos     { return n.At }
+func (n *BracketExpr) Pos() Pos  { return n.At }
+func (n *ParenExpr) Pos() Pos    { return n.At }
+func (n *TupleExpr) Pos() Pos    { return n.At }
+func (n *DeviceExpr) Pos() Pos   { return n.At }
+func (n *IndexExpr) Pos() Pos    { return n.At }
+func (n *SelectorExpr) Pos() Pos { return n.At }
+func (n *CallExpr) Pos() Pos     { return n.At }
+func (n *UnaryExpr) Pos() Pos    { return n.At }
+func (n *BinaryExpr) Pos() Pos   { return n.At }
+
+func (*Directive) declNode() {}
+func (*Section) declNode()   {}
+func (*FuncDecl) declNode()  {}
+
+func (*Directive) stmtNode()   {}
+func (*Block) stmtNode()       {}
+func (*DeclStmt) stmtNode()    {}
+func (*AssignStmt) stmtNode()  {}
+func (*IncDecStmt) stmtNode()  {}
+func (*ExprStmt) stmtNode()    {}
+func (*IfStmt) stmtNode()      {}
+func (*WhileStmt) stmtNode()   {}
+func (*ForStmt) stmtNode()     {}
+func (*SwitchStmt) stmtNode()  {}
+func (*SelectStmt) stmtNode()  {}
+func (*WaitStmt) stmtNode()    {}
+func (*ReturnStmt) stmtNode()  {}
+func (*BreakStmt) stmtNode()   {}
+func (*CallStmt) stmtNode()    {}
+func (*CommandStmt) stmtNode() {}
+
+func (*Ident) exprNode()        {}
+func (*BasicLit) exprNode()     {}
+func (*StringExpr) exprNode()   {}
+func (*ArrayLit) exprNode()     {}
+func (*BracketExpr) exprNode()  {}
+func (*ParenExpr) exprNode()    {}
+func (*TupleExpr) exprNode()    {}
+func (*DeviceExpr) exprNode()   {}
+func (*IndexExpr) exprNode()    {}
+func (*SelectorExpr) exprNode() {}
+func (*CallExpr) exprNode()     {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
@@ -0,0 +1,189 @@
+package syntax
+
+// Binary operator precedence, with higher binding tighter. Ranges bind
+// loosest as they only join channels
+var precedence = map[string]int{
+	"..": 1,
+	"||": 2,
+	"^^": 3,
+	"&&": 4,
+	"|":  5,
+	"^":  6,
+	"&":  7,
+	"==": 8, "!=": 8, "<": 8, "<=": 8, ">": 8, ">=": 8,
+	"+": 9, "-": 9,
+	"<<": 10, ">>": 10,
+	"*": 11, "/": 11, "%": 11,
+}
+
+// expr parses a full expression. A lone = is a comparison here, as
+// assignment is only a statement
+func (p *parser) expr() Expr {
+	return p.binary(1)
+}
+
+// binary parses operators binding at least as tightly as prec
+func (p *parser) binary(prec int) Expr {
+	x := p.unary()
+	for {
+		op, oprec := p.binaryOp()
+		if oprec < prec {
+			return x
+		}
+		p.next()
+		x = &BinaryExpr{At: x.Pos(), X: x, Op: op, Y: p.binary(oprec + 1)}
+	}
+}
+
+// binaryOp returns the current token as a binary operator symbol and its
+// precedence, or 0 if it isn't one
+func (p *parser) binaryOp() (string, int) {
+	op := ""
+	switch p.tok.Kind {
+	case KindOp:
+		op = p.tok.Text
+	case KindIdent:
+		op = keywordOps[p.tok.upper()]
+	}
+	switch op {
+	case "=":
+		op = "=="
+	case "<>":
+		op = "!="
+	}
+	return op, precedence[op]
+}
+
+// unary parses a prefix operator or a device address
+func (p *parser) unary() Expr {
+
+	at := p.tok.Pos
+	op := ""
+	switch {
+	case p.tok.Kind == KindOp && (p.tok.Text == "-" || p.tok.Text == "+" || p.tok.Text == "!" || p.tok.Text == "~"):
+		op = p.tok.Text
+	case p.tok.upper() == "NOT", p.tok.upper() == "BNOT":
+		op = keywordOps[p.tok.upper()]
+	}
+	if op != "" {
+		p.next()
+		return &UnaryExpr{At: at, Op: op, X: p.unary()}
+	}
+
+	x := p.postfix()
+	if p.noColon || !p.is(":") {
+		return x
+	}
+	d := &DeviceExpr{At: at, Parts: []Expr{x}}
+	for p.got(":") {
+		d.Parts = append(d.Parts, p.postfix())
+	}
+	return d
+}
+
+// postfix parses indexes, fields and calls following an operand on the
+// same line
+func (p *parser) postfix() Expr {
+
+	x := p.primary()
+	for p.sameLine() {
+		switch {
+		case p.is("[") && indexable(x):
+			p.next()
+			i := &IndexExpr{At: x.Pos(), X: x, Index: p.nested(p.expr)}
+			p.expect("]")
+			x = i
+		case p.is("."):
+			p.next()
+			x = &SelectorExpr{At: x.Pos(), X: x, Sel: p.ident()}
+		case p.is("("):
+			if _, ok := x.(*Ident); !ok {
+				return x
+			}
+			p.next()
+			c := &CallExpr{At: x.Pos(), Fun: x, Args: p.exprList(")")}
+			p.expect(")")
+			x = c
+		default:
+			return x
+		}
+	}
+	return x
+}
+
+// indexable returns true for expressions which can be followed by [index]
+func indexable(x Expr) bool {
+	switch x.(type) {
+	case *Ident, *IndexExpr, *SelectorExpr:
+		return true
+	}
+	return false
+}
+
+// primary parses an operand
+func (p *parser) primary() Expr {
+
+	t := p.tok
+	switch {
+	case t.Kind == KindIdent:
+		p.next()
+		return &Ident{At: t.Pos, Name: t.Text}
+
+	case t.Kind == KindInt, t.Kind == KindFloat, t.Kind == KindString:
+		p.next()
+		return &BasicLit{At: t.Pos, Kind: t.Kind, Value: t.Text}
+
+	case p.is(`"`):
+		p.next()
+		x := &StringExpr{At: t.Pos, Elems: p.exprList(`"`)}
+		p.expect(`"`)
+		return x
+
+	case p.is("{"):
+		p.next()
+		x := &ArrayLit{At: t.Pos, Elems: p.exprList("}")}
+		p.expect("}")
+		return x
+
+	case p.is("["):
+		p.next()
+		x := &BracketExpr{At: t.Pos, Elems: p.exprList("]")}
+		p.expect("]")
+		return x
+
+	case p.is("("):
+		p.next()
+		elems := p.exprList(")")
+		p.expect(")")
+		if len(elems) == 1 {
+			return &ParenExpr{At: t.Pos, X: elems[0]}
+		}
+		return &TupleExpr{At: t.Pos, Elems: elems}
+	}
+
+	p.fail("expected expression, found %s", t)
+	return nil
+}
+
+// exprList parses expressions separated by commas up to close, which isn't
+// consumed. A trailing comma is allowed
+func (p *parser) exprList(close string) []Expr {
+	var list []Expr
+	for !p.is(close) {
+		list = append(list, p.nested(p.expr))
+		if !p.got(",") {
+			break
+		}
+	}
+	return list
+}
+
+// nested parses within brackets, where a colon is always part of a device
+// address
+func (p *parser) nested(fn func() Expr) Expr {
+	saved := p.noColon
+	p.noColon = false
+	x := fn()
+	p.noColon = saved
+	return x
+}
@@ -0,0 +1,243 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a problem found at a position in a source file
+type Error struct {
+	Filename string
+	Pos      Pos
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	if e.Filename == "" {
+		return e.Pos.String() + ": " + e.Msg
+	}
+	return e.Filename + ":" + e.Pos.String() + ": " + e.Msg
+}
+
+// ErrorList collects every Error found in a file
+type ErrorList []*Error
+
+func (e ErrorList) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// errOrNil returns e as an error, or nil if empty
+func (e ErrorList) errOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Lexer breaks Netlinx source into Tokens. Whitespace is skipped, as are
+// all three comment styles ( // , /* */ and (* *) ) unless Comments is set.
+// Problems such as an unterminated string are added to Errors
+type Lexer struct {
+	Comments bool
+	Errors   ErrorList
+
+	filename string
+	src      []byte
+	off      int
+	line     int
+	col      int
+}
+
+// NewLexer returns a Lexer for src, using filename in errors
+func NewLexer(filename string, src []byte) *Lexer {
+	return &Lexer{filename: filename, src: src, line: 1, col: 1}
+}
+
+// pos returns the current position
+func (l *Lexer) pos() Pos {
+	return Pos{l.off, l.line, l.col}
+}
+
+// peek returns the byte i ahead, or 0 past the end
+func (l *Lexer) peek(i int) byte {
+	if l.off+i < len(l.src) {
+		return l.src[l.off+i]
+	}
+	return 0
+}
+
+// advance moves on n bytes, counting lines
+func (l *Lexer) advance(n int) {
+	for ; n > 0 && l.off < len(l.src); n-- {
+		if l.src[l.off] == '\n' {
+			l.line++
+			l.col = 0
+		}
+		l.off++
+		l.col++
+	}
+}
+
+func (l *Lexer) errorf(p Pos, format string, args ...interface{}) {
+	l.Errors = append(l.Errors, &Error{l.filename, p, fmt.Sprintf(format, args...)})
+}
+
+// Next returns the next Token, or one of Kind EOF at the end
+func (l *Lexer) Next() Token {
+
+	for {
+		// Whitespace
+		for l.off < len(l.src) && strings.IndexByte(" \t\r\n\f\v", l.src[l.off]) >= 0 {
+			l.advance(1)
+		}
+		p := l.pos()
+		if l.off >= len(l.src) {
+			return Token{KindEOF, "", p}
+		}
+
+		c := l.src[l.off]
+		switch {
+
+		// Single line comment
+		case c == '/' && l.peek(1) == '/':
+			for l.off < len(l.src) && l.src[l.off] != '\n' {
+				l.advance(1)
+			}
+			if l.Comments {
+				return Token{KindComment, string(l.src[p.Offset:l.off]), p}
+			}
+
+		// Block comments, both C and Pascal style
+		case (c == '/' || c == '(') && l.peek(1) == '*':
+			end := byte('/')
+			if c == '(' {
+				end = ')'
+			}
+			l.advance(2)
+			for l.off < len(l.src) && !(l.src[l.off] == '*' && l.peek(1) == end) {
+				l.advance(1)
+			}
+			if l.off >= len(l.src) {
+				l.errorf(p, "comment not terminated")
+			}
+			l.advance(2)
+			if l.Comments {
+				return Token{KindComment, string(l.src[p.Offset:l.off]), p}
+			}
+
+		// String literals, which can't span lines
+		case c == '\'':
+			l.advance(1)
+			start := l.off
+			for l.off < len(l.src) && l.src[l.off] != '\'' && l.src[l.off] != '\n' {
+				l.advance(1)
+			}
+			t := Token{KindString, string(l.src[start:l.off]), p}
+			if l.peek(0) == '\'' {
+				l.advance(1)
+			} else {
+				l.errorf(p, "string not terminated")
+			}
+			return t
+
+		// Preprocessor directives
+		case c == '#':
+			l.advance(1)
+			l.scanWhile(isIdentChar)
+			return Token{KindDirective, strings.ToUpper(string(l.src[p.Offset:l.off])), p}
+
+		case isIdentStart(c):
+			l.scanWhile(isIdentChar)
+			return Token{KindIdent, string(l.src[p.Offset:l.off]), p}
+
+		// Hex numbers
+		case c == '$' && isHex(l.peek(1)):
+			l.advance(1)
+			l.scanWhile(isHex)
+			return Token{KindInt, string(l.src[p.Offset:l.off]), p}
+
+		case isDigit(c):
+			return l.number(p)
+
+		default:
+			end := l.off + 2
+			if end > len(l.src) {
+				end = len(l.src)
+			}
+			for _, op := range operators {
+				if strings.HasPrefix(string(l.src[l.off:end]), op) {
+					l.advance(len(op))
+					return Token{KindOp, op, p}
+				}
+			}
+			l.advance(1)
+			l.errorf(p, "unexpected character %q", c)
+			return Token{KindIllegal, string(c), p}
+		}
+	}
+}
+
+// number scans an Int or Float. A . is only part of the number when a digit
+// follows, so ranges such as 1..8 lex as three tokens
+func (l *Lexer) number(p Pos) Token {
+	kind := KindInt
+	l.scanWhile(isDigit)
+	if l.peek(0) == '.' && isDigit(l.peek(1)) {
+		kind = KindFloat
+		l.advance(1)
+		l.scanWhile(isDigit)
+	}
+	if e := l.peek(0); e == 'e' || e == 'E' {
+		n := 1
+		if s := l.peek(1); s == '+' || s == '-' {
+			n = 2
+		}
+		if isDigit(l.peek(n)) {
+			kind = KindFloat
+			l.advance(n)
+			l.scanWhile(isDigit)
+		}
+	}
+	return Token{kind, string(l.src[p.Offset:l.off]), p}
+}
+
+// scanWhile advances over bytes matching fn
+func (l *Lexer) scanWhile(fn func(byte) bool) {
+	for l.off < len(l.src) && fn(l.src[l.off]) {
+		l.advance(1)
+	}
+}
+
+// Tokens returns every Token in src up to and including EOF, without
+// comments, and any errors found
+func Tokens(filename string, src []byte) ([]Token, error) {
+	l := NewLexer(filename, src)
+	var toks []Token
+	for {
+		t := l.Next()
+		toks = append(toks, t)
+		if t.Kind == KindEOF {
+			return toks, l.Errors.errOrNil()
+		}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
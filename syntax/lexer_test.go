@@ -0,0 +1,129 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+// tokenList formats tokens as Kind:Text@Line:Col, separated by spaces
+func tokenList(toks []Token) string {
+	var s []string
+	for _, t := range toks {
+		s = append(s, t.Kind.String()+":"+t.Text+"@"+t.Pos.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func TestTokens(t *testing.T) {
+
+	src := "#include 'a.axi' // note\n" +
+		"(* old *) x = $0D + 1.5e3\n" +
+		"[dv, 1]..[dv, 8] <> /* c */ y\n"
+
+	toks, err := Tokens("test.axs", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Directive:#INCLUDE@1:1 String:a.axi@1:10 " +
+		"Ident:x@2:11 Op:=@2:13 Int:$0D@2:15 Op:+@2:19 Float:1.5e3@2:21 " +
+		"Op:[@3:1 Ident:dv@3:2 Op:,@3:4 Int:1@3:6 Op:]@3:7 Op:..@3:8 Op:[@3:10 Ident:dv@3:11 Op:,@3:13 Int:8@3:15 Op:]@3:16 " +
+		"Op:<>@3:18 Ident:y@3:29 EOF:@4:1"
+	if got := tokenList(toks); got != want {
+		t.Errorf("tokens:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Comments are kept when asked for
+	l := NewLexer("test.axs", []byte(src))
+	l.Comments = true
+	var comments []string
+	for tok := l.Next(); tok.Kind != KindEOF; tok = l.Next() {
+		if tok.Kind == KindComment {
+			comments = append(comments, tok.Text+"@"+tok.Pos.String())
+		}
+	}
+	if got, want := strings.Join(comments, " "), "// note@1:18 (* old *)@2:1 /* c */@3:21"; got != want {
+		t.Errorf("comments = %q, want %q", got, want)
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		src    string
+		errs   string
+		tokens string
+	}{
+		{
+			name:   "unterminated string",
+			src:    "x = 'abc\ny",
+			errs:   "test.axs:1:5: string not terminated",
+			tokens: "Ident:x@1:1 Op:=@1:3 String:abc@1:5 Ident:y@2:1 EOF:@2:2",
+		},
+		{
+			name:   "unterminated comment",
+			src:    "x\n(* never\nclosed",
+			errs:   "test.axs:2:1: comment not terminated",
+			tokens: "Ident:x@1:1 EOF:@3:7",
+		},
+		{
+			name:   "unexpected characters",
+			src:    "a @ b\n  ?",
+			errs:   "test.axs:1:3: unexpected character '@'\ntest.axs:2:3: unexpected character '?'",
+			tokens: "Ident:a@1:1 Illegal:@@1:3 Ident:b@1:5 Illegal:?@2:3 EOF:@2:4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toks, err := Tokens("test.axs", []byte(tt.src))
+			if _, ok := err.(ErrorList); !ok || err.Error() != tt.errs {
+				t.Errorf("err = %v, want %q", err, tt.errs)
+			}
+			if got := tokenList(toks); got != tt.tokens {
+				t.Errorf("tokens:\n%s\nwant:\n%s", got, tt.tokens)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	src := "PROGRAM_NAME='Main'\n" +
+		"DEFINE_VARIABLE\n" +
+		"INTEGER = 1\n" +
+		"CHAR sName[] = 'open\n" +
+		"INTEGER nOk\n" +
+		"DEFINE_START\n" +
+		"nOk = 1 @\n"
+
+	f, err := ParseFile("test.axs", []byte(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("err = %v, want an ErrorList", err)
+	}
+
+	// Lexer and parser errors in source order
+	var got []string
+	for _, e := range list {
+		got = append(got, e.Pos.String())
+	}
+	if want := "3:9 4:16 7:9"; strings.Join(got, " ") != want {
+		t.Errorf("errors at %s, want %s\n%v", strings.Join(got, " "), want, err)
+	}
+
+	// Declarations after each error are still parsed
+	if f.Header == nil || len(f.Decls) != 2 {
+		t.Fatalf("parsed %d decls, want 2", len(f.Decls))
+	}
+	var names []string
+	for _, n := range f.Decls[0].(*Section).Items {
+		names = append(names, n.(*VarDecl).Name)
+	}
+	if want := "sName nOk"; strings.Join(names, " ") != want {
+		t.Errorf("variables = %q, want %s", names, want)
+	}
+	if items := f.Decls[1].(*Section).Items; len(items) != 1 {
+		t.Errorf("DEFINE_START has %d items, want 1", len(items))
+	}
+}
@@ -0,0 +1,545 @@
+package syntax
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// maxErrors stops parsing a file which clearly isn't Netlinx
+const maxErrors = 10
+
+// bailout abandons the current declaration after an error
+type bailout struct{}
+
+// tooMany abandons the file after maxErrors
+type tooMany struct{}
+
+type parser struct {
+	filename string
+	toks     []Token
+	i        int
+	tok      Token
+	prev     Token
+	errs     ErrorList
+	noColon  bool
+}
+
+func newParser(filename string, toks []Token) *parser {
+	p := &parser{filename: filename, toks: toks}
+	p.tok = toks[0]
+	return p
+}
+
+// ParseFile parses Netlinx source. A File is returned even if errors are
+// found, holding everything which could be parsed, and the error is an
+// ErrorList
+func ParseFile(filename string, src []byte) (f *File, err error) {
+
+	l := NewLexer(filename, src)
+	var toks []Token
+	for {
+		t := l.Next()
+		// The Lexer has already reported these
+		if t.Kind != KindIllegal {
+			toks = append(toks, t)
+		}
+		if t.Kind == KindEOF {
+			break
+		}
+	}
+
+	p := newParser(filename, toks)
+	p.errs = l.Errors
+	f = &File{Name: filename}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(tooMany); !ok {
+				panic(r)
+			}
+		}
+		// Lexer and parser errors are found separately
+		sort.SliceStable(p.errs, func(i, j int) bool { return p.errs[i].Pos.Offset < p.errs[j].Pos.Offset })
+		err = p.errs.errOrNil()
+	}()
+
+	for p.tok.Kind != KindEOF {
+		p.topDecl(f)
+	}
+	return f, nil
+}
+
+// LoadFile reads and parses a source file
+func LoadFile(fn string) (*File, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(fn, b)
+}
+
+// ParseExpr parses a single expression
+func ParseExpr(src string) (Expr, error) {
+	toks, err := Tokens("", []byte(src))
+	if err != nil {
+		return nil, err
+	}
+	p := newParser("", toks)
+	x := p.lineExpr(toks)
+	return x, p.errs.errOrNil()
+}
+
+// next moves to the following token
+func (p *parser) next() {
+	p.prev = p.tok
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	p.tok = p.toks[p.i]
+}
+
+// peek returns the token after the current one
+func (p *parser) peek() Token {
+	if p.i < len(p.toks)-1 {
+		return p.toks[p.i+1]
+	}
+	return p.tok
+}
+
+// is returns true if the current token is the operator op
+func (p *parser) is(op string) bool {
+	return p.tok.Kind == KindOp && p.tok.Text == op
+}
+
+// got moves past the operator op if it is the current token
+func (p *parser) got(op string) bool {
+	if p.is(op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// sameLine returns true if the current token is on the same line as the
+// previous one, as statements needn't end with a semicolon
+func (p *parser) sameLine() bool {
+	return p.tok.Kind != KindEOF && p.tok.Pos.Line == p.prev.Pos.Line
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) {
+	p.errs = append(p.errs, &Error{p.filename, pos, fmt.Sprintf(format, args...)})
+	if len(p.errs) >= maxErrors {
+		panic(tooMany{})
+	}
+}
+
+// fail reports an error at the current token and abandons the declaration
+func (p *parser) fail(format string, args ...interface{}) {
+	p.errorf(p.tok.Pos, format, args...)
+	panic(bailout{})
+}
+
+// expect moves past the operator op, failing if it isn't there
+func (p *parser) expect(op string) Pos {
+	if !p.is(op) {
+		p.fail("expected %s, found %s", op, p.tok)
+	}
+	pos := p.tok.Pos
+	p.next()
+	return pos
+}
+
+// ident returns the name of the current Ident and moves past it
+func (p *parser) ident() string {
+	if p.tok.Kind != KindIdent {
+		p.fail("expected name, found %s", p.tok)
+	}
+	s := p.tok.Text
+	p.next()
+	return s
+}
+
+// stringLit returns the text of the current String and moves past it
+func (p *parser) stringLit() string {
+	if p.tok.Kind != KindString {
+		p.fail("expected string, found %s", p.tok)
+	}
+	s := p.tok.Text
+	p.next()
+	return s
+}
+
+// recovered stops a bailout, skipping to where parsing can continue with
+// sync. Any other panic carries on
+func (p *parser) recovered(r interface{}, sync func()) {
+	if r == nil {
+		return
+	}
+	if _, ok := r.(bailout); !ok {
+		panic(r)
+	}
+	p.noColon = false
+	sync()
+}
+
+// syncTop skips to the next keyword which starts a declaration
+func (p *parser) syncTop() {
+	for p.tok.Kind != KindEOF && !isTopLevel(p.tok.upper()) {
+		p.next()
+	}
+}
+
+// syncItem skips to the first token on a later line outside any braces
+// opened since the item started at start, or to the next declaration
+func (p *parser) syncItem(start int) {
+	depth := 0
+	for _, t := range p.toks[start:p.i] {
+		if t.Kind == KindOp && t.Text == "{" {
+			depth++
+		} else if t.Kind == KindOp && t.Text == "}" {
+			depth--
+		}
+	}
+	line := p.tok.Pos.Line
+	for p.tok.Kind != KindEOF && !isTopLevel(p.tok.upper()) {
+		if depth <= 0 && p.tok.Pos.Line > line && p.i > start {
+			return
+		}
+		if p.is("{") {
+			depth++
+		} else if p.is("}") {
+			depth--
+			line = p.tok.Pos.Line
+		}
+		p.next()
+	}
+}
+
+// topDecl parses a declaration at the top level of a file
+func (p *parser) topDecl(f *File) {
+
+	defer func() { p.recovered(recover(), p.syncTop) }()
+
+	word := p.tok.upper()
+	if p.tok.Kind == KindDirective {
+		f.Decls = append(f.Decls, p.directive())
+		return
+	}
+	if word == "PROGRAM_NAME" || word == "MODULE_NAME" {
+		if f.Header != nil {
+			p.errorf(p.tok.Pos, "%s given twice", word)
+		}
+		f.Header = p.header()
+		return
+	}
+	if _, ok := funcKind(word); ok {
+		f.Decls = append(f.Decls, p.funcDecl())
+		return
+	}
+	if kind, ok := sectionKind(word); ok {
+		s := &Section{At: p.tok.Pos, Kind: kind}
+		f.Decls = append(f.Decls, s)
+		p.next()
+		for p.tok.Kind != KindEOF && !isTopLevel(p.tok.upper()) {
+			s.Items = append(s.Items, p.sectionItem(kind)...)
+		}
+		return
+	}
+	p.fail("expected a DEFINE_ section, found %s", p.tok)
+}
+
+// header parses PROGRAM_NAME or MODULE_NAME
+func (p *parser) header() *Header {
+	h := &Header{At: p.tok.Pos, Module: p.tok.upper() == "MODULE_NAME"}
+	p.next()
+	p.expect("=")
+	h.Name = p.stringLit()
+	if h.Module && p.is("(") {
+		h.Params = p.params()
+	}
+	return h
+}
+
+// directive parses a preprocessor line
+func (p *parser) directive() *Directive {
+
+	d := &Directive{At: p.tok.Pos, Name: p.tok.Text}
+	p.next()
+
+	var toks []Token
+	for p.tok.Kind != KindEOF && p.tok.Pos.Line == d.At.Line {
+		toks = append(toks, p.tok)
+		p.next()
+	}
+
+	switch d.Name {
+	case "#DEFINE", "#IF_DEFINED", "#IF_NOT_DEFINED":
+		if len(toks) > 0 && toks[0].Kind == KindIdent {
+			d.Ident = toks[0].Text
+			toks = toks[1:]
+		}
+	}
+	if len(toks) > 0 {
+		d.Value = p.lineExpr(toks)
+	}
+	return d
+}
+
+// lineExpr parses toks as a single expression, with its own errors
+func (p *parser) lineExpr(toks []Token) (x Expr) {
+
+	last := toks[len(toks)-1]
+	if last.Kind != KindEOF {
+		end := last.Pos
+		end.Col += len(last.Text)
+		end.Offset += len(last.Text)
+		toks = append(toks[:len(toks):len(toks)], Token{KindEOF, "", end})
+	}
+	sub := newParser(p.filename, toks)
+
+	defer func() {
+		p.recovered(recover(), func() {})
+		for _, e := range sub.errs {
+			p.errorf(e.Pos, "%s", e.Msg)
+		}
+	}()
+
+	x = sub.expr()
+	if sub.tok.Kind != KindEOF {
+		sub.errorf(sub.tok.Pos, "unexpected %s", sub.tok)
+	}
+	return x
+}
+
+// sectionItem parses the next item of a section. Several are returned for
+// declarations such as INTEGER a, b
+func (p *parser) sectionItem(kind SectionKind) (items []Node) {
+
+	start := p.i
+	defer func() { p.recovered(recover(), func() { p.syncItem(start) }) }()
+
+	if p.tok.Kind == KindDirective {
+		return []Node{p.directive()}
+	}
+	if p.got(";") {
+		return nil
+	}
+
+	switch kind {
+	case SectionDevice, SectionConstant, SectionVariable:
+		for _, v := range p.varSpec(true) {
+			items = append(items, v)
+		}
+		p.got(";")
+		return items
+	case SectionType:
+		return []Node{p.typeDecl()}
+	case SectionEvent:
+		return []Node{p.eventDecl()}
+	case SectionModule:
+		return []Node{p.moduleInst()}
+	case SectionStart, SectionProgram:
+		if s := p.stmt(); s != nil {
+			return []Node{s}
+		}
+		return nil
+	}
+
+	// Latching, toggling, combines and the like are lists of channels
+	at := p.tok.Pos
+	s := &ExprStmt{At: at, X: p.expr()}
+	p.got(";")
+	return []Node{s}
+}
+
+// varSpec parses a declaration with optional qualifiers and type. With
+// multi set it may declare several names, each with a value, as in a
+// section, otherwise it is a single parameter
+func (p *parser) varSpec(multi bool) []*VarDecl {
+
+	at := p.tok.Pos
+	var quals []string
+	for qualifiers[p.tok.upper()] {
+		quals = append(quals, p.tok.upper())
+		p.next()
+	}
+
+	typ := ""
+	if p.tok.Kind == KindIdent {
+		next := p.peek()
+		if w := p.tok.upper(); types[w] {
+			typ = w
+			p.next()
+		} else if next.Kind == KindIdent && next.Pos.Line == p.tok.Pos.Line {
+			typ = p.tok.Text
+			p.next()
+		}
+	}
+
+	var vs []*VarDecl
+	for {
+		v := &VarDecl{At: at, Qualifiers: quals, Type: typ}
+		v.Name = p.ident()
+		v.Dims = p.dims()
+		if multi && p.got("=") {
+			v.Value = p.expr()
+		}
+		vs = append(vs, v)
+		if !multi || !p.got(",") {
+			return vs
+		}
+		at = p.tok.Pos
+	}
+}
+
+// dims parses any array dimensions
+func (p *parser) dims() []Expr {
+	var dims []Expr
+	for p.is("[") && p.sameLine() {
+		p.next()
+		var x Expr
+		if !p.is("]") {
+			x = p.expr()
+		}
+		p.expect("]")
+		dims = append(dims, x)
+	}
+	return dims
+}
+
+// params parses a bracketed parameter list
+func (p *parser) params() []*VarDecl {
+	var params []*VarDecl
+	p.expect("(")
+	for !p.is(")") {
+		params = append(params, p.varSpec(false)...)
+		if !p.got(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return params
+}
+
+// typeDecl parses a STRUCTURE
+func (p *parser) typeDecl() *TypeDecl {
+
+	if w := p.tok.upper(); w != "STRUCTURE" && w != "STRUCT" {
+		p.fail("expected STRUCTURE, found %s", p.tok)
+	}
+	t := &TypeDecl{At: p.tok.Pos}
+	p.next()
+	t.Name = p.ident()
+
+	p.expect("{")
+	for !p.is("}") && p.tok.Kind != KindEOF {
+		if p.got(";") {
+			continue
+		}
+		t.Fields = append(t.Fields, p.varSpec(true)...)
+	}
+	p.expect("}")
+	return t
+}
+
+// funcDecl parses a DEFINE_FUNCTION, DEFINE_CALL or DEFINE_LIBRARY_FUNCTION
+func (p *parser) funcDecl() *FuncDecl {
+
+	kind, _ := funcKind(p.tok.upper())
+	f := &FuncDecl{At: p.tok.Pos, Kind: kind}
+	p.next()
+
+	if kind == FuncCall {
+		f.Name = p.stringLit()
+		if p.is("(") {
+			f.Params = p.params()
+		}
+		f.Body = p.block()
+		return f
+	}
+
+	// A return type is followed by the name or its size
+	if next := p.peek(); p.tok.Kind == KindIdent && (next.Kind == KindIdent || (next.Kind == KindOp && next.Text == "[")) {
+		f.Type = p.tok.Text
+		if types[p.tok.upper()] {
+			f.Type = p.tok.upper()
+		}
+		p.next()
+		f.TypeDims = p.dims()
+	}
+	f.Name = p.ident()
+	f.Params = p.params()
+
+	if kind == FuncLibrary {
+		p.got(";")
+		return f
+	}
+	f.Body = p.block()
+	return f
+}
+
+// eventDecl parses the events sharing a handler and the handler itself
+func (p *parser) eventDecl() *EventDecl {
+
+	e := &EventDecl{At: p.tok.Pos}
+	for events[p.tok.upper()] {
+		h := &EventHead{At: p.tok.Pos, Kind: p.tok.upper()}
+		p.next()
+		p.expect("[")
+		h.Args = p.exprList("]")
+		p.expect("]")
+		e.Events = append(e.Events, h)
+	}
+	if len(e.Events) == 0 {
+		p.fail("expected an event, found %s", p.tok)
+	}
+
+	at := p.expect("{")
+	if !p.isHandler() {
+		e.Body = &Block{At: at, Stmts: p.stmtList()}
+		p.expect("}")
+		return e
+	}
+
+	for !p.is("}") && p.tok.Kind != KindEOF {
+		if p.got(";") {
+			continue
+		}
+		if !p.isHandler() {
+			p.fail("expected an event handler such as PUSH:, found %s", p.tok)
+		}
+		h := &Handler{At: p.tok.Pos, Name: p.tok.upper()}
+		p.next()
+		if p.got("[") {
+			h.Args = p.exprList("]")
+			p.expect("]")
+		}
+		p.expect(":")
+		if !p.isHandler() && !p.is("}") {
+			h.Body = p.stmt()
+		}
+		e.Handlers = append(e.Handlers, h)
+	}
+	p.expect("}")
+	return e
+}
+
+// isHandler returns true at the start of a handler such as PUSH:
+func (p *parser) isHandler() bool {
+	w := p.tok.upper()
+	next := p.peek()
+	return handlers[w] && next.Kind == KindOp && (next.Text == ":" || (w == "HOLD" && next.Text == "["))
+}
+
+// moduleInst parses a module in DEFINE_MODULE
+func (p *parser) moduleInst() *ModuleInst {
+	m := &ModuleInst{At: p.tok.Pos}
+	m.Module = p.stringLit()
+	m.Instance = p.ident()
+	p.expect("(")
+	m.Args = p.exprList(")")
+	p.expect(")")
+	p.got(";")
+	return m
+}
@@ -0,0 +1,437 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describe returns the type of n with the fields which aren't nodes
+func describe(n Node) string {
+	s := strings.TrimPrefix(fmt.Sprintf("%T", n), "*syntax.")
+	var parts []string
+	switch n := n.(type) {
+	case *Header:
+		if n.Module {
+			parts = append(parts, "MODULE_NAME")
+		}
+		parts = append(parts, n.Name)
+	case *Directive:
+		parts = append(parts, n.Name, n.Ident)
+	case *Section:
+		parts = append(parts, n.Kind.String())
+	case *VarDecl:
+		parts = append(parts, strings.Join(n.Qualifiers, " "), n.Type, n.Name)
+	case *TypeDecl:
+		parts = append(parts, n.Name)
+	case *FuncDecl:
+		parts = append(parts, n.Kind.String(), n.Type, n.Name)
+	case *EventHead:
+		parts = append(parts, n.Kind)
+	case *Handler:
+		parts = append(parts, n.Name)
+	case *ModuleInst:
+		parts = append(parts, n.Module, n.Instance)
+	case *IncDecStmt:
+		parts = append(parts, n.Op)
+	case *WhileStmt:
+		parts = append(parts, n.Kind)
+	case *WaitStmt:
+		parts = append(parts, n.Kind, n.Name)
+	case *CallStmt:
+		if n.System {
+			parts = append(parts, "SYSTEM_CALL")
+		}
+		parts = append(parts, n.Name)
+	case *CommandStmt:
+		parts = append(parts, n.Name)
+	case *Ident:
+		parts = append(parts, n.Name)
+	case *BasicLit:
+		parts = append(parts, n.Kind.String(), n.Value)
+	case *SelectorExpr:
+		parts = append(parts, n.Sel)
+	case *UnaryExpr:
+		parts = append(parts, n.Op)
+	case *BinaryExpr:
+		parts = append(parts, n.Op)
+	}
+	for _, p := range parts {
+		if p != "" {
+			s += " " + p
+		}
+	}
+	return s
+}
+
+// dump lists n and every node within it, indented by depth, with positions
+func dump(n Node) string {
+	var b strings.Builder
+	var walk func(n Node, depth int)
+	walk = func(n Node, depth int) {
+		fmt.Fprintf(&b, "%s%s %s\n", strings.Repeat("  ", depth), n.Pos(), describe(n))
+		Inspect(n, func(c Node) bool {
+			if c == n {
+				return true
+			}
+			walk(c, depth+1)
+			return false
+		})
+	}
+	walk(n, 0)
+	return b.String()
+}
+
+// parseTests hold source for each kind of section and declaration, with
+// the tree dump gives for each declaration in the File
+var parseTests = []struct {
+	name string
+	src  string
+	want string
+}{
+	{
+		name: "header and directives",
+		src:  "PROGRAM_NAME='Main'\n#IF_NOT_DEFINED __MAIN__\n#DEFINE __MAIN__\n#INCLUDE 'Shared.axi'\n#END_IF\n",
+		want: `
+1:1 Header Main
+2:1 Directive #IF_NOT_DEFINED __MAIN__
+3:1 Directive #DEFINE __MAIN__
+4:1 Directive #INCLUDE
+  4:10 BasicLit String Shared.axi
+5:1 Directive #END_IF
+`,
+	},
+	{
+		name: "module header",
+		src:  "MODULE_NAME='Projector_Comm'(DEV vdvProj, DEV dvProj, INTEGER nInputs[])\n",
+		want: `
+1:1 Header MODULE_NAME Projector_Comm
+  1:30 VarDecl DEV vdvProj
+  1:43 VarDecl DEV dvProj
+  1:55 VarDecl INTEGER nInputs
+`,
+	},
+	{
+		name: "device",
+		src:  "DEFINE_DEVICE\ndvTP = 10001:1:0\ndvProj = 5001:1:0 // Projector\n",
+		want: `
+1:1 Section DEFINE_DEVICE
+  2:1 VarDecl dvTP
+    2:8 DeviceExpr
+      2:8 BasicLit Int 10001
+      2:14 BasicLit Int 1
+      2:16 BasicLit Int 0
+  3:1 VarDecl dvProj
+    3:10 DeviceExpr
+      3:10 BasicLit Int 5001
+      3:15 BasicLit Int 1
+      3:17 BasicLit Int 0
+`,
+	},
+	{
+		name: "constant",
+		src:  "DEFINE_CONSTANT\nINTEGER MAX_INPUTS = 8\nCHAR POWER_ON[] = 'PON'\nTL_POLL = 1, TL_FEEDBACK = 2;\n",
+		want: `
+1:1 Section DEFINE_CONSTANT
+  2:1 VarDecl INTEGER MAX_INPUTS
+    2:22 BasicLit Int 8
+  3:1 VarDecl CHAR POWER_ON
+    3:19 BasicLit String PON
+  4:1 VarDecl TL_POLL
+    4:11 BasicLit Int 1
+  4:14 VarDecl TL_FEEDBACK
+    4:28 BasicLit Int 2
+`,
+	},
+	{
+		name: "type",
+		src:  "DEFINE_TYPE\nSTRUCTURE _Input\n{\n  CHAR name[32]\n  INTEGER id\n}\n",
+		want: `
+1:1 Section DEFINE_TYPE
+  2:1 TypeDecl _Input
+    4:3 VarDecl CHAR name
+      4:13 BasicLit Int 32
+    5:3 VarDecl INTEGER id
+`,
+	},
+	{
+		name: "variable",
+		src:  "DEFINE_VARIABLE\nVOLATILE INTEGER nInput\nPERSISTENT CHAR sNames[8][32]\n_Input uInputs[MAX_INPUTS]\nLONG lTimes[] = {1000, 2000}\n",
+		want: `
+1:1 Section DEFINE_VARIABLE
+  2:1 VarDecl VOLATILE INTEGER nInput
+  3:1 VarDecl PERSISTENT CHAR sNames
+    3:24 BasicLit Int 8
+    3:27 BasicLit Int 32
+  4:1 VarDecl _Input uInputs
+    4:16 Ident MAX_INPUTS
+  5:1 VarDecl LONG lTimes
+    5:17 ArrayLit
+      5:18 BasicLit Int 1000
+      5:24 BasicLit Int 2000
+`,
+	},
+	{
+		name: "latching",
+		src:  "DEFINE_LATCHING\n[dvTP, 1]..[dvTP, 4]\n",
+		want: `
+1:1 Section DEFINE_LATCHING
+  2:1 ExprStmt
+    2:1 BinaryExpr ..
+      2:1 BracketExpr
+        2:2 Ident dvTP
+        2:8 BasicLit Int 1
+      2:12 BracketExpr
+        2:13 Ident dvTP
+        2:19 BasicLit Int 4
+`,
+	},
+	{
+		name: "mutually exclusive",
+		src:  "DEFINE_MUTUALLY_EXCLUSIVE\n([dvTP, 11]..[dvTP, 14])\n",
+		want: `
+1:1 Section DEFINE_MUTUALLY_EXCLUSIVE
+  2:1 ExprStmt
+    2:1 ParenExpr
+      2:2 BinaryExpr ..
+        2:2 BracketExpr
+          2:3 Ident dvTP
+          2:9 BasicLit Int 11
+        2:14 BracketExpr
+          2:15 Ident dvTP
+          2:21 BasicLit Int 14
+`,
+	},
+	{
+		name: "toggling",
+		src:  "DEFINE_TOGGLING\n[dvTP, 21]\n",
+		want: `
+1:1 Section DEFINE_TOGGLING
+  2:1 ExprStmt
+    2:1 BracketExpr
+      2:2 Ident dvTP
+      2:8 BasicLit Int 21
+`,
+	},
+	{
+		name: "combine",
+		src:  "DEFINE_COMBINE\n(vdvTP, dvTP1, dvTP2)\n",
+		want: `
+1:1 Section DEFINE_COMBINE
+  2:1 ExprStmt
+    2:1 TupleExpr
+      2:2 Ident vdvTP
+      2:9 Ident dvTP1
+      2:16 Ident dvTP2
+`,
+	},
+	{
+		name: "connect level",
+		src:  "DEFINE_CONNECT_LEVEL\n(dvTP, 1, dvVol, 1)\n",
+		want: `
+1:1 Section DEFINE_CONNECT_LEVEL
+  2:1 ExprStmt
+    2:1 TupleExpr
+      2:2 Ident dvTP
+      2:8 BasicLit Int 1
+      2:11 Ident dvVol
+      2:18 BasicLit Int 1
+`,
+	},
+	{
+		name: "start",
+		src:  "DEFINE_START\nCREATE_BUFFER dvProj, sBuffer\nnInput = 1\nTIMELINE_CREATE(TL_POLL, lTimes, 2, TIMELINE_RELATIVE, TIMELINE_REPEAT)\n",
+		want: `
+1:1 Section DEFINE_START
+  2:1 CommandStmt CREATE_BUFFER
+    2:15 Ident dvProj
+    2:23 Ident sBuffer
+  3:1 AssignStmt
+    3:1 Ident nInput
+    3:10 BasicLit Int 1
+  4:1 ExprStmt
+    4:1 CallExpr
+      4:1 Ident TIMELINE_CREATE
+      4:17 Ident TL_POLL
+      4:26 Ident lTimes
+      4:34 BasicLit Int 2
+      4:37 Ident TIMELINE_RELATIVE
+      4:56 Ident TIMELINE_REPEAT
+`,
+	},
+	{
+		name: "event",
+		src:  "DEFINE_EVENT\nBUTTON_EVENT[dvTP, 1]\nBUTTON_EVENT[dvTP, 2]\n{\n  PUSH:\n  {\n    ON[dvRelay, BUTTON.INPUT.CHANNEL]\n  }\n  HOLD[20, REPEAT]: nInput++\n}\nDATA_EVENT[dvProj]\n{\n  STRING:\n  {\n    IF (FIND_STRING(DATA.TEXT, 'PON', 1)) { nPower = 1 }\n  }\n}\nLEVEL_EVENT[dvTP, 1]\n{\n  nVol = LEVEL.VALUE\n}\n",
+		want: `
+1:1 Section DEFINE_EVENT
+  2:1 EventDecl
+    2:1 EventHead BUTTON_EVENT
+      2:14 Ident dvTP
+      2:20 BasicLit Int 1
+    3:1 EventHead BUTTON_EVENT
+      3:14 Ident dvTP
+      3:20 BasicLit Int 2
+    5:3 Handler PUSH
+      6:3 Block
+        7:5 CommandStmt ON
+          7:7 BracketExpr
+            7:8 Ident dvRelay
+            7:17 SelectorExpr CHANNEL
+              7:17 SelectorExpr INPUT
+                7:17 Ident BUTTON
+    9:3 Handler HOLD
+      9:8 BasicLit Int 20
+      9:12 Ident REPEAT
+      9:21 IncDecStmt ++
+        9:21 Ident nInput
+  11:1 EventDecl
+    11:1 EventHead DATA_EVENT
+      11:12 Ident dvProj
+    13:3 Handler STRING
+      14:3 Block
+        15:5 IfStmt
+          15:8 ParenExpr
+            15:9 CallExpr
+              15:9 Ident FIND_STRING
+              15:21 SelectorExpr TEXT
+                15:21 Ident DATA
+              15:32 BasicLit String PON
+              15:39 BasicLit Int 1
+          15:43 Block
+            15:45 AssignStmt
+              15:45 Ident nPower
+              15:54 BasicLit Int 1
+  18:1 EventDecl
+    18:1 EventHead LEVEL_EVENT
+      18:13 Ident dvTP
+      18:19 BasicLit Int 1
+    19:1 Block
+      20:3 AssignStmt
+        20:3 Ident nVol
+        20:10 SelectorExpr VALUE
+          20:10 Ident LEVEL
+`,
+	},
+	{
+		name: "program",
+		src:  "DEFINE_PROGRAM\nWAIT 10 'poll'\n{\n  SEND_STRING dvProj, \"'PWR?', $0D\"\n}\n[dvTP, 1] = (nPower AND NOT nMute)\n",
+		want: `
+1:1 Section DEFINE_PROGRAM
+  2:1 WaitStmt WAIT poll
+    2:6 BasicLit Int 10
+    3:1 Block
+      4:3 CommandStmt SEND_STRING
+        4:15 Ident dvProj
+        4:23 StringExpr
+          4:24 BasicLit String PWR?
+          4:32 BasicLit Int $0D
+  6:1 AssignStmt
+    6:1 BracketExpr
+      6:2 Ident dvTP
+      6:8 BasicLit Int 1
+    6:13 ParenExpr
+      6:14 BinaryExpr &&
+        6:14 Ident nPower
+        6:25 UnaryExpr !
+          6:29 Ident nMute
+`,
+	},
+	{
+		name: "module",
+		src:  "DEFINE_MODULE\n'Projector_Comm' mdlProj(vdvProj, dvProj)\n",
+		want: `
+1:1 Section DEFINE_MODULE
+  2:1 ModuleInst Projector_Comm mdlProj
+    2:26 Ident vdvProj
+    2:35 Ident dvProj
+`,
+	},
+	{
+		name: "functions",
+		src:  "DEFINE_FUNCTION CHAR[16] fnName(INTEGER nId)\n{\n  STACK_VAR INTEGER i\n  FOR (i = 1; i <= 8; i++)\n  {\n    SWITCH (i)\n    {\n      CASE 1: BREAK\n      DEFAULT: {}\n    }\n  }\n  RETURN uInputs[nId].name\n}\nDEFINE_CALL 'Reset' (INTEGER n)\n{\n  SELECT\n  {\n    ACTIVE (n > 0): CALL 'Clear'\n  }\n  WHILE (n) n--\n}\nDEFINE_LIBRARY_FUNCTION LONG GET_TIMER()\n",
+		want: `
+1:1 FuncDecl DEFINE_FUNCTION CHAR fnName
+  1:22 BasicLit Int 16
+  1:33 VarDecl INTEGER nId
+  2:1 Block
+    3:3 DeclStmt
+      3:3 VarDecl STACK_VAR INTEGER i
+    4:3 ForStmt
+      4:8 AssignStmt
+        4:8 Ident i
+        4:12 BasicLit Int 1
+      4:15 BinaryExpr <=
+        4:15 Ident i
+        4:20 BasicLit Int 8
+      4:23 IncDecStmt ++
+        4:23 Ident i
+      5:3 Block
+        6:5 SwitchStmt
+          6:12 ParenExpr
+            6:13 Ident i
+          8:7 CaseClause
+            8:12 BasicLit Int 1
+            8:15 BreakStmt
+          9:7 CaseClause
+            9:16 Block
+    12:3 ReturnStmt
+      12:10 SelectorExpr name
+        12:10 IndexExpr
+          12:10 Ident uInputs
+          12:18 Ident nId
+14:1 FuncDecl DEFINE_CALL Reset
+  14:22 VarDecl INTEGER n
+  15:1 Block
+    16:3 SelectStmt
+      18:5 CaseClause
+        18:12 ParenExpr
+          18:13 BinaryExpr >
+            18:13 Ident n
+            18:17 BasicLit Int 0
+        18:21 CallStmt Clear
+    20:3 WhileStmt WHILE
+      20:9 ParenExpr
+        20:10 Ident n
+      20:13 IncDecStmt --
+        20:13 Ident n
+22:1 FuncDecl DEFINE_LIBRARY_FUNCTION LONG GET_TIMER
+`,
+	},
+}
+
+func TestParseFile(t *testing.T) {
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			f, err := ParseFile("test.axs", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			if f.Header != nil {
+				got += dump(f.Header)
+			}
+			for _, d := range f.Decls {
+				got += dump(d)
+			}
+			if got != strings.TrimPrefix(tt.want, "\n") {
+				t.Errorf("tree:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			// Every position agrees with its offset
+			Inspect(f, func(n Node) bool {
+				if want := offsetPos(tt.src, n.Pos().Offset); n.Pos() != want {
+					t.Errorf("%s at %s, offset %d is %s", describe(n), n.Pos(), n.Pos().Offset, want)
+				}
+				return true
+			})
+		})
+	}
+}
+
+// offsetPos returns the Pos of offset in src
+func offsetPos(src string, offset int) Pos {
+	before := src[:offset]
+	return Pos{offset, strings.Count(before, "\n") + 1, offset - strings.LastIndex(before, "\n")}
+}
@@ -0,0 +1,236 @@
+package syntax
+
+// block parses statements in braces
+func (p *parser) block() *Block {
+	b := &Block{At: p.expect("{")}
+	b.Stmts = p.stmtList()
+	p.expect("}")
+	return b
+}
+
+// stmtList parses statements up to a closing brace
+func (p *parser) stmtList() []Stmt {
+	var list []Stmt
+	for !p.is("}") && p.tok.Kind != KindEOF && !isTopLevel(p.tok.upper()) {
+		if s := p.stmt(); s != nil {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// stmt parses a statement, returning nil for an empty one
+func (p *parser) stmt() Stmt {
+
+	switch {
+	case p.got(";"):
+		return nil
+	case p.is("{"):
+		return p.block()
+	case p.tok.Kind == KindDirective:
+		return p.directive()
+	}
+
+	at := p.tok.Pos
+	word := p.tok.upper()
+	next := p.peek()
+
+	switch word {
+	case "IF":
+		p.next()
+		s := &IfStmt{At: at, Cond: p.expr(), Then: p.stmt()}
+		if p.tok.upper() == "ELSE" {
+			p.next()
+			s.Else = p.stmt()
+		}
+		return s
+
+	case "WHILE", "MEDIUM_WHILE", "LONG_WHILE":
+		p.next()
+		return &WhileStmt{At: at, Kind: word, Cond: p.expr(), Body: p.stmt()}
+
+	case "FOR":
+		return p.forStmt()
+
+	case "SWITCH":
+		p.next()
+		s := &SwitchStmt{At: at, Tag: p.expr()}
+		s.Cases = p.clauses("CASE", "DEFAULT")
+		return s
+
+	case "SELECT":
+		p.next()
+		return &SelectStmt{At: at, Cases: p.clauses("ACTIVE", "")}
+
+	case "WAIT", "WAIT_UNTIL", "TIMED_WAIT_UNTIL":
+		return p.waitStmt()
+
+	case "RETURN":
+		p.next()
+		s := &ReturnStmt{At: at}
+		if p.sameLine() && !p.is(";") && !p.is("}") {
+			s.Value = p.expr()
+		}
+		p.got(";")
+		return s
+
+	case "BREAK":
+		p.next()
+		p.got(";")
+		return &BreakStmt{At: at}
+
+	case "CALL", "SYSTEM_CALL":
+		return p.callStmt()
+	}
+
+	// Keywords used as commands, unless used as a name
+	if commands[word] && !(next.Kind == KindOp && (next.Text == "=" || next.Text == "(" || next.Text == ".")) {
+		p.next()
+		s := &CommandStmt{At: at, Name: word}
+		if p.sameLine() && !p.is(";") && !p.is("}") {
+			for {
+				s.Args = append(s.Args, p.expr())
+				if !p.got(",") {
+					break
+				}
+			}
+		}
+		p.got(";")
+		return s
+	}
+
+	// Local variables, by qualifier, built in type or a type name followed
+	// by the variable name
+	if qualifiers[word] || (p.tok.Kind == KindIdent && next.Kind == KindIdent && next.Pos.Line == at.Line) {
+		s := &DeclStmt{At: at, Vars: p.varSpec(true)}
+		p.got(";")
+		return s
+	}
+
+	s := p.simpleStmt()
+	p.got(";")
+	return s
+}
+
+// simpleStmt parses an assignment, increment or expression
+func (p *parser) simpleStmt() Stmt {
+
+	at := p.tok.Pos
+	x := p.unary()
+
+	switch {
+	case p.got("="):
+		return &AssignStmt{At: at, Lhs: x, Rhs: p.expr()}
+	case p.is("++"), p.is("--"):
+		s := &IncDecStmt{At: at, X: x, Op: p.tok.Text}
+		p.next()
+		return s
+	}
+	return &ExprStmt{At: at, X: x}
+}
+
+// forStmt parses FOR (Init; Cond; Post) Body
+func (p *parser) forStmt() *ForStmt {
+
+	s := &ForStmt{At: p.tok.Pos}
+	p.next()
+	p.expect("(")
+	if !p.is(";") {
+		s.Init = p.simpleStmt()
+	}
+	p.expect(";")
+	if !p.is(";") {
+		s.Cond = p.expr()
+	}
+	p.expect(";")
+	if !p.is(")") {
+		s.Post = p.simpleStmt()
+	}
+	p.expect(")")
+	s.Body = p.stmt()
+	return s
+}
+
+// clauses parses the braces of a SWITCH or SELECT, where each clause starts
+// with label and a value, or with def and no value
+func (p *parser) clauses(label string, def string) []*CaseClause {
+
+	var cs []*CaseClause
+	p.expect("{")
+	for !p.is("}") && p.tok.Kind != KindEOF {
+		if p.got(";") {
+			continue
+		}
+
+		c := &CaseClause{At: p.tok.Pos}
+		switch w := p.tok.upper(); {
+		case w == label:
+			p.next()
+			// A colon here ends the label rather than being part of a
+			// device address
+			p.noColon = true
+			c.Value = p.expr()
+			p.noColon = false
+		case def != "" && w == def:
+			p.next()
+		default:
+			if def == "" {
+				p.fail("expected %s, found %s", label, p.tok)
+			}
+			p.fail("expected %s or %s, found %s", label, def, p.tok)
+		}
+		p.expect(":")
+
+		for !p.is("}") && p.tok.Kind != KindEOF {
+			if w := p.tok.upper(); w == label || (def != "" && w == def) {
+				break
+			}
+			if s := p.stmt(); s != nil {
+				c.Body = append(c.Body, s)
+			}
+		}
+		cs = append(cs, c)
+	}
+	p.expect("}")
+	return cs
+}
+
+// waitStmt parses WAIT, WAIT_UNTIL and TIMED_WAIT_UNTIL
+func (p *parser) waitStmt() *WaitStmt {
+
+	s := &WaitStmt{At: p.tok.Pos, Kind: p.tok.upper()}
+	p.next()
+
+	if s.Kind != "WAIT" {
+		s.Cond = p.expr()
+	}
+	if s.Kind != "WAIT_UNTIL" {
+		s.Time = p.expr()
+	}
+	if p.tok.Kind == KindString && p.sameLine() {
+		s.Name = p.tok.Text
+		p.next()
+	}
+	s.Body = p.stmt()
+	return s
+}
+
+// callStmt parses CALL 'name'(args) and SYSTEM_CALL [instance] 'name'(args)
+func (p *parser) callStmt() *CallStmt {
+
+	s := &CallStmt{At: p.tok.Pos, System: p.tok.upper() == "SYSTEM_CALL"}
+	p.next()
+
+	if s.System && p.got("[") {
+		s.Instance = p.expr()
+		p.expect("]")
+	}
+	s.Name = p.stringLit()
+	if p.is("(") && p.sameLine() {
+		p.next()
+		s.Args = p.exprList(")")
+		p.expect(")")
+	}
+	p.got(";")
+	return s
+}
@@ -0,0 +1,228 @@
+package syntax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a position in a source file. Line and Col start at 1, and Col
+// counts bytes
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Kind specifies the type of a Token
+type Kind int
+
+// Token Kinds. Keywords are returned as KindIdent, as Netlinx is case
+// insensitive and any keyword can be written in lower case
+const (
+	KindEOF Kind = iota
+	KindIllegal
+	KindComment
+	KindIdent
+	KindInt
+	KindFloat
+	KindString
+	KindDirective
+	KindOp
+)
+
+var kinds = [...]string{
+	"EOF",
+	"Illegal",
+	"Comment",
+	"Ident",
+	"Int",
+	"Float",
+	"String",
+	"Directive",
+	"Op",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+// Token is a single lexical element. Text is the source of the token, except
+// for a String which holds the text between the quotes, and a Directive
+// which is upper cased
+type Token struct {
+	Kind Kind
+	Text string
+	Pos  Pos
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case KindEOF:
+		return "end of file"
+	case KindString:
+		return "'" + t.Text + "'"
+	}
+	return t.Text
+}
+
+// upper returns the Text of an Ident in upper case, or "" for any other
+// Kind, for comparing with keywords
+func (t Token) upper() string {
+	if t.Kind != KindIdent {
+		return ""
+	}
+	return strings.ToUpper(t.Text)
+}
+
+// Operators, longest first so they are matched before their prefixes
+var operators = []string{
+	"..", "<<", ">>", "<=", ">=", "==", "!=", "<>", "&&", "||", "^^", "++", "--",
+	"+", "-", "*", "/", "%", "=", "<", ">", "!", "~", "&", "|", "^",
+	"(", ")", "[", "]", "{", "}", ",", ";", ":", ".", `"`,
+}
+
+// keywordOps maps the word forms of operators to their symbols
+var keywordOps = map[string]string{
+	"AND":    "&&",
+	"OR":     "||",
+	"XOR":    "^^",
+	"NOT":    "!",
+	"BAND":   "&",
+	"BOR":    "|",
+	"BXOR":   "^",
+	"BNOT":   "~",
+	"LSHIFT": "<<",
+	"RSHIFT": ">>",
+	"MOD":    "%",
+}
+
+// SectionKind is a DEFINE_ section of a source file
+type SectionKind int
+
+// Sections. Functions are a FuncDecl rather than a Section
+const (
+	SectionDevice SectionKind = iota
+	SectionConstant
+	SectionType
+	SectionVariable
+	SectionLatching
+	SectionMutuallyExclusive
+	SectionToggling
+	SectionCombine
+	SectionConnectLevel
+	SectionStart
+	SectionEvent
+	SectionProgram
+	SectionModule
+)
+
+var sections = [...]string{
+	"DEFINE_DEVICE",
+	"DEFINE_CONSTANT",
+	"DEFINE_TYPE",
+	"DEFINE_VARIABLE",
+	"DEFINE_LATCHING",
+	"DEFINE_MUTUALLY_EXCLUSIVE",
+	"DEFINE_TOGGLING",
+	"DEFINE_COMBINE",
+	"DEFINE_CONNECT_LEVEL",
+	"DEFINE_START",
+	"DEFINE_EVENT",
+	"DEFINE_PROGRAM",
+	"DEFINE_MODULE",
+}
+
+func (s SectionKind) String() string {
+	return sections[s]
+}
+
+// sectionKind returns the SectionKind for a keyword
+func sectionKind(word string) (SectionKind, bool) {
+	for i, s := range sections {
+		if s == word {
+			return SectionKind(i), true
+		}
+	}
+	return 0, false
+}
+
+// FuncKind is the way a function is defined
+type FuncKind int
+
+// Function kinds
+const (
+	FuncFunction FuncKind = iota
+	FuncCall
+	FuncLibrary
+)
+
+var funcKinds = [...]string{
+	"DEFINE_FUNCTION",
+	"DEFINE_CALL",
+	"DEFINE_LIBRARY_FUNCTION",
+}
+
+func (f FuncKind) String() string {
+	return funcKinds[f]
+}
+
+// funcKind returns the FuncKind for a keyword
+func funcKind(word string) (FuncKind, bool) {
+	for i, f := range funcKinds {
+		if f == word {
+			return FuncKind(i), true
+		}
+	}
+	return 0, false
+}
+
+// isTopLevel returns true for the keywords which end a section
+func isTopLevel(word string) bool {
+	if _, ok := sectionKind(word); ok {
+		return true
+	}
+	if _, ok := funcKind(word); ok {
+		return true
+	}
+	return word == "PROGRAM_NAME" || word == "MODULE_NAME"
+}
+
+// Built in types
+var types = map[string]bool{
+	"CHAR": true, "WIDECHAR": true, "INTEGER": true, "SINTEGER": true,
+	"LONG": true, "SLONG": true, "FLOAT": true, "DOUBLE": true,
+	"DEV": true, "DEVCHAN": true, "DEVLEV": true,
+}
+
+// Qualifiers which can come before a type
+var qualifiers = map[string]bool{
+	"CONSTANT": true, "VOLATILE": true, "PERSISTENT": true, "NON_VOLATILE": true,
+	"LOCAL_VAR": true, "STACK_VAR": true,
+}
+
+// Events which can be defined in DEFINE_EVENT
+var events = map[string]bool{
+	"BUTTON_EVENT": true, "CHANNEL_EVENT": true, "DATA_EVENT": true,
+	"LEVEL_EVENT": true, "TIMELINE_EVENT": true, "CUSTOM_EVENT": true,
+}
+
+// Handlers within an event
+var handlers = map[string]bool{
+	"PUSH": true, "RELEASE": true, "HOLD": true,
+	"ON": true, "OFF": true,
+	"ONLINE": true, "OFFLINE": true, "ONERROR": true,
+	"STRING": true, "COMMAND": true, "STANDBY": true, "AWAKE": true,
+}
+
+// Keywords used as statements which take arguments without brackets
+var commands = map[string]bool{
+	"ON": true, "OFF": true, "PULSE": true, "TO": true, "MIN_TO": true, "TOTAL_OFF": true,
+	"SEND_STRING": true, "SEND_COMMAND": true, "SEND_LEVEL": true, "SEND_C": true,
+	"CLEAR_BUFFER": true, "CREATE_BUFFER": true, "CREATE_MULTI_BUFFER": true, "CREATE_LEVEL": true,
+	"CANCEL_WAIT": true, "CANCEL_WAIT_UNTIL": true, "PAUSE_WAIT": true, "RESTART_WAIT": true,
+	"CANCEL_ALL_WAIT": true, "CANCEL_ALL_WAIT_UNTIL": true, "PAUSE_ALL_WAIT": true, "RESTART_ALL_WAIT": true,
+}
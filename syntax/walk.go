@@ -0,0 +1,152 @@
+package syntax
+
+// Pos returns the start of the file
+func (f *File) Pos() Pos {
+	return Pos{Line: 1, Col: 1}
+}
+
+// Inspect calls fn for n and then, if fn returns true, for each node within
+// it in source order
+func Inspect(n Node, fn func(Node) bool) {
+
+	if n == nil || !fn(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	case *File:
+		if n.Header != nil {
+			Inspect(n.Header, fn)
+		}
+		for _, d := range n.Decls {
+			Inspect(d, fn)
+		}
+	case *Header:
+		inspectVars(n.Params, fn)
+	case *Directive:
+		Inspect(n.Value, fn)
+	case *Section:
+		for _, i := range n.Items {
+			Inspect(i, fn)
+		}
+	case *VarDecl:
+		inspectExprs(n.Dims, fn)
+		Inspect(n.Value, fn)
+	case *TypeDecl:
+		inspectVars(n.Fields, fn)
+	case *FuncDecl:
+		inspectExprs(n.TypeDims, fn)
+		inspectVars(n.Params, fn)
+		if n.Body != nil {
+			Inspect(n.Body, fn)
+		}
+	case *EventDecl:
+		for _, e := range n.Events {
+			Inspect(e, fn)
+		}
+		for _, h := range n.Handlers {
+			Inspect(h, fn)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, fn)
+		}
+	case *EventHead:
+		inspectExprs(n.Args, fn)
+	case *Handler:
+		inspectExprs(n.Args, fn)
+		Inspect(n.Body, fn)
+	case *ModuleInst:
+		inspectExprs(n.Args, fn)
+
+	case *Block:
+		inspectStmts(n.Stmts, fn)
+	case *DeclStmt:
+		inspectVars(n.Vars, fn)
+	case *AssignStmt:
+		Inspect(n.Lhs, fn)
+		Inspect(n.Rhs, fn)
+	case *IncDecStmt:
+		Inspect(n.X, fn)
+	case *ExprStmt:
+		Inspect(n.X, fn)
+	case *IfStmt:
+		Inspect(n.Cond, fn)
+		Inspect(n.Then, fn)
+		Inspect(n.Else, fn)
+	case *WhileStmt:
+		Inspect(n.Cond, fn)
+		Inspect(n.Body, fn)
+	case *ForStmt:
+		Inspect(n.Init, fn)
+		Inspect(n.Cond, fn)
+		Inspect(n.Post, fn)
+		Inspect(n.Body, fn)
+	case *SwitchStmt:
+		Inspect(n.Tag, fn)
+		for _, c := range n.Cases {
+			Inspect(c, fn)
+		}
+	case *SelectStmt:
+		for _, c := range n.Cases {
+			Inspect(c, fn)
+		}
+	case *CaseClause:
+		Inspect(n.Value, fn)
+		inspectStmts(n.Body, fn)
+	case *WaitStmt:
+		Inspect(n.Cond, fn)
+		Inspect(n.Time, fn)
+		Inspect(n.Body, fn)
+	case *ReturnStmt:
+		Inspect(n.Value, fn)
+	case *CallStmt:
+		Inspect(n.Instance, fn)
+		inspectExprs(n.Args, fn)
+	case *CommandStmt:
+		inspectExprs(n.Args, fn)
+
+	case *StringExpr:
+		inspectExprs(n.Elems, fn)
+	case *ArrayLit:
+		inspectExprs(n.Elems, fn)
+	case *BracketExpr:
+		inspectExprs(n.Elems, fn)
+	case *ParenExpr:
+		Inspect(n.X, fn)
+	case *TupleExpr:
+		inspectExprs(n.Elems, fn)
+	case *DeviceExpr:
+		inspectExprs(n.Parts, fn)
+	case *IndexExpr:
+		Inspect(n.X, fn)
+		Inspect(n.Index, fn)
+	case *SelectorExpr:
+		Inspect(n.X, fn)
+	case *CallExpr:
+		Inspect(n.Fun, fn)
+		inspectExprs(n.Args, fn)
+	case *UnaryExpr:
+		Inspect(n.X, fn)
+	case *BinaryExpr:
+		Inspect(n.X, fn)
+		Inspect(n.Y, fn)
+	}
+}
+
+func inspectExprs(list []Expr, fn func(Node) bool) {
+	for _, x := range list {
+		Inspect(x, fn)
+	}
+}
+
+func inspectStmts(list []Stmt, fn func(Node) bool) {
+	for _, s := range list {
+		Inspect(s, fn)
+	}
+}
+
+func inspectVars(list []*VarDecl, fn func(Node) bool) {
+	for _, v := range list {
+		Inspect(v, fn)
+	}
+}
@@ -0,0 +1,97 @@
+package version
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// FromGit returns the Version of the git working tree containing dir. The
+// nearest tag which is a semantic version gives the number, and when there
+// is none 0.0.0 is used. Builds from commits after the tag, or without a
+// tag, get the number of commits since it and the commit hash as a
+// pre-release, and uncommitted changes add dirty, as in 1.2.3-4.g1a2b3c4.dirty.
+// A nearest tag which isn't a semantic version is kept with the commit, as in
+// 0.0.0-handover-4-g1a2b3c4
+func FromGit(dir string) (Version, error) {
+
+	commit, err := git(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return Version{}, err
+	}
+
+	v := Version{Commit: commit, Time: now()}
+
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil {
+		return Version{}, err
+	}
+	v.Dirty = status != ""
+
+	// No tag isn't an error
+	semver := false
+	if tag, err := git(dir, "describe", "--tags", "--abbrev=0"); err == nil {
+		v.Tag = tag
+		if count, err := git(dir, "rev-list", "--count", tag+"..HEAD"); err == nil {
+			v.Ahead, _ = strconv.Atoi(count)
+		}
+		if t, err := Parse(tag); err == nil {
+			v.Major, v.Minor, v.Patch, v.Pre, v.Build = t.Major, t.Minor, t.Patch, t.Pre, t.Build
+			semver = true
+		}
+	}
+
+	var pre []string
+	if v.Pre != "" {
+		pre = append(pre, v.Pre)
+	}
+	if v.Tag != "" && !semver {
+		described := preRelease(v.Tag)
+		if v.Ahead > 0 {
+			described += "-" + strconv.Itoa(v.Ahead)
+		}
+		pre = append(pre, described+"-g"+v.Commit)
+	} else if v.Tag == "" || v.Ahead > 0 {
+		if v.Ahead > 0 {
+			pre = append(pre, strconv.Itoa(v.Ahead))
+		}
+		pre = append(pre, "g"+v.Commit)
+	}
+	if v.Dirty {
+		pre = append(pre, "dirty")
+	}
+	v.Pre = strings.Join(pre, ".")
+
+	return v, nil
+}
+
+// preRelease returns s with anything not allowed in a pre-release identifier
+// replaced by -
+func preRelease(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '-' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// git runs a git command in dir, returning its trimmed output
+func git(dir string, args ...string) (string, error) {
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New("git " + args[0] + ": " + msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,90 @@
+package version
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// repo creates a git repository with one commit
+func repo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := ioutil.TempDir("", "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, dir, "init", "-q")
+	commit(t, dir, "one")
+	return dir
+}
+
+// run runs git in dir, failing the test if it fails
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// commit writes a file and commits it
+func commit(t *testing.T, dir string, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.axs"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", content)
+}
+
+func TestFromGit(t *testing.T) {
+
+	dir := repo(t)
+	defer os.RemoveAll(dir)
+
+	check := func(want string) {
+		t.Helper()
+		v, err := FromGit(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Commit != run(t, dir, "rev-parse", "--short", "HEAD") {
+			t.Errorf("Commit = %q, want HEAD", v.Commit)
+		}
+		if got := v.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+		if _, err := Parse(v.String()); err != nil {
+			t.Errorf("String() isn't a semantic version: %v", err)
+		}
+	}
+	head := func() string {
+		return run(t, dir, "rev-parse", "--short", "HEAD")
+	}
+
+	check("0.0.0-g" + head())
+
+	run(t, dir, "tag", "v1.2.3")
+	check("1.2.3")
+
+	commit(t, dir, "two")
+	check("1.2.3-1.g" + head())
+
+	// A tag which isn't a version keeps the commit
+	run(t, dir, "tag", "site_handover")
+	check("0.0.0-site-handover-g" + head())
+
+	commit(t, dir, "three")
+	check("0.0.0-site-handover-1-g" + head())
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.axs"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check("0.0.0-site-handover-1-g" + head() + ".dirty")
+}
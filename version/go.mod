@@ -0,0 +1,3 @@
+module github.com/soloworks/go-netlinx/version
+
+go 1.12
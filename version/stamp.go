@@ -0,0 +1,104 @@
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommentPrefix starts the line StampComments adds to workspace comments
+const CommentPrefix = "Build: "
+
+// ManifestName is the name of the manifest in a package
+const ManifestName = "manifest.json"
+
+// Manifest describes a package and the build it came from
+type Manifest struct {
+	Workspace string    `json:"workspace"`
+	Type      string    `json:"type"`
+	Version   string    `json:"version"`
+	Tag       string    `json:"tag,omitempty"`
+	Commit    string    `json:"commit,omitempty"`
+	Dirty     bool      `json:"dirty"`
+	Built     time.Time `json:"built"`
+	Files     []string  `json:"files"`
+}
+
+// Manifest returns the Manifest for a package of a workspace
+func (v Version) Manifest(workspace string, kind string, files []string) Manifest {
+	return Manifest{
+		Workspace: workspace,
+		Type:      kind,
+		Version:   v.String(),
+		Tag:       v.Tag,
+		Commit:    v.Commit,
+		Dirty:     v.Dirty,
+		Built:     v.Time,
+		Files:     files,
+	}
+}
+
+// JSON returns the Manifest as indented JSON
+func (m Manifest) JSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// StampComments returns comments with a line holding the version, replacing
+// any added by an earlier stamp
+func (v Version) StampComments(comments string) string {
+
+	nl := "\n"
+	if strings.Contains(comments, "\r\n") {
+		nl = "\r\n"
+	}
+
+	var lines []string
+	for _, l := range strings.Split(comments, nl) {
+		if !strings.HasPrefix(l, CommentPrefix) {
+			lines = append(lines, l)
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(append(lines, CommentPrefix+v.String()), nl)
+}
+
+// AXI returns a NetLinx include defining the version as constants, for
+// the compiled program to report. Each constant name starts with prefix
+func (v Version) AXI(prefix string) []byte {
+
+	guard := "__" + prefix + "VERSION_AXI__"
+	dirty := "0"
+	if v.Dirty {
+		dirty = "1"
+	}
+
+	var b bytes.Buffer
+	b.WriteString("PROGRAM_NAME='" + strings.ToLower(prefix) + "version'\r\n")
+	b.WriteString("(* Generated by packup, changes will be overwritten *)\r\n")
+	b.WriteString("#IF_NOT_DEFINED " + guard + "\r\n")
+	b.WriteString("#DEFINE " + guard + "\r\n")
+	b.WriteString("\r\n")
+	b.WriteString("DEFINE_CONSTANT\r\n")
+	b.WriteString("\r\n")
+	b.WriteString("CHAR " + prefix + "VERSION[] = '" + quote(v.String()) + "'\r\n")
+	b.WriteString("INTEGER " + prefix + "VERSION_MAJOR = " + strconv.Itoa(v.Major) + "\r\n")
+	b.WriteString("INTEGER " + prefix + "VERSION_MINOR = " + strconv.Itoa(v.Minor) + "\r\n")
+	b.WriteString("INTEGER " + prefix + "VERSION_PATCH = " + strconv.Itoa(v.Patch) + "\r\n")
+	b.WriteString("CHAR " + prefix + "COMMIT[] = '" + quote(v.Commit) + "'\r\n")
+	b.WriteString("INTEGER " + prefix + "DIRTY = " + dirty + "\r\n")
+	b.WriteString("CHAR " + prefix + "BUILT[] = '" + v.Time.UTC().Format(time.RFC3339) + "'\r\n")
+	b.WriteString("\r\n")
+	b.WriteString("#END_IF\r\n")
+
+	return b.Bytes()
+}
+
+// quote makes s safe inside a NetLinx string literal
+func quote(s string) string {
+	return strings.Replace(s, "'", "''", -1)
+}
@@ -0,0 +1,96 @@
+package version
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version identifies a build, from a semantic version and optionally the
+// git commit it was made from
+type Version struct {
+	Major  int
+	Minor  int
+	Patch  int
+	Pre    string
+	Build  string
+	Tag    string
+	Commit string
+	Ahead  int
+	Dirty  bool
+	Time   time.Time
+}
+
+var semverRe = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)` +
+	`(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
+
+// Parse reads a semantic version such as 1.2.3, v1.2.3-rc.1 or 1.2.3+site
+func Parse(s string) (Version, error) {
+
+	m := semverRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, errors.New("invalid version " + s + ", expected MAJOR.MINOR.PATCH")
+	}
+
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	v.Pre = m[4]
+	v.Build = m[5]
+	v.Time = now()
+	return v, nil
+}
+
+// String returns the semantic version
+func (v Version) String() string {
+	s := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// BuildID returns the version made safe for use in file names
+func (v Version) BuildID() string {
+	return strings.Replace(v.String(), "+", "_", -1)
+}
+
+// FromTime returns 0.0.0 with the UTC time as a pre-release, for builds
+// with neither git nor a supplied version
+func FromTime(t time.Time) Version {
+	t = t.UTC().Truncate(time.Second)
+	return Version{Pre: t.Format("20060102150405"), Time: t}
+}
+
+// Detect returns the supplied version if set, with the commit from git
+// where dir is in a working tree. Otherwise the Version comes from git, or
+// the time if that fails. Only an invalid supplied version is an error
+func Detect(dir string, supplied string) (Version, error) {
+
+	if supplied == "" {
+		if v, err := FromGit(dir); err == nil {
+			return v, nil
+		}
+		return FromTime(time.Now()), nil
+	}
+
+	v, err := Parse(supplied)
+	if err != nil {
+		return v, err
+	}
+	if g, err := FromGit(dir); err == nil {
+		v.Tag, v.Commit, v.Ahead, v.Dirty = g.Tag, g.Commit, g.Ahead, g.Dirty
+	}
+	return v, nil
+}
+
+// now returns the time a Version is made, to the second
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}